@@ -2,32 +2,18 @@ package main
 
 import (
 	"bufio"
-	"bytes"
 	"fmt"
 	"os"
 	"os/exec"
-	"os/signal"
 	"path/filepath"
 	"sort"
 	"strings"
-	"syscall"
 	"time"
 
-	"github.com/eiannone/keyboard"
+	"github.com/ankddev/zapret-discord-youtube/internal/tui"
 )
 
-const (
-	serviceName         = "zapret_by_ankddev"
-	clearScreenSequence = "\033[2J\033[H\033[3J"
-	defaultTermHeight   = 24
-	enterAltScreen      = "\033[?1049h"
-	exitAltScreen       = "\033[?1049l"
-	hideCursor          = "\033[?25l"
-	showCursor          = "\033[?25h"
-	fps                 = 240
-	frameTime           = time.Second / time.Duration(fps)
-	bufferSize          = 4096
-)
+const serviceName = "zapret_by_ankddev"
 
 var version string
 
@@ -42,8 +28,6 @@ const (
 	colorRed    = "\033[31m"
 	colorGreen  = "\033[32m"
 	colorYellow = "\033[33m"
-	colorCyan   = "\033[36m"
-	colorGrey   = "\033[90m"
 )
 
 func NewServiceManager(name string) *ServiceManager {
@@ -180,12 +164,8 @@ func getOptions() ([]string, int) {
 	return options, len(batFiles)
 }
 
-func clearScreen(buf *bytes.Buffer) {
-	buf.WriteString(clearScreenSequence)
-}
-
-func printWelcomeMessage(buf *bytes.Buffer, configCount int) {
-	messages := []string{
+func welcomeMessage(configCount int) []string {
+	return []string{
 		"Welcome!",
 		"This program can install BAT file as service with autorun.",
 		"Author: ANKDDEV https://github.com/ankddev",
@@ -195,179 +175,41 @@ func printWelcomeMessage(buf *bytes.Buffer, configCount int) {
 		"\nUsing ARROWS on your keyboard, select BAT file from list for installing service 'discordfix_zapret' or select 'Delete service from autorun' or 'Run BLOCKCHECK (Auto-setting BAT parameters)' or select 'Exit'.\n",
 		"For selection press ENTER.",
 	}
-
-	for _, msg := range messages {
-		buf.WriteString(msg + "\n")
-	}
-}
-
-// Add new functions for terminal handling
-func getTerminalSize() (int, int) {
-	cmd := exec.Command("stty", "size")
-	cmd.Stdin = os.Stdin
-	out, err := cmd.Output()
-	if err != nil {
-		return 80, defaultTermHeight
-	}
-
-	var rows, cols int
-	fmt.Sscanf(string(out), "%d %d", &rows, &cols)
-	return cols, rows
 }
 
 func main() {
-	// Setup cleanup on exit
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-c
-		fmt.Print(showCursor + exitAltScreen)
-		keyboard.Close()
-		os.Exit(1)
-	}()
-
-	fmt.Print(enterAltScreen + hideCursor)
-	defer fmt.Print(showCursor + exitAltScreen)
-
-	var buf bytes.Buffer
-	buf.Grow(bufferSize)
-
-	// Pre-calculate terminal dimensions
-	_, termHeight := getTerminalSize()
-	visibleItems := termHeight - 12
-	startIdx := 0
-
-	// Create output buffer for direct writes
-	output := bufio.NewWriter(os.Stdout)
-	defer output.Flush()
-
 	options, configCount := getOptions()
-	if len(options) == 0 {
-		fmt.Println("Can't find any BAT files in current directory.")
-		return
+	list := &tui.List{
+		Header: welcomeMessage(configCount),
+		Items:  tui.Items(options...),
 	}
 
-	currentSelection := 0
-	serviceManager := NewServiceManager(serviceName)
-
-	// Initialize keyboard
-	if err := keyboard.Open(); err != nil {
-		fmt.Println("Error initializing keyboard:", err)
+	choice, err := tui.Show(list)
+	if err != nil {
+		fmt.Println("Error:", err)
 		return
 	}
-	defer keyboard.Close()
-
-	// Main loop
-	for {
-		start := time.Now()
-
-		buf.Reset()
-		buf.WriteString("\033[H\033[J")
-
-		printWelcomeMessage(&buf, configCount)
-
-		// Calculate visible range and scroll position
-		endIdx := min(startIdx+visibleItems, len(options))
-
-		// Update scroll position before selection becomes invisible
-		if currentSelection >= startIdx+visibleItems-1 {
-			startIdx = currentSelection - visibleItems + 2
-		} else if currentSelection < startIdx {
-			startIdx = currentSelection
-		}
-
-		// Ensure startIdx stays within bounds
-		if startIdx < 0 {
-			startIdx = 0
-		}
-		if startIdx > len(options)-visibleItems {
-			startIdx = max(0, len(options)-visibleItems)
-		}
-
-		// Show scroll indicators only if needed
-		if startIdx > 0 {
-			buf.WriteString(fmt.Sprintf("%s↑ more items above%s\n", colorGrey, colorReset))
-		}
-
-		// Batch write visible options with proper spacing
-		for i := startIdx; i < endIdx; i++ {
-			if i == currentSelection {
-				buf.WriteString(fmt.Sprintf("%s► %s%s\n", colorCyan, options[i], colorReset))
-			} else {
-				buf.WriteString(fmt.Sprintf("  %s\n", options[i]))
-			}
-		}
-
-		// Show bottom scroll indicator with proper spacing
-		if endIdx < len(options) {
-			buf.WriteString(fmt.Sprintf("%s↓ more items below%s\n", colorGrey, colorReset))
-		}
-
-		// Single write operation to terminal
-		output.Write(buf.Bytes())
-		output.Flush()
-
-		// Precise frame timing
-		elapsed := time.Since(start)
-		if elapsed < frameTime {
-			time.Sleep(frameTime - elapsed)
-		}
-
-		// Non-blocking keyboard input
-		if _, key, err := keyboard.GetKey(); err == nil {
-			switch key {
-			case keyboard.KeyArrowUp:
-				if currentSelection > 0 {
-					currentSelection--
-				}
-			case keyboard.KeyArrowDown:
-				if currentSelection < len(options)-1 {
-					currentSelection++
-				}
-			case keyboard.KeyEnter:
-				clearScreen(&buf)
-				switch options[currentSelection] {
-				case "Delete service from autorun":
-					serviceManager.removeService()
-				case "Run BLOCKCHECK (Auto-setting BAT parameters)":
-					// Restore normal terminal state before launch
-					fmt.Print(showCursor + exitAltScreen)
-					keyboard.Close()
-
-					_, err := serviceManager.runPowershellCommand("Start-Process 'blockcheck.cmd'")
-					if err != nil {
-						fmt.Printf("%s⚠ Error running BLOCKCHECK: %v%s\n", colorRed, err, colorReset)
-					}
-					return
-				case "Exit":
-					return
-				default:
-					batPath := filepath.Join("pre-configs", options[currentSelection])
-					serviceManager.installService(batPath)
-				}
-
-				fmt.Println("Ready! You can close this window")
-				bufio.NewReader(os.Stdin).ReadBytes('\n')
-				return
-			case keyboard.KeyEsc:
-				return
-			}
-		}
+	if choice == tui.Cancelled {
+		return
 	}
-}
 
-// Helper function for min
-func min(a, b int) int {
-	if a < b {
-		return a
+	serviceManager := NewServiceManager(serviceName)
+	switch options[choice] {
+	case "Delete service from autorun":
+		serviceManager.removeService()
+	case "Run BLOCKCHECK (Auto-setting BAT parameters)":
+		_, err := serviceManager.runPowershellCommand("Start-Process 'blockcheck.cmd'")
+		if err != nil {
+			fmt.Printf("%s⚠ Error running BLOCKCHECK: %v%s\n", colorRed, err, colorReset)
+		}
+		return
+	case "Exit":
+		return
+	default:
+		batPath := filepath.Join("pre-configs", options[choice])
+		serviceManager.installService(batPath)
 	}
-	return b
-}
 
-// Add helper function for max
-func max(a, b int) int {
-	if a > b {
-		return a
-	}
-	return b
+	fmt.Println("Ready! You can close this window")
+	bufio.NewReader(os.Stdin).ReadBytes('\n')
 }
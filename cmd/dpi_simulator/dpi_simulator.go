@@ -0,0 +1,129 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ankddev/zapret-discord-youtube/internal/dpisim"
+)
+
+const (
+	// Colors
+	colorReset = "\033[0m"
+	colorRed   = "\033[31m"
+	colorCyan  = "\033[36m"
+
+	defaultStub = "http://warning.rt.ru/"
+)
+
+// Version is set during build
+var version string
+
+// Config of simulator
+type Config struct {
+	rules string
+	tcp   string
+	udp   string
+	sim   dpisim.Config
+}
+
+func run(config Config) error {
+	rules, err := dpisim.LoadRules(config.rules, config.sim.Stub)
+	if err != nil {
+		return err
+	}
+	config.sim.Rules = rules
+	s, err := dpisim.New(config.sim)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%sDPI simulator%s (version %s), %d rules loaded from %s\n", colorCyan, colorReset, version, len(rules), config.rules)
+	for _, rule := range rules {
+		fmt.Printf("  %s\n", rule)
+	}
+
+	errs := make(chan error, 2)
+	if config.tcp != "" {
+		listener, err := net.Listen("tcp", config.tcp)
+		if err != nil {
+			return fmt.Errorf("error listening TCP: %v", err)
+		}
+		defer listener.Close()
+		fmt.Printf("Listening TCP on %s\n", listener.Addr())
+		go func() { errs <- s.ServeTCP(listener) }()
+	}
+	if config.udp != "" {
+		address, err := net.ResolveUDPAddr("udp", config.udp)
+		if err != nil {
+			return fmt.Errorf("invalid UDP address: %v", err)
+		}
+		conn, err := net.ListenUDP("udp", address)
+		if err != nil {
+			return fmt.Errorf("error listening UDP: %v", err)
+		}
+		defer conn.Close()
+		fmt.Printf("Listening UDP on %s\n", conn.LocalAddr())
+		go func() { errs <- s.ServeUDP(conn) }()
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-errs:
+		return err
+	case <-interrupt:
+		fmt.Println("\nStopped")
+		return nil
+	}
+}
+
+func main() {
+	config := Config{
+		tcp: ":10443",
+		sim: dpisim.Config{
+			Reassemble: true,
+			Timeout:    5 * time.Second,
+			Stub:       defaultStub,
+		},
+	}
+	fs := flag.NewFlagSet("dpi_simulator", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: dpi_simulator -rules FILE [flags]")
+		fmt.Fprintln(fs.Output(), "\nProxy that blocks connections like DPI of ISP, for testing on Linux.\n\nFlags:")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&config.rules, "rules", "", "file with rules, one \"[proto:]host action [argument]\" per line")
+	fs.StringVar(&config.tcp, "tcp", config.tcp, "address to accept TCP connections on, empty to disable")
+	fs.StringVar(&config.udp, "udp", "", "address to accept UDP datagrams on, empty to disable")
+	fs.StringVar(&config.sim.Upstream, "upstream", "", "send all traffic to this address instead of original destination")
+	fs.StringVar(&config.sim.DNS, "dns", "", "DNS server to resolve hosts with, like 1.1.1.1:53")
+	fs.IntVar(&config.sim.Mark, "mark", 0, "fwmark of upstream connections, to exclude them from redirect")
+	fs.BoolVar(&config.sim.Reassemble, "reassemble", config.sim.Reassemble, "wait for whole request, false inspects only first segment")
+	fs.DurationVar(&config.sim.Timeout, "timeout", config.sim.Timeout, "time to wait for request of client")
+	fs.StringVar(&config.sim.Stub, "stub", config.sim.Stub, "URL stub redirects to by default")
+	fs.BoolVar(&config.sim.Verbose, "v", false, "log size of every answer")
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+	if config.rules == "" || fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if config.tcp == "" && config.udp == "" {
+		fmt.Fprintln(os.Stderr, "Error: both -tcp and -udp are disabled")
+		os.Exit(2)
+	}
+
+	if err := run(config); err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: %v%s\n", colorRed, err, colorReset)
+		os.Exit(1)
+	}
+}
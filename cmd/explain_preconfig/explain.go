@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ankddev/zapret-discord-youtube/internal/preconfig"
+)
+
+// Messages of explanation
+var messages = map[string]preconfig.Text{
+	"file":         {EN: "File: %s", RU: "Файл: %s"},
+	"intercepted":  {EN: "Intercepted traffic", RU: "Перехватываемый трафик"},
+	"instance":     {EN: "Settings of winws", RU: "Настройки winws"},
+	"order":        {EN: "winws checks profiles in order, packet is handled by first profile it matches.", RU: "winws проверяет профили по порядку, пакет обрабатывается первым подходящим профилем."},
+	"profile":      {EN: "Profile %d of %d", RU: "Профиль %d из %d"},
+	"skipped":      {EN: "profile is disabled by --skip", RU: "профиль отключён через --skip"},
+	"matches":      {EN: "Matches:", RU: "Применяется к:"},
+	"does":         {EN: "Does:", RU: "Делает:"},
+	"nothing":      {EN: "nothing, traffic passes unchanged", RU: "ничего, трафик проходит без изменений"},
+	"tcp":          {EN: "TCP ports %s", RU: "TCP порты %s"},
+	"udp":          {EN: "UDP ports %s", RU: "UDP порты %s"},
+	"anyPort":      {EN: "any port intercepted by WinDivert", RU: "любые порты, перехваченные WinDivert"},
+	"l3":           {EN: "IP versions %s", RU: "версии IP %s"},
+	"l7":           {EN: "protocols %s", RU: "протоколы %s"},
+	"ssid":         {EN: "Wi-Fi networks %s", RU: "Wi-Fi сети %s"},
+	"hostlist":     {EN: "domains from %s", RU: "домены из %s"},
+	"domains":      {EN: "domains %s", RU: "домены %s"},
+	"hostExclude":  {EN: "except domains from %s", RU: "кроме доменов из %s"},
+	"domExclude":   {EN: "except domains %s", RU: "кроме доменов %s"},
+	"autohostlist": {EN: "domains winws finds blocked, they are saved to %s", RU: "домены, которые winws сочтёт заблокированными, они сохраняются в %s"},
+	"ipset":        {EN: "IP addresses from %s", RU: "IP адреса из %s"},
+	"ips":          {EN: "IP addresses %s", RU: "IP адреса %s"},
+	"ipExclude":    {EN: "except IP addresses from %s", RU: "кроме IP адресов из %s"},
+	"ipsExclude":   {EN: "except IP addresses %s", RU: "кроме IP адресов %s"},
+	"anyHost":      {EN: "any host", RU: "любые хосты"},
+	"total":        {EN: "%s, %d in total", RU: "%s, всего %d"},
+	"missing":      {EN: "%s, file not found", RU: "%s, файл не найден"},
+	"unknown":      {EN: "no description", RU: "нет описания"},
+}
+
+// message returns formatted message in language
+func message(lang preconfig.Lang, key string, args ...any) string {
+	return fmt.Sprintf(messages[key].In(lang), args...)
+}
+
+// Options that select traffic of profile, they are explained as single list
+// instead of one by one
+var filterOptions = map[string]string{
+	"filter-tcp":               "tcp",
+	"filter-udp":               "udp",
+	"filter-l3":                "l3",
+	"filter-l7":                "l7",
+	"filter-ssid":              "ssid",
+	"hostlist":                 "hostlist",
+	"hostlist-domains":         "domains",
+	"hostlist-exclude":         "hostExclude",
+	"hostlist-exclude-domains": "domExclude",
+	"hostlist-auto":            "autohostlist",
+	"ipset":                    "ipset",
+	"ipset-ip":                 "ips",
+	"ipset-exclude":            "ipExclude",
+	"ipset-exclude-ip":         "ipsExclude",
+}
+
+// Filter options whose value is file with list of entries
+var listOptions = map[string]bool{
+	"hostlist":         true,
+	"hostlist-exclude": true,
+	"ipset":            true,
+	"ipset-exclude":    true,
+}
+
+// explain writes plain language description of pre-config
+func explain(w io.Writer, p *preconfig.Preconfig, lang preconfig.Lang) {
+	title := p.Name
+	if p.Title != "" {
+		title += " (" + p.Title + ")"
+	}
+	fmt.Fprintf(w, "%s%s%s\n", colorCyan, title, colorReset)
+	fmt.Fprintln(w, message(lang, "file", p.Path))
+
+	var filters, settings []preconfig.Option
+	for _, o := range p.Global {
+		if strings.HasPrefix(o.Name, "wf-") {
+			filters = append(filters, o)
+		} else {
+			settings = append(settings, o)
+		}
+	}
+	if len(filters) > 0 {
+		fmt.Fprintf(w, "\n%s:\n", message(lang, "intercepted"))
+		for _, o := range filters {
+			explainOption(w, o, lang)
+		}
+	}
+	if len(settings) > 0 {
+		fmt.Fprintf(w, "\n%s:\n", message(lang, "instance"))
+		for _, o := range settings {
+			explainOption(w, o, lang)
+		}
+	}
+
+	if len(p.Profiles) > 1 {
+		fmt.Fprintf(w, "\n%s\n", message(lang, "order"))
+	}
+	for i, profile := range p.Profiles {
+		fmt.Fprintf(w, "\n%s%s%s\n", colorGreen, message(lang, "profile", i+1, len(p.Profiles)), colorReset)
+		explainProfile(w, p, profile, lang)
+	}
+}
+
+// explainProfile writes what traffic profile matches and what it does with it
+func explainProfile(w io.Writer, p *preconfig.Preconfig, profile preconfig.Profile, lang preconfig.Lang) {
+	if profile.Has("skip") {
+		fmt.Fprintf(w, "  %s%s%s\n", colorGrey, message(lang, "skipped"), colorReset)
+	}
+
+	var matches []string
+	var actions []preconfig.Option
+	hasPort, hasHost := false, false
+	for _, o := range profile.Options {
+		key, ok := filterOptions[o.Name]
+		switch {
+		case o.Name == "skip":
+		case !ok:
+			actions = append(actions, o)
+		default:
+			value := o.Value
+			if listOptions[o.Name] || o.Name == "hostlist-auto" {
+				value = filepath.Base(p.ResolvePath(o.Value))
+			}
+			if listOptions[o.Name] {
+				value = countEntries(p.ResolvePath(o.Value), value, lang)
+			}
+			matches = append(matches, message(lang, key, value))
+			hasPort = hasPort || o.Name == "filter-tcp" || o.Name == "filter-udp"
+			hasHost = hasHost || strings.HasPrefix(o.Name, "hostlist") || strings.HasPrefix(o.Name, "ipset")
+		}
+	}
+	if !hasPort {
+		matches = append([]string{message(lang, "anyPort")}, matches...)
+	}
+	if !hasHost {
+		matches = append(matches, message(lang, "anyHost"))
+	}
+
+	fmt.Fprintf(w, "  %s\n", message(lang, "matches"))
+	for _, match := range matches {
+		fmt.Fprintf(w, "    - %s\n", match)
+	}
+	fmt.Fprintf(w, "  %s\n", message(lang, "does"))
+	if len(actions) == 0 {
+		fmt.Fprintf(w, "    %s\n", message(lang, "nothing"))
+	}
+	for _, o := range actions {
+		explainOption(w, o, lang)
+	}
+}
+
+// explainOption writes option with its description, and descriptions of
+// every mode or fooling method it lists
+func explainOption(w io.Writer, o preconfig.Option, lang preconfig.Lang) {
+	description := preconfig.DescribeOption(o.Name, lang)
+	if description == "" {
+		description = message(lang, "unknown")
+	}
+	fmt.Fprintf(w, "    %s%s%s\n", colorCyan, o, colorReset)
+	fmt.Fprintf(w, "      %s\n", description)
+
+	var describe func(string, preconfig.Lang) string
+	switch o.Name {
+	case "dpi-desync":
+		describe = preconfig.DescribeMode
+	case "dpi-desync-fooling":
+		describe = preconfig.DescribeFooling
+	default:
+		return
+	}
+	for _, value := range strings.Split(o.Value, ",") {
+		if description := describe(value, lang); description != "" {
+			fmt.Fprintf(w, "        %s: %s\n", value, description)
+		}
+	}
+}
+
+// countEntries adds number of entries in list file to its name
+func countEntries(path, name string, lang preconfig.Lang) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return message(lang, "missing", name)
+	}
+	defer file.Close()
+
+	count := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			count++
+		}
+	}
+	return message(lang, "total", name, count)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ankddev/zapret-discord-youtube/internal/preconfig"
+)
+
+const (
+	// Colors
+	colorReset = "\033[0m"
+	colorRed   = "\033[31m"
+	colorGreen = "\033[32m"
+	colorCyan  = "\033[36m"
+	colorGrey  = "\033[90m"
+
+	preconfigsDir = "pre-configs"
+)
+
+// Version is set during build
+var version string
+
+// findPreconfig returns path of pre-config given by path or by name in
+// pre-configs folder, with or without extension
+func findPreconfig(name string) (string, error) {
+	candidates := []string{name, filepath.Join(preconfigsDir, name)}
+	if !strings.HasSuffix(strings.ToLower(name), ".bat") {
+		candidates = append(candidates, name+".bat", filepath.Join(preconfigsDir, name+".bat"))
+	}
+	for _, path := range candidates {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("pre-config %s not found", name)
+}
+
+// explainFile loads pre-config and prints its explanation
+func explainFile(name string, lang preconfig.Lang) error {
+	path, err := findPreconfig(name)
+	if err != nil {
+		return err
+	}
+	p, err := preconfig.Load(path)
+	if err != nil {
+		return err
+	}
+	explain(os.Stdout, p, lang)
+	return nil
+}
+
+func runInteractive() int {
+	reader := bufio.NewReader(os.Stdin)
+	defer func() {
+		fmt.Println("\nPress Enter to exit...")
+		reader.ReadString('\n')
+	}()
+
+	fmt.Printf("%sExplain pre-config%s (version %s)\n", colorCyan, colorReset, version)
+	fmt.Println("This program describes in plain language what pre-config does.")
+
+	fmt.Print("\nLanguage / Язык: 1 - English, 2 - Русский [1]: ")
+	answer, err := reader.ReadString('\n')
+	if err != nil {
+		fmt.Printf("Error reading input: %v\n", err)
+		return 1
+	}
+	lang := preconfig.LangEN
+	if strings.TrimSpace(answer) == "2" {
+		lang = preconfig.LangRU
+	}
+
+	files, _ := filepath.Glob(filepath.Join(preconfigsDir, "*.bat"))
+	sort.Strings(files)
+	if len(files) == 0 {
+		fmt.Printf("%sNo pre-configs found in %s%s\n", colorRed, preconfigsDir, colorReset)
+		return 1
+	}
+	fmt.Println("\nSelect pre-config to explain:")
+	for i, path := range files {
+		fmt.Printf("%d. %s\n", i+1, strings.TrimSuffix(filepath.Base(path), ".bat"))
+	}
+	for {
+		fmt.Print("\nEnter number of pre-config: ")
+		answer, err := reader.ReadString('\n')
+		if err != nil {
+			fmt.Printf("Error reading input: %v\n", err)
+			return 1
+		}
+		var n int
+		if _, err := fmt.Sscan(strings.TrimSpace(answer), &n); err != nil || n < 1 || n > len(files) {
+			fmt.Printf("Invalid selection. Please select number from 1 to %d\n", len(files))
+			continue
+		}
+		fmt.Println()
+		if err := explainFile(files[n-1], lang); err != nil {
+			fmt.Printf("%sError: %v%s\n", colorRed, err, colorReset)
+			return 1
+		}
+		return 0
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		os.Exit(runInteractive())
+	}
+
+	fs := flag.NewFlagSet("explain_preconfig", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: explain_preconfig [-lang en|ru] PRE-CONFIG...")
+		fmt.Fprintln(fs.Output(), "\nDescribes what traffic every profile of pre-config matches and what its")
+		fmt.Fprintln(fs.Output(), "options do. Pre-config is path of BAT file or its name in pre-configs folder.")
+		fmt.Fprintln(fs.Output(), "Without arguments tool runs in interactive mode.\n\nFlags:")
+		fs.PrintDefaults()
+	}
+	langCode := fs.String("lang", "en", "language of descriptions: en or ru")
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+	if *langCode != "en" && *langCode != "ru" {
+		fmt.Fprintf(os.Stderr, "Error: unknown language '%s', use en or ru\n", *langCode)
+		os.Exit(2)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	lang := preconfig.ParseLang(*langCode)
+	code := 0
+	for i, name := range fs.Args() {
+		if i > 0 {
+			fmt.Println()
+		}
+		if err := explainFile(name, lang); err != nil {
+			fmt.Fprintf(os.Stderr, "%sError: %v%s\n", colorRed, err, colorReset)
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
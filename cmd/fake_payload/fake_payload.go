@@ -0,0 +1,394 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ankddev/zapret-discord-youtube/internal/fake"
+	"github.com/ankddev/zapret-discord-youtube/internal/preconfig"
+)
+
+const (
+	// Colors
+	colorReset = "\033[0m"
+	colorRed   = "\033[31m"
+	colorGreen = "\033[32m"
+	colorCyan  = "\033[36m"
+
+	binDir        = "bin"
+	preconfigsDir = "pre-configs"
+)
+
+// Folders with fake payloads, shipped with fix and with blockcheck
+var payloadDirs = []string{
+	binDir,
+	filepath.Join(binDir, "blockcheck", "zapret", "files", "fake"),
+}
+
+// Version is set during build
+var version string
+
+const usage = `Usage: fake_payload <command> [arguments]
+
+Without arguments tool runs in interactive mode.
+
+Commands:
+  inspect FILE...        decode TLS ClientHello or QUIC Initial in fake payload files
+  generate -sni DOMAIN   build TLS ClientHello or QUIC Initial fake payload,
+                         run "fake_payload generate -help" to see all flags
+  check [DIR]            check that pre-configs in DIR (default pre-configs) pass
+                         right kind of payload to every fake option
+`
+
+// inspect prints what fake payload claims to be
+func inspect(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	kind := fake.Detect(data)
+	fmt.Printf("%s%s%s (%d bytes)\n", colorCyan, path, colorReset, len(data))
+	fmt.Printf("  Type: %s\n", kind)
+
+	switch kind {
+	case fake.KindTLS:
+		hello, err := fake.ParseClientHello(data)
+		if err != nil {
+			return err
+		}
+		printClientHello(hello, "  ")
+	case fake.KindQUIC:
+		packet, err := fake.ParseQUICInitial(data)
+		if err != nil {
+			return err
+		}
+		printQUICInitial(packet)
+	case fake.KindHTTP:
+		line, _, _ := strings.Cut(string(data), "\n")
+		fmt.Printf("  Request: %s\n", strings.TrimSpace(line))
+		for _, header := range strings.Split(string(data), "\n") {
+			if name, value, ok := strings.Cut(header, ":"); ok && strings.EqualFold(name, "host") {
+				fmt.Printf("  Host: %s\n", strings.TrimSpace(value))
+			}
+		}
+	case fake.KindZero:
+	default:
+		dump := data
+		if len(dump) > 64 {
+			dump = dump[:64]
+		}
+		fmt.Printf("  First bytes:\n%s", indent(hex.Dump(dump), "    "))
+	}
+	return nil
+}
+
+func printClientHello(hello *fake.ClientHello, prefix string) {
+	if hello.RecordVersion != 0 {
+		fmt.Printf("%sRecord version: %s\n", prefix, fake.VersionName(hello.RecordVersion))
+	}
+	fmt.Printf("%sVersion: %s\n", prefix, fake.VersionName(hello.Version))
+	if len(hello.SupportedVersions) > 0 {
+		fmt.Printf("%sSupported versions: %s\n", prefix, names(hello.SupportedVersions, fake.VersionName))
+	}
+	fmt.Printf("%sSNI: %s\n", prefix, orNone(hello.SNI))
+	fmt.Printf("%sALPN: %s\n", prefix, orNone(hello.ALPN))
+	fmt.Printf("%sSession ID: %d bytes\n", prefix, len(hello.SessionID))
+
+	var shares []string
+	for _, share := range hello.KeyShares {
+		shares = append(shares, fmt.Sprintf("%s (%d bytes)", fake.GroupName(share.Group), share.Length))
+	}
+	fmt.Printf("%sKey shares: %s\n", prefix, orNone(shares))
+	if hello.HasPostQuantum() {
+		fmt.Printf("%s%sPost-quantum key share present, ClientHello is larger than usual%s\n", prefix, colorGreen, colorReset)
+	}
+	if len(hello.SupportedGroups) > 0 {
+		fmt.Printf("%sSupported groups: %s\n", prefix, names(hello.SupportedGroups, fake.GroupName))
+	}
+
+	fmt.Printf("%sCipher suites (%d):\n", prefix, len(hello.CipherSuites))
+	for _, suite := range hello.CipherSuites {
+		fmt.Printf("%s  %s\n", prefix, fake.CipherSuiteName(suite))
+	}
+	fmt.Printf("%sExtensions (%d):\n", prefix, len(hello.Extensions))
+	for _, ext := range hello.Extensions {
+		fmt.Printf("%s  %s, %d bytes\n", prefix, fake.ExtensionName(ext.Type), len(ext.Data))
+	}
+
+	fmt.Printf("%sSize: %d bytes", prefix, hello.Size)
+	if hello.Padding > 0 {
+		fmt.Printf(", padding %d bytes", hello.Padding)
+	}
+	if hello.Trailing > 0 {
+		fmt.Printf(", %d bytes after ClientHello", hello.Trailing)
+	}
+	fmt.Println()
+	if hello.Truncated {
+		fmt.Printf("%s%sClientHello is truncated, rest of it is in next packet%s\n", prefix, colorRed, colorReset)
+	}
+}
+
+func printQUICInitial(packet *fake.QUICInitial) {
+	fmt.Printf("  Version: %s\n", fake.QUICVersionName(packet.Version))
+	fmt.Printf("  Destination connection ID: %x\n", packet.DCID)
+	fmt.Printf("  Source connection ID: %x\n", packet.SCID)
+	fmt.Printf("  Token: %d bytes\n", len(packet.Token))
+	fmt.Printf("  Packet number: %d\n", packet.PacketNumber)
+	fmt.Printf("  Size: %d bytes", packet.Size)
+	if packet.Trailing > 0 {
+		fmt.Printf(", %d bytes after packet", packet.Trailing)
+	}
+	fmt.Println()
+
+	var frames []string
+	for name, count := range packet.Frames {
+		frames = append(frames, fmt.Sprintf("%s x%d", name, count))
+	}
+	sort.Strings(frames)
+	fmt.Printf("  Frames: %s\n", orNone(frames))
+	fmt.Printf("  CRYPTO data: %d bytes at offset %d\n", len(packet.Crypto), packet.CryptoOffset)
+
+	if packet.ClientHello == nil {
+		if packet.CryptoOffset > 0 {
+			fmt.Println("  Packet carries continuation of ClientHello from previous packet")
+		}
+		return
+	}
+	fmt.Println("  ClientHello:")
+	printClientHello(packet.ClientHello, "    ")
+}
+
+func names[T any](values []T, name func(T) string) string {
+	result := make([]string, len(values))
+	for i, v := range values {
+		result[i] = name(v)
+	}
+	return strings.Join(result, ", ")
+}
+
+func orNone(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	return strings.Join(values, ", ")
+}
+
+func indent(text, prefix string) string {
+	lines := strings.SplitAfter(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// findPayloads returns .bin files in payload folders
+func findPayloads() []string {
+	var payloads []string
+	for _, dir := range payloadDirs {
+		files, _ := filepath.Glob(filepath.Join(dir, "*.bin"))
+		sort.Strings(files)
+		payloads = append(payloads, files...)
+	}
+	return payloads
+}
+
+func runInspect(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	code := 0
+	for i, path := range args {
+		if i > 0 {
+			fmt.Println()
+		}
+		if err := inspect(path); err != nil {
+			fmt.Fprintf(os.Stderr, "%s%s: %v%s\n", colorRed, path, err, colorReset)
+			code = 1
+		}
+	}
+	return code
+}
+
+// runCheck lints payloads of pre-configs. Exit code is 1 when any
+// pre-config passes payload of wrong kind, warnings don't fail check.
+func runCheck(args []string) int {
+	dir := preconfigsDir
+	switch len(args) {
+	case 0:
+	case 1:
+		dir = args[0]
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	preconfigs, err := preconfig.LoadDir(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError reading pre-configs: %v%s\n", colorRed, err, colorReset)
+		return 1
+	}
+
+	errors := 0
+	for _, problem := range preconfig.LintPayloads(preconfigs) {
+		color := colorCyan
+		if problem.Severity == preconfig.Error {
+			color = colorRed
+			errors++
+		}
+		fmt.Printf("%s%s%s\n", color, problem, colorReset)
+	}
+	if errors > 0 {
+		fmt.Printf("\n%sFound %d payloads of wrong kind%s\n", colorRed, errors, colorReset)
+		return 1
+	}
+	fmt.Printf("%sPayloads of %d pre-configs match their options%s\n", colorGreen, len(preconfigs), colorReset)
+	return 0
+}
+
+func runInteractive() int {
+	reader := bufio.NewReader(os.Stdin)
+	defer func() {
+		fmt.Println("\nPress Enter to exit...")
+		reader.ReadString('\n')
+	}()
+
+	fmt.Printf("%sFake payload tool%s (version %s)\n", colorCyan, colorReset, version)
+	fmt.Println("\nSelect action:")
+	fmt.Println("1. Inspect fake payload")
+	fmt.Println("2. Generate fake payload")
+	for {
+		answer, err := prompt(reader, "\nEnter number of action", "1")
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return 1
+		}
+		switch answer {
+		case "1":
+			return inspectInteractive(reader)
+		case "2":
+			return generateInteractive(reader)
+		}
+		fmt.Println("Invalid selection. Please select number from 1 to 2")
+	}
+}
+
+func inspectInteractive(reader *bufio.Reader) int {
+	payloads := findPayloads()
+	if len(payloads) == 0 {
+		fmt.Printf("%sNo fake payloads found in %s%s\n", colorRed, binDir, colorReset)
+		return 1
+	}
+
+	fmt.Println("\nSelect fake payload to inspect:")
+	for i, path := range payloads {
+		fmt.Printf("%d. %s\n", i+1, path)
+	}
+	for {
+		fmt.Print("\nEnter number of payload: ")
+		answer, err := reader.ReadString('\n')
+		if err != nil {
+			fmt.Printf("Error reading input: %v\n", err)
+			return 1
+		}
+		var n int
+		if _, err := fmt.Sscan(strings.TrimSpace(answer), &n); err != nil || n < 1 || n > len(payloads) {
+			fmt.Printf("Invalid selection. Please select number from 1 to %d\n", len(payloads))
+			continue
+		}
+		fmt.Println()
+		if err := inspect(payloads[n-1]); err != nil {
+			fmt.Printf("%sError: %v%s\n", colorRed, err, colorReset)
+			return 1
+		}
+		return 0
+	}
+}
+
+func generateInteractive(reader *bufio.Reader) int {
+	// Reading stops at first error, it's checked once all answers are read
+	var options generateOptions
+	var err error
+	ask := func(question, defaultValue string) string {
+		if err != nil {
+			return ""
+		}
+		var answer string
+		answer, err = prompt(reader, question, defaultValue)
+		return answer
+	}
+
+	kind := ask("\nPayload type: 1 - TLS ClientHello, 2 - QUIC Initial", "1")
+	options.quic = kind == "2"
+	options.sni = ask("Domain to put in SNI (better one that isn't blocked by your ISP)", "www.google.com")
+	defaultALPN := "h2,http/1.1"
+	if options.quic {
+		defaultALPN = "h3"
+	}
+	options.alpn = ask("ALPN protocols, comma separated", defaultALPN)
+	pq := ask("Add post-quantum key share like current browsers? (y/n)", "n")
+	options.postQuantum = strings.EqualFold(pq, "y")
+	defaultSize := "0"
+	if options.quic {
+		defaultSize = fmt.Sprint(fake.MinQUICInitialSize)
+	}
+	size := ask("Target size in bytes (0 - without padding)", defaultSize)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	if _, err := fmt.Sscan(size, &options.size); err != nil || options.size < 0 {
+		fmt.Printf("%sInvalid size: %s%s\n", colorRed, size, colorReset)
+		return 1
+	}
+
+	options.output = defaultOutput(options.sni, options.quic, options.postQuantumGroup())
+	if _, err := os.Stat(options.output); err == nil {
+		answer := ask(fmt.Sprintf("File %s already exists. Overwrite? (y/n)", options.output), "n")
+		if err != nil || !strings.EqualFold(answer, "y") {
+			fmt.Println("Cancelled.")
+			return 1
+		}
+		options.force = true
+	}
+	return generate(options)
+}
+
+func prompt(reader *bufio.Reader, question, defaultValue string) (string, error) {
+	fmt.Printf("%s [%s]: ", question, defaultValue)
+	answer, err := reader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("error reading input: %v", err)
+	}
+	if answer = strings.TrimSpace(answer); answer == "" {
+		return defaultValue, nil
+	}
+	return answer, nil
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		os.Exit(runInteractive())
+	}
+	switch os.Args[1] {
+	case "inspect":
+		os.Exit(runInspect(os.Args[2:]))
+	case "generate":
+		os.Exit(runGenerate(os.Args[2:]))
+	case "check":
+		os.Exit(runCheck(os.Args[2:]))
+	case "-h", "-help", "--help", "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+}
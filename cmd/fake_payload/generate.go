@@ -0,0 +1,180 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ankddev/zapret-discord-youtube/internal/fake"
+)
+
+// generateOptions describes fake payload to generate
+type generateOptions struct {
+	sni         string
+	alpn        string
+	postQuantum bool
+	kyber       bool
+	quic        bool
+	// size is target size of ClientHello record for TLS and of datagram
+	// for QUIC
+	size   int
+	output string
+	force  bool
+}
+
+// postQuantumGroup returns hybrid key share group of payload, 0 without it
+func (o generateOptions) postQuantumGroup() uint16 {
+	switch {
+	case o.postQuantum:
+		return fake.GroupX25519MLKEM768
+	case o.kyber:
+		return fake.GroupX25519Kyber768Draft00
+	}
+	return 0
+}
+
+// defaultOutput names file like payloads shipped with fix, for example
+// bin/tls_clienthello_www_google_com.bin. Payloads with post-quantum key
+// share get suffix of its group.
+func defaultOutput(sni string, quic bool, group uint16) string {
+	prefix := "tls_clienthello_"
+	if quic {
+		prefix = "quic_initial_"
+	}
+	name := prefix + strings.NewReplacer(".", "_", "-", "_").Replace(strings.ToLower(sni))
+	switch group {
+	case fake.GroupX25519MLKEM768:
+		name += "_mlkem"
+	case fake.GroupX25519Kyber768Draft00:
+		name += "_kyber"
+	}
+	return filepath.Join(binDir, name+".bin")
+}
+
+// binPath returns path of file relative to bin folder as pre-configs refer
+// to it after %BIN%. False is returned for files outside of bin folder.
+func binPath(path string) (string, bool) {
+	bin, err1 := filepath.Abs(binDir)
+	file, err2 := filepath.Abs(path)
+	if err1 != nil || err2 != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(bin, file)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	// Pre-configs are run by cmd, which separates folders with backslash
+	return strings.ReplaceAll(rel, string(filepath.Separator), `\`), true
+}
+
+// generate builds fake payload, saves it and shows what it contains
+func generate(options generateOptions) int {
+	if _, err := os.Stat(options.output); err == nil && !options.force {
+		fmt.Fprintf(os.Stderr, "%sFile %s already exists, use -force to overwrite%s\n", colorRed, options.output, colorReset)
+		return 1
+	}
+
+	spec := fake.HelloSpec{
+		SNI:         options.sni,
+		ALPN:        splitList(options.alpn),
+		PostQuantum: options.postQuantum,
+		Kyber:       options.kyber,
+		QUIC:        options.quic,
+	}
+	// Size of QUIC payload is reached with PADDING frames, not extension
+	if !options.quic {
+		spec.Size = options.size
+	}
+	hello, err := fake.BuildClientHello(spec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError building ClientHello: %v%s\n", colorRed, err, colorReset)
+		return 1
+	}
+
+	data := fake.TLSRecord(hello)
+	if options.quic {
+		data, err = fake.BuildQUICInitial(hello, options.size)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%sError building QUIC Initial: %v%s\n", colorRed, err, colorReset)
+			return 1
+		}
+	}
+
+	if err := os.WriteFile(options.output, data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "%sError saving payload: %v%s\n", colorRed, err, colorReset)
+		return 1
+	}
+
+	// Decode saved file back, so broken payload is noticed right away
+	if err := inspect(options.output); err != nil {
+		fmt.Fprintf(os.Stderr, "%sGenerated payload can't be decoded: %v%s\n", colorRed, err, colorReset)
+		return 1
+	}
+
+	option := "dpi-desync-fake-tls"
+	if options.quic {
+		option = "dpi-desync-fake-quic"
+	}
+	rel, ok := binPath(options.output)
+	if !ok {
+		fmt.Printf("\n%sPayload saved. Move it to %s folder to use it in pre-configs.%s\n", colorGreen, binDir, colorReset)
+		return 0
+	}
+	fmt.Printf("\n%sPayload saved. Use it in pre-config as --%s=\"%%BIN%%%s\"%s\n",
+		colorGreen, option, rel, colorReset)
+	return 0
+}
+
+func runGenerate(args []string) int {
+	var options generateOptions
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	fs.StringVar(&options.sni, "sni", "", "domain to put in SNI, better one that isn't blocked by your ISP")
+	fs.StringVar(&options.alpn, "alpn", "", "comma separated ALPN protocols (default \"h2,http/1.1\" for TLS and \"h3\" for QUIC)")
+	fs.BoolVar(&options.postQuantum, "pq", false, "add X25519MLKEM768 post-quantum key share like current browsers")
+	fs.BoolVar(&options.kyber, "kyber", false, "add older X25519Kyber768Draft00 key share instead of ML-KEM")
+	fs.BoolVar(&options.quic, "quic", false, "build QUIC Initial packet instead of TLS ClientHello")
+	fs.IntVar(&options.size, "size", 0, "target size in bytes: ClientHello is padded to it, QUIC packet is at least 1200 bytes")
+	fs.StringVar(&options.output, "o", "", "file to save payload to (default bin/tls_clienthello_<sni>.bin or bin/quic_initial_<sni>.bin)")
+	fs.BoolVar(&options.force, "force", false, "overwrite existing file")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "unexpected arguments: %s\n", strings.Join(fs.Args(), " "))
+		return 2
+	}
+	if options.sni == "" {
+		fmt.Fprintln(os.Stderr, "SNI is required, use -sni")
+		return 2
+	}
+	if options.postQuantum && options.kyber {
+		fmt.Fprintln(os.Stderr, "-pq and -kyber can't be used together")
+		return 2
+	}
+	if options.size < 0 {
+		fmt.Fprintln(os.Stderr, "size must not be negative")
+		return 2
+	}
+	if options.alpn == "" {
+		options.alpn = "h2,http/1.1"
+		if options.quic {
+			options.alpn = "h3"
+		}
+	}
+	if options.output == "" {
+		options.output = defaultOutput(options.sni, options.quic, options.postQuantumGroup())
+	}
+	return generate(options)
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
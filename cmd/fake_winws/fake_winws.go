@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ankddev/zapret-discord-youtube/internal/preconfig"
+)
+
+// Environment variables that script fake winws
+const (
+	// envMode is behaviour for every run: start, crash, crash:DURATION or hang
+	envMode = "FAKE_WINWS_MODE"
+	// envScript is file with "pattern mode" lines, first line whose pattern
+	// is found in command line sets behaviour, "*" matches any
+	envScript = "FAKE_WINWS_SCRIPT"
+	// envLog is file every run is appended to as JSON line
+	envLog = "FAKE_WINWS_LOG"
+)
+
+// Behaviours of fake winws
+const (
+	modeStart = "start"
+	modeCrash = "crash"
+	modeHang  = "hang"
+)
+
+// Option is parsed option of command line
+type Option struct {
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+}
+
+// Run is record of one run in log
+type Run struct {
+	Time     time.Time  `json:"time"`
+	PID      int        `json:"pid"`
+	Dir      string     `json:"dir"`
+	Args     []string   `json:"args"`
+	Global   []Option   `json:"global"`
+	Profiles [][]Option `json:"profiles"`
+	Mode     string     `json:"mode"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// parseArgs splits command line into global options and profiles, checking
+// every option like winws does
+func parseArgs(args []string) (global []Option, profiles [][]Option, err error) {
+	var profile []Option
+	hasFilter := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") {
+			return nil, nil, fmt.Errorf("unexpected argument: %s", arg)
+		}
+		name, value, hasValue := strings.Cut(arg[2:], "=")
+		spec, ok := preconfig.LookupOption(name)
+		if !ok {
+			return nil, nil, fmt.Errorf("unrecognized option '--%s'", name)
+		}
+
+		switch spec.Arg {
+		case preconfig.ArgNone:
+			if hasValue {
+				return nil, nil, fmt.Errorf("option '--%s' doesn't allow an argument", name)
+			}
+		case preconfig.ArgRequired:
+			// getopt takes value from next argument too
+			if !hasValue && i+1 < len(args) && !strings.HasPrefix(args[i+1], "--") {
+				value, hasValue = args[i+1], true
+				i++
+			}
+			if !hasValue || value == "" {
+				return nil, nil, fmt.Errorf("option '--%s' requires an argument", name)
+			}
+		}
+		if hasValue {
+			if err := preconfig.ValidateValue(name, value); err != nil {
+				return nil, nil, fmt.Errorf("--%s: %v", name, err)
+			}
+			if spec.File && !isInline(value) {
+				if _, err := os.Stat(localPath(value)); err != nil {
+					return nil, nil, fmt.Errorf("--%s: could not read %s", name, value)
+				}
+			}
+		}
+
+		switch {
+		case name == "new":
+			if len(profile) == 0 {
+				return nil, nil, fmt.Errorf("empty profile before --new")
+			}
+			profiles = append(profiles, profile)
+			profile = nil
+		case preconfig.IsGlobal(name):
+			if strings.HasPrefix(name, "wf-") && name != "wf-l3" && name != "wf-save" {
+				hasFilter = true
+			}
+			global = append(global, Option{name, value})
+		default:
+			profile = append(profile, Option{name, value})
+		}
+	}
+	if len(profile) > 0 {
+		profiles = append(profiles, profile)
+	}
+	if !hasFilter {
+		return nil, nil, fmt.Errorf("windivert filter isn't set, use --wf-tcp, --wf-udp or --wf-raw")
+	}
+	return global, profiles, nil
+}
+
+// isInline reports whether value of payload option is data, not file
+func isInline(value string) bool {
+	return strings.HasPrefix(value, "0x") || strings.HasPrefix(value, "!")
+}
+
+// localPath makes Windows path of pre-config usable on this system
+func localPath(path string) string {
+	if runtime.GOOS == "windows" {
+		return path
+	}
+	return filepath.FromSlash(strings.ReplaceAll(path, `\`, "/"))
+}
+
+// scriptedMode returns behaviour for command line
+func scriptedMode(args []string) (string, error) {
+	mode := os.Getenv(envMode)
+	if path := os.Getenv(envScript); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return "", fmt.Errorf("error opening script: %v", err)
+		}
+		defer file.Close()
+
+		commandLine := strings.Join(args, " ")
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			index := strings.LastIndex(line, " ")
+			if index < 0 {
+				return "", fmt.Errorf("invalid script line: %s", line)
+			}
+			pattern, lineMode := strings.TrimSpace(line[:index]), line[index+1:]
+			if pattern == "*" || strings.Contains(commandLine, pattern) {
+				mode = lineMode
+				break
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return "", fmt.Errorf("error reading script: %v", err)
+		}
+	}
+	if mode == "" {
+		mode = modeStart
+	}
+	return mode, nil
+}
+
+// writeLog appends run to log file
+func writeLog(run Run) {
+	path := os.Getenv(envLog)
+	if path == "" {
+		return
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fake winws: error opening log: %v\n", err)
+		return
+	}
+	defer file.Close()
+	line, _ := json.Marshal(run)
+	file.Write(append(line, '\n'))
+}
+
+func main() {
+	args := os.Args[1:]
+	dir, _ := os.Getwd()
+	run := Run{Time: time.Now(), PID: os.Getpid(), Dir: dir, Args: args}
+
+	global, profiles, err := parseArgs(args)
+	if err == nil {
+		run.Mode, err = scriptedMode(args)
+	}
+	run.Global, run.Profiles = global, profiles
+	if err != nil {
+		run.Error = err.Error()
+		writeLog(run)
+		fmt.Fprintf(os.Stderr, "fake winws: %v\n", err)
+		os.Exit(1)
+	}
+	writeLog(run)
+	fmt.Printf("fake winws: %d profiles, mode %s\n", len(profiles), run.Mode)
+
+	mode, argument, _ := strings.Cut(run.Mode, ":")
+	switch mode {
+	case modeStart:
+		fmt.Println("windivert initialized. capture is started.")
+		waitSignal()
+	case modeCrash:
+		// Crash without delay looks like WinDivert failing to start
+		if argument != "" {
+			delay, err := time.ParseDuration(argument)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "fake winws: invalid crash delay '%s'\n", argument)
+				os.Exit(1)
+			}
+			fmt.Println("windivert initialized. capture is started.")
+			time.Sleep(delay)
+		}
+		fmt.Fprintln(os.Stderr, "windivert: error opening filter: Access is denied. (5)")
+		os.Exit(1)
+	case modeHang:
+		// Hung winws doesn't react to anything but kill
+		signal.Ignore(os.Interrupt, syscall.SIGTERM)
+		for {
+			time.Sleep(time.Hour)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "fake winws: unknown mode '%s'\n", run.Mode)
+		os.Exit(1)
+	}
+}
+
+// waitSignal blocks until winws is asked to stop
+func waitSignal() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	<-c
+	fmt.Println("fake winws: stopping")
+}
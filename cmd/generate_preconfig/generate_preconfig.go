@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ankddev/zapret-discord-youtube/internal/blockcheck"
+	"github.com/ankddev/zapret-discord-youtube/internal/preconfig"
+)
+
+const (
+	// Colors
+	colorReset = "\033[0m"
+	colorRed   = "\033[31m"
+	colorGreen = "\033[32m"
+	colorCyan  = "\033[36m"
+
+	defaultLog  = "blockcheck.log"
+	defaultList = "list-ultimate.txt"
+	defaultName = "CustomFix (Blockcheck)"
+
+	preconfigsDir = "pre-configs"
+	listsDir      = "lists"
+	binDir        = "bin"
+)
+
+// Fake payloads shipped in bin folder, used when strategy sends fakes but
+// doesn't set payload
+const (
+	fakeTLS  = "tls_clienthello_www_google_com.bin"
+	fakeQUIC = "quic_initial_www_google_com.bin"
+)
+
+// Version is set during build
+var version string
+
+// profileSpec describes profile of pre-config for group of blockcheck protocols
+type profileSpec struct {
+	name      string
+	protocols []blockcheck.Protocol
+	filter    preconfig.Option
+	fakeName  string
+	fakeFile  string
+}
+
+// TLS 1.2 and TLS 1.3 share port, so single strategy is chosen for both,
+// preferring one that works for both versions
+var profileSpecs = []profileSpec{
+	{
+		name:      "HTTP",
+		protocols: []blockcheck.Protocol{blockcheck.HTTP},
+		filter:    preconfig.NewOption("filter-tcp", "80"),
+	},
+	{
+		name:      "HTTPS",
+		protocols: []blockcheck.Protocol{blockcheck.TLS12, blockcheck.TLS13},
+		filter:    preconfig.NewOption("filter-tcp", "443"),
+		fakeName:  "dpi-desync-fake-tls",
+		fakeFile:  fakeTLS,
+	},
+	{
+		name:      "QUIC",
+		protocols: []blockcheck.Protocol{blockcheck.QUIC},
+		filter:    preconfig.NewOption("filter-udp", "443"),
+		fakeName:  "dpi-desync-fake-quic",
+		fakeFile:  fakeQUIC,
+	},
+}
+
+// generate builds pre-config from blockcheck results. It returns description
+// of chosen strategies to show to user.
+func generate(log *blockcheck.Log, list string) (preconfig.Template, []string, error) {
+	template := preconfig.Template{
+		List: list,
+		Comments: []string{
+			fmt.Sprintf("Generated from blockcheck.log on %s", time.Now().Format("2006-01-02")),
+		},
+		Tags: []string{"custom", "blockcheck"},
+	}
+
+	var chosen []string
+	for _, spec := range profileSpecs {
+		choice, ok := log.Choose(spec.protocols...)
+		if !ok {
+			continue
+		}
+
+		profile := preconfig.Profile{Options: []preconfig.Option{spec.filter, preconfig.HostlistOption()}}
+		profile.Options = append(profile.Options, strategyOptions(choice.Strategy)...)
+		if spec.fakeName != "" && usesFake(profile) && !profile.Has(spec.fakeName) {
+			profile.Options = append(profile.Options, preconfig.QuotedOption(spec.fakeName, "%BIN%"+spec.fakeFile))
+		}
+		template.Profiles = append(template.Profiles, profile)
+
+		description := fmt.Sprintf("%s: %s (works for %s)", spec.name, choice.Strategy, strings.Join(choice.Domains, ", "))
+		template.Comments = append(template.Comments, description)
+		chosen = append(chosen, description)
+	}
+
+	if len(template.Profiles) == 0 {
+		return template, nil, errors.New("blockcheck didn't find any working strategy")
+	}
+	return template, chosen, nil
+}
+
+// strategyOptions converts blockcheck arguments to pre-config options. Fake
+// payloads blockcheck used from its own folder are taken from bin folder
+// when fix ships them, from blockcheck folder otherwise.
+func strategyOptions(strategy blockcheck.Strategy) []preconfig.Option {
+	var options []preconfig.Option
+	for _, arg := range strategy.Args {
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		switch {
+		case !hasValue:
+			options = append(options, preconfig.NewFlag(name))
+		case strings.Contains(value, "/files/fake/"):
+			options = append(options, preconfig.QuotedOption(name, fakePath(path.Base(value))))
+		default:
+			options = append(options, preconfig.NewOption(name, value))
+		}
+	}
+	return options
+}
+
+func fakePath(name string) string {
+	if _, err := os.Stat(filepath.Join(binDir, name)); err == nil {
+		return "%BIN%" + name
+	}
+	return `%BIN%blockcheck\zapret\files\fake\` + name
+}
+
+// Desync modes that send fake packets with payload. Fakes of fakedsplit and
+// fakeddisorder and data of syndata default to payload of protocol too.
+var fakeModes = map[string]bool{"fake": true, "fakedsplit": true, "fakeddisorder": true, "syndata": true}
+
+// usesFake reports whether profile sends fake packets with payload
+func usesFake(profile preconfig.Profile) bool {
+	desync, _ := profile.Get("dpi-desync")
+	for _, mode := range strings.Split(desync, ",") {
+		if fakeModes[mode] {
+			return true
+		}
+	}
+	return false
+}
+
+// getLists returns hostlists in lists folder
+func getLists() ([]string, error) {
+	files, err := os.ReadDir(listsDir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading lists directory: %v", err)
+	}
+	var lists []string
+	for _, f := range files {
+		if !f.IsDir() && strings.HasPrefix(f.Name(), "list-") && strings.HasSuffix(f.Name(), ".txt") {
+			lists = append(lists, f.Name())
+		}
+	}
+	sort.Strings(lists)
+	return lists, nil
+}
+
+func printLog(log *blockcheck.Log) {
+	fmt.Println("\nBlockcheck results:")
+	for _, r := range log.Results {
+		color := colorRed
+		switch r.Status {
+		case blockcheck.Working, blockcheck.NoBypassNeeded:
+			color = colorGreen
+		}
+		fmt.Printf("%s  ipv%d %s %s: %s%s\n", color, r.IPVersion, r.Domain, r.Protocol, r.Status, colorReset)
+	}
+	if !log.HasSummary {
+		fmt.Printf("%sBlockcheck didn't finish, results are taken from strategies found before it was stopped.%s\n", colorRed, colorReset)
+	}
+}
+
+func prompt(reader *bufio.Reader, question, defaultValue string) (string, error) {
+	fmt.Printf("%s [%s]: ", question, defaultValue)
+	answer, err := reader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("error reading input: %v", err)
+	}
+	if answer = strings.TrimSpace(answer); answer == "" {
+		return defaultValue, nil
+	}
+	return answer, nil
+}
+
+func chooseList(reader *bufio.Reader) (string, error) {
+	lists, err := getLists()
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Println("\nSelect hostlist for pre-config:")
+	defaultNumber := "1"
+	for i, list := range lists {
+		fmt.Printf("%d. %s\n", i+1, list)
+		if list == defaultList {
+			defaultNumber = fmt.Sprint(i + 1)
+		}
+	}
+
+	for {
+		answer, err := prompt(reader, "\nEnter number of list", defaultNumber)
+		if err != nil {
+			return "", err
+		}
+		var n int
+		if _, err := fmt.Sscan(answer, &n); err == nil && n >= 1 && n <= len(lists) {
+			return lists[n-1], nil
+		}
+		fmt.Printf("Invalid selection. Please select number from 1 to %d\n", len(lists))
+	}
+}
+
+func runInteractive() int {
+	reader := bufio.NewReader(os.Stdin)
+	defer func() {
+		fmt.Println("\nPress Enter to exit...")
+		reader.ReadString('\n')
+	}()
+
+	fmt.Printf("%sCreate pre-config from blockcheck results%s (version %s)\n", colorCyan, colorReset, version)
+	fmt.Println("Run blockcheck.cmd first, it saves results to blockcheck.log.")
+
+	logPath, err := prompt(reader, "\nPath to blockcheck log", defaultLog)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	log, err := blockcheck.Load(logPath)
+	if err != nil {
+		fmt.Printf("%sError reading blockcheck log: %v%s\n", colorRed, err, colorReset)
+		return 1
+	}
+	printLog(log)
+
+	list, err := chooseList(reader)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	template, chosen, err := generate(log, list)
+	if err != nil {
+		fmt.Printf("%s%v%s\n", colorRed, err, colorReset)
+		return 1
+	}
+
+	name, err := prompt(reader, "\nName of pre-config", defaultName)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+
+	overwrite := false
+	if _, err := os.Stat(filepath.Join(preconfigsDir, name+".bat")); err == nil {
+		answer, err := prompt(reader, fmt.Sprintf("Pre-config %s already exists. Overwrite? (y/n)", name), "n")
+		if err != nil || !strings.EqualFold(answer, "y") {
+			fmt.Println("Cancelled.")
+			return 1
+		}
+		overwrite = true
+	}
+
+	return save(template, name, overwrite, chosen)
+}
+
+func save(template preconfig.Template, name string, overwrite bool, chosen []string) int {
+	template.Title = name
+	path, err := template.Save(preconfigsDir, name, overwrite)
+	if err != nil {
+		fmt.Printf("%s%v%s\n", colorRed, err, colorReset)
+		return 1
+	}
+
+	fmt.Println("\nChosen strategies:")
+	for _, description := range chosen {
+		fmt.Printf("  %s\n", description)
+	}
+	fmt.Printf("\n%sPre-config saved to %s. Run it with \"Run pre-config\" or add it to autorun.%s\n", colorGreen, path, colorReset)
+	return 0
+}
+
+func runCLI(args []string) int {
+	fs := flag.NewFlagSet("generate_preconfig", flag.ContinueOnError)
+	logPath := fs.String("log", defaultLog, "blockcheck log to take results from")
+	list := fs.String("list", defaultList, "hostlist from lists folder to apply pre-config to")
+	name := fs.String("name", defaultName, "name of pre-config to create")
+	force := fs.Bool("force", false, "overwrite existing pre-config")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "unexpected arguments: %s\n", strings.Join(fs.Args(), " "))
+		return 2
+	}
+	if _, err := os.Stat(filepath.Join(listsDir, *list)); err != nil {
+		fmt.Fprintf(os.Stderr, "hostlist %s not found in %s\n", *list, listsDir)
+		return 2
+	}
+
+	log, err := blockcheck.Load(*logPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading blockcheck log: %v\n", err)
+		return 1
+	}
+	template, chosen, err := generate(log, *list)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return save(template, *name, *force, chosen)
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1:]))
+	}
+	os.Exit(runInteractive())
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ankddev/zapret-discord-youtube/internal/blockcheck"
+	"github.com/ankddev/zapret-discord-youtube/internal/preconfig"
+)
+
+// TestGenerate renders pre-config from blockcheck log and parses it back, as
+// tools read it after it's saved
+func TestGenerate(t *testing.T) {
+	log, err := blockcheck.Load(filepath.Join("..", "..", "internal", "blockcheck", "testdata", "complete.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template, chosen, err := generate(log, "list-general.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chosen) != 3 {
+		t.Errorf("chosen strategies %q, want one for HTTP, HTTPS and QUIC", chosen)
+	}
+
+	p, err := preconfig.Parse("CustomFix (Blockcheck).bat", strings.NewReader(template.Render()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.HasTag("blockcheck") {
+		t.Errorf("tags %v, want blockcheck", p.Tags)
+	}
+	for name, want := range map[string]string{"wf-tcp": "80,443", "wf-udp": "443"} {
+		if value, _ := p.GetGlobal(name); value != want {
+			t.Errorf("--%s=%s, want %s", name, value, want)
+		}
+	}
+
+	tests := []struct {
+		filter  string
+		desync  string
+		options map[string]string
+		// missing are options profile must not have
+		missing []string
+	}{
+		{
+			filter:  "filter-tcp=80",
+			desync:  "split2",
+			missing: []string{"wf-l3", "dpi-desync-fake-tls", "dpi-desync-fake-http"},
+		},
+		{
+			filter: "filter-tcp=443",
+			desync: "fake,split2",
+			options: map[string]string{
+				"dpi-desync-autottl":  "2",
+				"dpi-desync-fooling":  "md5sig",
+				"dpi-desync-fake-tls": "%BIN%" + fakeTLS,
+			},
+			missing: []string{"wf-l3"},
+		},
+		{
+			filter:  "filter-udp=443",
+			desync:  "fake",
+			options: map[string]string{"dpi-desync-fake-quic": "%BIN%" + fakeQUIC},
+			missing: []string{"wf-l3"},
+		},
+	}
+	if len(p.Profiles) != len(tests) {
+		t.Fatalf("pre-config has %d profiles, want %d", len(p.Profiles), len(tests))
+	}
+	for i, test := range tests {
+		profile := p.Profiles[i]
+		filter, value, _ := strings.Cut(test.filter, "=")
+		if got, _ := profile.Get(filter); got != value {
+			t.Errorf("profile %d: --%s=%s, want %s", i, filter, got, value)
+		}
+		if hostlist, _ := profile.Get("hostlist"); hostlist != `%~dp0..\lists\list-general.txt` {
+			t.Errorf("profile %d: hostlist %s", i, hostlist)
+		}
+		if desync, _ := profile.Get("dpi-desync"); desync != test.desync {
+			t.Errorf("profile %d: --dpi-desync=%s, want %s", i, desync, test.desync)
+		}
+		for name, want := range test.options {
+			// Parser expands variables in values
+			if got, _ := profile.Get(name); got != p.Expand(want) {
+				t.Errorf("profile %d: --%s=%s, want %s", i, name, got, want)
+			}
+		}
+		for _, name := range test.missing {
+			if profile.Has(name) {
+				t.Errorf("profile %d has --%s", i, name)
+			}
+		}
+	}
+}
+
+func TestUsesFake(t *testing.T) {
+	tests := []struct {
+		desync string
+		want   bool
+	}{
+		{"fake", true},
+		{"fake,split2", true},
+		{"fakedsplit", true},
+		{"fakeddisorder", true},
+		{"syndata", true},
+		{"syndata,multidisorder", true},
+		{"split2", false},
+		{"multisplit", false},
+		{"fakeknown", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		profile := preconfig.Profile{Options: []preconfig.Option{preconfig.NewOption("dpi-desync", test.desync)}}
+		if got := usesFake(profile); got != test.want {
+			t.Errorf("usesFake(--dpi-desync=%s) = %v, want %v", test.desync, got, test.want)
+		}
+	}
+}
+
+// TestGenerateFakedsplit adds payload to strategies sending fakes with modes
+// other than fake
+func TestGenerateFakedsplit(t *testing.T) {
+	summary := `* SUMMARY
+ipv4 discord.com curl_test_https_tls12 : winws --wf-l3=ipv4 --wf-tcp=443 --dpi-desync=fakedsplit --dpi-desync-fooling=md5sig
+ipv4 discord.com curl_test_https_tls13 : winws --wf-l3=ipv4 --wf-tcp=443 --dpi-desync=fakedsplit --dpi-desync-fooling=md5sig
+`
+	log, err := blockcheck.Parse(strings.NewReader(summary))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template, _, err := generate(log, "list-general.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(template.Profiles) != 1 {
+		t.Fatalf("generated %d profiles, want 1", len(template.Profiles))
+	}
+	if value, _ := template.Profiles[0].Get("dpi-desync-fake-tls"); value != "%BIN%"+fakeTLS {
+		t.Errorf("--dpi-desync-fake-tls=%s, want %%BIN%%%s", value, fakeTLS)
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ankddev/zapret-discord-youtube/internal/pcap"
+)
+
+// Verdict is kind of interference found in flow
+type Verdict string
+
+const (
+	VerdictOK            Verdict = "connection works"
+	VerdictNoSYNACK      Verdict = "server doesn't answer SYN, IP address is blocked"
+	VerdictRSTInjection  Verdict = "RST injected by DPI"
+	VerdictNoServerHello Verdict = "blackhole after ClientHello"
+	VerdictHTTPStub      Verdict = "HTTP redirect to stub page injected"
+	VerdictMidStream     Verdict = "blackhole in the middle of stream"
+	VerdictQUICBlocked   Verdict = "QUIC Initial is dropped"
+	VerdictInconclusive  Verdict = "not enough packets to tell"
+)
+
+// Thresholds of anomalies. Route to server may change by a hop or two during
+// connection, DPI is usually much closer to client than server.
+const (
+	ttlTolerance = 2
+	// IP ID of server grows with every packet it sends, jump larger than this
+	// means packet came from another host
+	ipidTolerance = 1000
+	// Client retransmitting for this long without answer means blackhole
+	stallTimeout = time.Second
+)
+
+// Diagnosis is result of flow analysis
+type Diagnosis struct {
+	Verdict Verdict
+	// Evidence explains verdict, one fact per line
+	Evidence []string
+}
+
+func (d *Diagnosis) add(format string, args ...any) {
+	d.Evidence = append(d.Evidence, fmt.Sprintf(format, args...))
+}
+
+// diagnose classifies flow by typical signatures of DPI
+func diagnose(f *Flow) Diagnosis {
+	if f.Proto == pcap.ProtoUDP {
+		return diagnoseUDP(f)
+	}
+	return diagnoseTCP(f)
+}
+
+func diagnoseTCP(f *Flow) Diagnosis {
+	var d Diagnosis
+	var syn, synAck, lastServer *pcap.Packet
+	var request *pcap.Packet
+	var rsts []*pcap.Packet
+	var serverData []*pcap.Packet
+	clientEnd := uint32(0)
+	retransmits := 0
+	var lastRetransmit time.Time
+	closed := false
+
+	for _, p := range f.Packets {
+		if f.FromClient(p) {
+			switch {
+			case p.Has(pcap.FlagSYN) && !p.Has(pcap.FlagACK):
+				syn = p
+			case len(p.Payload) > 0:
+				end := p.Seq + uint32(len(p.Payload))
+				if request == nil {
+					request = p
+					clientEnd = end
+				} else if int32(end-clientEnd) <= 0 {
+					retransmits++
+					lastRetransmit = p.Time
+				} else {
+					clientEnd = end
+				}
+			}
+			if p.Has(pcap.FlagFIN) || p.Has(pcap.FlagRST) {
+				closed = true
+			}
+			continue
+		}
+
+		switch {
+		case p.Has(pcap.FlagSYN | pcap.FlagACK):
+			synAck = p
+			lastServer = p
+		case p.Has(pcap.FlagRST):
+			rsts = append(rsts, p)
+		default:
+			if len(p.Payload) > 0 {
+				serverData = append(serverData, p)
+			}
+			if p.Has(pcap.FlagFIN) {
+				closed = true
+			}
+			lastServer = p
+		}
+	}
+
+	if syn != nil && synAck == nil {
+		if len(rsts) > 0 {
+			d.Verdict = VerdictNoSYNACK
+			d.add("server answered SYN with RST, TTL %d", rsts[0].TTL)
+			return d
+		}
+		d.Verdict = VerdictNoSYNACK
+		d.add("SYN sent %d times without answer", countSYN(f))
+		return d
+	}
+
+	// Packets of server define normal TTL and IP ID
+	reference := synAck
+	if reference == nil && len(serverData) > 0 {
+		reference = serverData[0]
+	}
+
+	var rtt time.Duration
+	if syn != nil && synAck != nil {
+		rtt = synAck.Time.Sub(syn.Time)
+	}
+
+	for _, rst := range rsts {
+		anomalies := packetAnomalies(rst, reference, lastServerBefore(f, rst))
+		if request != nil && rtt > 0 {
+			if delay := rst.Time.Sub(request.Time); delay >= 0 && delay < rtt/2 {
+				anomalies = append(anomalies, fmt.Sprintf("RST came %s after request, faster than half of handshake RTT %s",
+					delay.Round(time.Microsecond), rtt.Round(time.Microsecond)))
+			}
+		}
+		if dataAfter(serverData, rst) {
+			anomalies = append(anomalies, "server kept sending data after RST, so server didn't send it")
+		}
+		if len(anomalies) > 0 {
+			d.Verdict = VerdictRSTInjection
+			d.Evidence = append(d.Evidence, anomalies...)
+			return d
+		}
+	}
+
+	if f.HTTP && len(serverData) > 0 {
+		if evidence, ok := httpStub(f, serverData, reference); ok {
+			d.Verdict = VerdictHTTPStub
+			d.Evidence = evidence
+			return d
+		}
+	}
+
+	if request == nil {
+		if len(rsts) > 0 {
+			d.Verdict = VerdictInconclusive
+			d.add("connection reset before client sent data")
+			return d
+		}
+		d.Verdict = VerdictInconclusive
+		d.add("client didn't send any data")
+		return d
+	}
+
+	if len(serverData) == 0 {
+		if len(rsts) > 0 {
+			d.Verdict = VerdictRSTInjection
+			d.add("connection reset right after request, before any answer")
+			return d
+		}
+		if retransmits > 0 || f.Packets[len(f.Packets)-1].Time.Sub(request.Time) > stallTimeout {
+			d.Verdict = VerdictNoServerHello
+			what := "ClientHello"
+			if f.HTTP {
+				what = "HTTP request"
+			}
+			d.add("%s sent, server didn't answer for %s", what, f.Packets[len(f.Packets)-1].Time.Sub(request.Time).Round(time.Millisecond))
+			if retransmits > 0 {
+				d.add("client retransmitted data %d times", retransmits)
+			}
+			if synAck != nil {
+				d.add("TCP handshake completed, so IP address isn't blocked")
+			}
+			return d
+		}
+		d.Verdict = VerdictInconclusive
+		d.add("capture ends right after request")
+		return d
+	}
+
+	_, received := f.Bytes()
+	lastData := serverData[len(serverData)-1]
+	if !closed && retransmits > 0 && lastRetransmit.After(lastData.Time) && lastRetransmit.Sub(lastData.Time) > stallTimeout {
+		d.Verdict = VerdictMidStream
+		d.add("server sent %d bytes, then stopped answering", received)
+		d.add("client retransmitted data for %s after last answer", lastRetransmit.Sub(lastData.Time).Round(time.Millisecond))
+		if received >= 14*1024 && received <= 24*1024 {
+			d.add("connection froze after about 16 KB, which is typical for TSPU")
+		}
+		return d
+	}
+
+	d.Verdict = VerdictOK
+	d.add("server sent %d bytes", received)
+	if lastServer != nil && lastServer.Has(pcap.FlagFIN) {
+		d.add("server closed connection normally")
+	}
+	return d
+}
+
+func diagnoseUDP(f *Flow) Diagnosis {
+	var d Diagnosis
+	sent, received := 0, 0
+	var lastClient, lastServer time.Time
+	for _, p := range f.Packets {
+		if f.FromClient(p) {
+			sent++
+			lastClient = p.Time
+		} else {
+			received++
+			lastServer = p.Time
+		}
+	}
+
+	switch {
+	case received == 0 && f.TLS:
+		d.Verdict = VerdictQUICBlocked
+		d.add("client sent %d packets, server didn't answer", sent)
+		d.add("browser falls back to TCP, so site may still open slowly")
+	case received == 0:
+		d.Verdict = VerdictInconclusive
+		d.add("client sent %d packets without answer", sent)
+	case lastClient.Sub(lastServer) > stallTimeout && f.Duration() > 2*stallTimeout:
+		d.Verdict = VerdictMidStream
+		d.add("server answered %d packets, then stopped while client kept sending for %s",
+			received, lastClient.Sub(lastServer).Round(time.Millisecond))
+	default:
+		d.Verdict = VerdictOK
+		d.add("client sent %d packets, server sent %d", sent, received)
+	}
+	return d
+}
+
+// packetAnomalies compares packet, which claims to come from server, with real
+// packets of server
+func packetAnomalies(p, reference, previous *pcap.Packet) []string {
+	var anomalies []string
+	if reference == nil {
+		return nil
+	}
+	if diff := int(p.TTL) - int(reference.TTL); diff > ttlTolerance || diff < -ttlTolerance {
+		anomalies = append(anomalies, fmt.Sprintf("TTL %d differs from TTL %d of server", p.TTL, reference.TTL))
+	}
+	if p.Src.Is4() && previous != nil && previous.IPID != 0 {
+		if p.IPID == 0 {
+			anomalies = append(anomalies, "IP ID is 0 while server packets have it set")
+		} else if distance := p.IPID - previous.IPID; distance > ipidTolerance && -distance > ipidTolerance {
+			anomalies = append(anomalies, fmt.Sprintf("IP ID %d doesn't follow IP ID %d of previous server packet", p.IPID, previous.IPID))
+		}
+	}
+	return anomalies
+}
+
+// lastServerBefore returns last non-RST packet of server before given one
+func lastServerBefore(f *Flow, target *pcap.Packet) *pcap.Packet {
+	var last *pcap.Packet
+	for _, p := range f.Packets {
+		if p == target {
+			break
+		}
+		if !f.FromClient(p) && !p.Has(pcap.FlagRST) {
+			last = p
+		}
+	}
+	return last
+}
+
+func dataAfter(serverData []*pcap.Packet, rst *pcap.Packet) bool {
+	for _, p := range serverData {
+		if p.Time.After(rst.Time) {
+			return true
+		}
+	}
+	return false
+}
+
+func countSYN(f *Flow) int {
+	count := 0
+	for _, p := range f.Packets {
+		if f.FromClient(p) && p.Has(pcap.FlagSYN) {
+			count++
+		}
+	}
+	return count
+}
+
+// httpStub recognizes redirect DPI injects instead of answer of server: it
+// leads to other host and often differs from server packets by TTL
+func httpStub(f *Flow, serverData []*pcap.Packet, reference *pcap.Packet) ([]string, bool) {
+	first := serverData[0]
+	response, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(first.Payload)), nil)
+	if err != nil {
+		return nil, false
+	}
+	response.Body.Close()
+
+	var evidence []string
+	if response.StatusCode >= 300 && response.StatusCode < 400 {
+		location := response.Header.Get("Location")
+		target, err := url.Parse(location)
+		if err == nil && target.Host != "" && !sameSite(target.Hostname(), f.Host) {
+			evidence = append(evidence, fmt.Sprintf("%d redirect from %s to %s", response.StatusCode, f.Host, location))
+		}
+	}
+	if len(evidence) == 0 {
+		return nil, false
+	}
+
+	// Stub usually is first packet of "server", so reference is SYN-ACK
+	if reference != nil && reference != first {
+		evidence = append(evidence, packetAnomalies(first, reference, nil)...)
+	}
+	if len(serverData) > 1 && strings.HasPrefix(string(serverData[1].Payload), "HTTP/") {
+		evidence = append(evidence, "real answer of server came after redirect")
+	}
+	return evidence, true
+}
+
+// sameSite reports whether host is domain or its subdomain, so redirect from
+// site.com to www.site.com isn't taken for stub
+func sameSite(host, domain string) bool {
+	host = strings.TrimPrefix(strings.ToLower(host), "www.")
+	domain, _, _ = strings.Cut(strings.TrimPrefix(strings.ToLower(domain), "www."), ":")
+	return host == domain || strings.HasSuffix(host, "."+domain) || strings.HasSuffix(domain, "."+host)
+}
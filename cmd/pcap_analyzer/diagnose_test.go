@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/netip"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ankddev/zapret-discord-youtube/internal/pcap"
+)
+
+// readFlows reads flows of capture in testdata
+func readFlows(t *testing.T, file string) []*Flow {
+	t.Helper()
+	frames, err := pcap.ReadFile(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var packets []*pcap.Packet
+	for _, frame := range frames {
+		packet, err := pcap.Decode(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if packet != nil {
+			packets = append(packets, packet)
+		}
+	}
+	return buildFlows(packets)
+}
+
+func TestDiagnose(t *testing.T) {
+	tests := []struct {
+		file    string
+		verdict Verdict
+		// evidence is part of every line of evidence expected in
+		// diagnosis
+		evidence []string
+	}{
+		{file: "ok.pcap", verdict: VerdictOK, evidence: []string{"server sent 2600 bytes", "closed connection normally"}},
+		{file: "no_synack.pcap", verdict: VerdictNoSYNACK, evidence: []string{"SYN sent 3 times"}},
+		{file: "rst_ttl.pcap", verdict: VerdictRSTInjection, evidence: []string{"TTL 250 differs from TTL 52", "IP ID is 0", "faster than half of handshake RTT"}},
+		{file: "rst_ipid.pcap", verdict: VerdictRSTInjection, evidence: []string{"IP ID 30000 doesn't follow IP ID 65535"}},
+		// IP ID of server goes from 65535 to 1 in RST server sends itself
+		{file: "ipid_wrap.pcap", verdict: VerdictOK, evidence: []string{"server sent 2300 bytes"}},
+		{file: "no_server_hello.pcap", verdict: VerdictNoServerHello, evidence: []string{"ClientHello sent", "retransmitted data 3 times", "handshake completed"}},
+		{file: "http_stub.pcap", verdict: VerdictHTTPStub, evidence: []string{"302 redirect from rutracker.org to http://warning.rt.ru/?id=17", "TTL 60 differs", "real answer of server came after redirect"}},
+		{file: "mid_stream.pcap", verdict: VerdictMidStream, evidence: []string{"server sent 16800 bytes", "retransmitted data for 4.514s", "about 16 KB"}},
+		{file: "inconclusive.pcap", verdict: VerdictInconclusive, evidence: []string{"didn't send any data"}},
+		{file: "quic_ok.pcap", verdict: VerdictOK, evidence: []string{"client sent 2 packets, server sent 2"}},
+		{file: "quic_blocked.pcap", verdict: VerdictQUICBlocked, evidence: []string{"client sent 3 packets", "falls back to TCP"}},
+		{file: "quic_stall.pcap", verdict: VerdictMidStream, evidence: []string{"server answered 2 packets, then stopped while client kept sending for 2.969s"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			flows := readFlows(t, test.file)
+			if len(flows) != 1 {
+				t.Fatalf("found %d flows, want 1", len(flows))
+			}
+			d := diagnose(flows[0])
+			if d.Verdict != test.verdict {
+				t.Errorf("verdict = %q, want %q", d.Verdict, test.verdict)
+			}
+			if len(d.Evidence) != len(test.evidence) {
+				t.Fatalf("evidence %q, want %q", d.Evidence, test.evidence)
+			}
+			for i, want := range test.evidence {
+				if !strings.Contains(d.Evidence[i], want) {
+					t.Errorf("evidence %q, want %q", d.Evidence[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestPacketAnomalies(t *testing.T) {
+	server := netip.MustParseAddr("203.0.113.5")
+	packet := func(ttl uint8, id uint16) *pcap.Packet {
+		return &pcap.Packet{Src: server, TTL: ttl, IPID: id}
+	}
+
+	tests := []struct {
+		name                   string
+		p, reference, previous *pcap.Packet
+		anomalies              int
+	}{
+		{"same host", packet(52, 1001), packet(52, 900), packet(52, 1000), 0},
+		{"route changed by hop", packet(50, 1001), packet(52, 900), packet(52, 1000), 0},
+		{"other TTL", packet(64, 1001), packet(52, 900), packet(52, 1000), 1},
+		{"IP ID wraps", packet(52, 3), packet(52, 65500), packet(52, 65530), 0},
+		{"IP ID goes back a little", packet(52, 65530), packet(52, 1), packet(52, 3), 0},
+		{"IP ID jumps", packet(52, 30000), packet(52, 900), packet(52, 1000), 1},
+		{"IP ID jumps over wrap", packet(52, 40000), packet(52, 65500), packet(52, 65530), 1},
+		{"zero IP ID", packet(52, 0), packet(52, 900), packet(52, 1000), 1},
+		// Hosts with zero IP ID, like Linux in DF packets, aren't compared
+		{"server without IP ID", packet(52, 30000), packet(52, 0), packet(52, 0), 0},
+		{"no reference", packet(64, 30000), nil, nil, 0},
+	}
+	for _, test := range tests {
+		if anomalies := packetAnomalies(test.p, test.reference, test.previous); len(anomalies) != test.anomalies {
+			t.Errorf("%s: anomalies %q, want %d", test.name, anomalies, test.anomalies)
+		}
+	}
+}
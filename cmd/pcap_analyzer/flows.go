@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ankddev/zapret-discord-youtube/internal/fake"
+	"github.com/ankddev/zapret-discord-youtube/internal/pcap"
+)
+
+// Ports of servers flows are analyzed for by default
+var serverPorts = map[uint16]bool{80: true, 443: true}
+
+// Only start of client stream is needed to read ClientHello or HTTP request
+const maxRequestSize = 16 * 1024
+
+// Flow is TCP connection or UDP exchange between client and server
+type Flow struct {
+	Proto          int
+	Client, Server netip.AddrPort
+	Packets        []*pcap.Packet
+	// Host is SNI of ClientHello or Host of HTTP request
+	Host string
+	// TLS is set when client sent ClientHello, HTTP when it sent request
+	TLS, HTTP bool
+}
+
+type flowKey struct {
+	proto int
+	a, b  netip.AddrPort
+}
+
+func newFlowKey(p *pcap.Packet) flowKey {
+	a := netip.AddrPortFrom(p.Src, p.SrcPort)
+	b := netip.AddrPortFrom(p.Dst, p.DstPort)
+	if a.Compare(b) > 0 {
+		a, b = b, a
+	}
+	return flowKey{p.Proto, a, b}
+}
+
+// FromClient reports whether packet was sent by client
+func (f *Flow) FromClient(p *pcap.Packet) bool {
+	return p.Src == f.Client.Addr() && p.SrcPort == f.Client.Port()
+}
+
+// Duration returns time between first and last packet
+func (f *Flow) Duration() time.Duration {
+	if len(f.Packets) == 0 {
+		return 0
+	}
+	return f.Packets[len(f.Packets)-1].Time.Sub(f.Packets[0].Time)
+}
+
+// Bytes returns number of payload bytes sent by client and by server
+func (f *Flow) Bytes() (client, server int) {
+	for _, p := range f.Packets {
+		if f.FromClient(p) {
+			client += len(p.Payload)
+		} else {
+			server += len(p.Payload)
+		}
+	}
+	return client, server
+}
+
+// buildFlows groups packets into flows. Client is side that sent SYN, for
+// flows captured without SYN it's side that doesn't use server port. New SYN
+// with other sequence number on same ports starts new flow.
+func buildFlows(packets []*pcap.Packet) []*Flow {
+	var flows []*Flow
+	active := make(map[flowKey]*Flow)
+	syns := make(map[*Flow]uint32)
+
+	for _, p := range packets {
+		key := newFlowKey(p)
+		flow, ok := active[key]
+		isSYN := p.Proto == pcap.ProtoTCP && p.Has(pcap.FlagSYN) && !p.Has(pcap.FlagACK)
+		if ok && isSYN {
+			if seq, seen := syns[flow]; seen && seq != p.Seq {
+				ok = false
+			}
+		}
+		if !ok {
+			flow = &Flow{Proto: p.Proto}
+			flow.Client = netip.AddrPortFrom(p.Src, p.SrcPort)
+			flow.Server = netip.AddrPortFrom(p.Dst, p.DstPort)
+			if !isSYN && serverPorts[p.SrcPort] && !serverPorts[p.DstPort] {
+				flow.Client, flow.Server = flow.Server, flow.Client
+			}
+			active[key] = flow
+			flows = append(flows, flow)
+		}
+		if isSYN {
+			syns[flow] = p.Seq
+		}
+		flow.Packets = append(flow.Packets, p)
+	}
+
+	for _, flow := range flows {
+		flow.identify()
+	}
+	return flows
+}
+
+// identify reads SNI or Host from start of client stream
+func (f *Flow) identify() {
+	if f.Proto == pcap.ProtoUDP {
+		for _, p := range f.Packets {
+			if !f.FromClient(p) || !fake.IsQUICInitial(p.Payload) {
+				continue
+			}
+			f.TLS = true
+			if packet, err := fake.ParseQUICInitial(p.Payload); err == nil && packet.ClientHello != nil && len(packet.ClientHello.SNI) > 0 {
+				f.Host = packet.ClientHello.SNI[0]
+				return
+			}
+		}
+		return
+	}
+
+	stream := f.clientStream()
+	switch {
+	case fake.IsTLSClientHello(stream):
+		f.TLS = true
+		if hello, err := fake.ParseClientHello(stream); err == nil && len(hello.SNI) > 0 {
+			f.Host = hello.SNI[0]
+		}
+	case fake.Detect(stream) == fake.KindHTTP:
+		f.HTTP = true
+		if request, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(stream))); err == nil {
+			f.Host = request.Host
+		} else if _, rest, ok := strings.Cut(string(stream), "\nHost:"); ok {
+			// Request may be cut in the middle of headers
+			host, _, _ := strings.Cut(rest, "\n")
+			f.Host = strings.TrimSpace(host)
+		}
+	}
+}
+
+// clientStream returns start of data client sent, reassembled by sequence
+// numbers, so retransmissions and reordering don't break it
+func (f *Flow) clientStream() []byte {
+	type segment struct {
+		seq  uint32
+		data []byte
+	}
+	var segments []segment
+	for _, p := range f.Packets {
+		if f.FromClient(p) && len(p.Payload) > 0 {
+			segments = append(segments, segment{p.Seq, p.Payload})
+		}
+	}
+	if len(segments) == 0 {
+		return nil
+	}
+	sort.SliceStable(segments, func(i, j int) bool {
+		return int32(segments[i].seq-segments[j].seq) < 0
+	})
+
+	start := segments[0].seq
+	var stream []byte
+	for _, s := range segments {
+		offset := int(int32(s.seq - start))
+		if offset > len(stream) || len(stream) >= maxRequestSize {
+			break
+		}
+		if end := offset + len(s.data); end > len(stream) {
+			stream = append(stream, s.data[len(stream)-offset:]...)
+		}
+	}
+	return stream
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+
+	"github.com/ankddev/zapret-discord-youtube/internal/pcap"
+)
+
+// TestReorderedClientHello reads SNI of ClientHello split into segments that
+// came out of order, with one of them retransmitted
+func TestReorderedClientHello(t *testing.T) {
+	flows := readFlows(t, "reordered.pcap")
+	if len(flows) != 1 {
+		t.Fatalf("found %d flows, want 1", len(flows))
+	}
+	flow := flows[0]
+	if !flow.TLS || flow.Host != "discord.com" {
+		t.Errorf("flow TLS %v with host %q, want ClientHello for discord.com", flow.TLS, flow.Host)
+	}
+	if want := netip.MustParseAddrPort("192.168.1.10:50000"); flow.Client != want {
+		t.Errorf("client is %v, want %v", flow.Client, want)
+	}
+}
+
+func TestClientStream(t *testing.T) {
+	client := netip.MustParseAddrPort("192.168.1.10:50000")
+	server := netip.MustParseAddrPort("203.0.113.5:443")
+	segment := func(seq uint32, data string) *pcap.Packet {
+		return &pcap.Packet{Src: client.Addr(), SrcPort: client.Port(), Dst: server.Addr(), DstPort: server.Port(),
+			Proto: pcap.ProtoTCP, Seq: seq, Flags: pcap.FlagACK, Payload: []byte(data)}
+	}
+	answer := &pcap.Packet{Src: server.Addr(), SrcPort: server.Port(), Dst: client.Addr(), DstPort: client.Port(),
+		Proto: pcap.ProtoTCP, Flags: pcap.FlagACK, Payload: []byte("answer")}
+
+	tests := []struct {
+		name     string
+		segments []*pcap.Packet
+		want     string
+	}{
+		{"in order", []*pcap.Packet{segment(100, "GET "), answer, segment(104, "/ HTTP")}, "GET / HTTP"},
+		{"reordered", []*pcap.Packet{segment(104, "/ HTTP"), segment(100, "GET ")}, "GET / HTTP"},
+		{"retransmitted", []*pcap.Packet{segment(100, "GET "), segment(100, "GET "), segment(104, "/ HTTP")}, "GET / HTTP"},
+		{"overlapping", []*pcap.Packet{segment(100, "GET /"), segment(102, "T / HT"), segment(108, "TP")}, "GET / HTTP"},
+		{"sequence wraps", []*pcap.Packet{segment(2, "HTTP"), segment(0xfffffffc, "GET "), segment(0, "/ ")}, "GET / HTTP"},
+		// Data after gap isn't known to follow, so it's left out
+		{"gap", []*pcap.Packet{segment(100, "GET "), segment(110, "TP")}, "GET "},
+	}
+	for _, test := range tests {
+		flow := &Flow{Proto: pcap.ProtoTCP, Client: client, Server: server, Packets: test.segments}
+		if stream := flow.clientStream(); !bytes.Equal(stream, []byte(test.want)) {
+			t.Errorf("%s: stream %q, want %q", test.name, stream, test.want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ankddev/zapret-discord-youtube/internal/pcap"
+	"github.com/ankddev/zapret-discord-youtube/internal/preconfig"
+)
+
+const (
+	// Colors
+	colorReset = "\033[0m"
+	colorRed   = "\033[31m"
+	colorGreen = "\033[32m"
+	colorCyan  = "\033[36m"
+
+	preconfigsDir = "pre-configs"
+
+	// Number of pre-configs suggested for every flow
+	suggestedPreconfigs = 3
+)
+
+// Version is set during build
+var version string
+
+// Config of analysis
+type Config struct {
+	path string
+	// target limits analysis to flows with host or server address
+	target     string
+	allPorts   bool
+	preconfigs []*preconfig.Preconfig
+}
+
+// analyze reads capture and prints diagnosis of every flow. Returns number
+// of flows with interference.
+func analyze(config Config) (int, error) {
+	frames, err := pcap.ReadFile(config.path)
+	if err != nil {
+		return 0, err
+	}
+
+	var packets []*pcap.Packet
+	malformed := 0
+	for _, frame := range frames {
+		packet, err := pcap.Decode(frame)
+		if err != nil {
+			malformed++
+			continue
+		}
+		if packet != nil {
+			packets = append(packets, packet)
+		}
+	}
+	fmt.Printf("Read %d frames, %d TCP and UDP packets", len(frames), len(packets))
+	if malformed > 0 {
+		fmt.Printf(", %d malformed", malformed)
+	}
+	fmt.Println()
+
+	var flows []*Flow
+	for _, flow := range buildFlows(packets) {
+		if config.matches(flow) {
+			flows = append(flows, flow)
+		}
+	}
+	if len(flows) == 0 {
+		return 0, fmt.Errorf("no flows to analyze, check target and that capture contains traffic to ports 80 and 443")
+	}
+
+	counts := make(map[Verdict]int)
+	blocked := 0
+	for _, flow := range flows {
+		diagnosis := diagnose(flow)
+		counts[diagnosis.Verdict]++
+		if diagnosis.Verdict != VerdictOK && diagnosis.Verdict != VerdictInconclusive {
+			blocked++
+		}
+		config.print(flow, diagnosis)
+	}
+
+	fmt.Println("\nSummary:")
+	verdicts := make([]Verdict, 0, len(counts))
+	for verdict := range counts {
+		verdicts = append(verdicts, verdict)
+	}
+	sort.Slice(verdicts, func(i, j int) bool {
+		if counts[verdicts[i]] != counts[verdicts[j]] {
+			return counts[verdicts[i]] > counts[verdicts[j]]
+		}
+		return verdicts[i] < verdicts[j]
+	})
+	for _, verdict := range verdicts {
+		fmt.Printf("  %s: %d\n", verdict, counts[verdict])
+	}
+	return blocked, nil
+}
+
+// matches reports whether flow is to be analyzed
+func (c Config) matches(f *Flow) bool {
+	if c.target != "" {
+		if addr, err := netip.ParseAddr(c.target); err == nil {
+			return f.Server.Addr() == addr
+		}
+		host := strings.ToLower(f.Host)
+		target := strings.ToLower(c.target)
+		return host == target || strings.HasSuffix(host, "."+target)
+	}
+	if c.allPorts {
+		return true
+	}
+	return serverPorts[f.Server.Port()]
+}
+
+func (c Config) print(f *Flow, d Diagnosis) {
+	proto := "TCP"
+	if f.Proto == pcap.ProtoUDP {
+		proto = "UDP"
+	}
+	host := f.Host
+	if host == "" {
+		host = "unknown host"
+	}
+	client, server := f.Bytes()
+	fmt.Printf("\n%s%s %s -> %s (%s)%s\n", colorCyan, proto, f.Client, f.Server, host, colorReset)
+	fmt.Printf("  %d packets in %s, client sent %d bytes, server sent %d bytes\n",
+		len(f.Packets), f.Duration().Round(time.Millisecond), client, server)
+
+	color := colorRed
+	switch d.Verdict {
+	case VerdictOK:
+		color = colorGreen
+	case VerdictInconclusive:
+		color = colorReset
+	}
+	fmt.Printf("  Diagnosis: %s%s%s\n", color, d.Verdict, colorReset)
+	for _, evidence := range d.Evidence {
+		fmt.Printf("    - %s\n", evidence)
+	}
+
+	suggestion, ok := suggestions[d.Verdict]
+	if !ok {
+		return
+	}
+	fmt.Printf("  Suggestion: %s\n", suggestion.Strategy)
+	if len(suggestion.Modes) == 0 {
+		return
+	}
+	family := familyFor(f.Host)
+	fmt.Printf("  Pre-config family: %s\n", family)
+	if names := matchingPreconfigs(c.preconfigs, family, f, suggestion.Modes, suggestedPreconfigs); len(names) > 0 {
+		fmt.Printf("  Try pre-configs: %s\n", strings.Join(names, ", "))
+	}
+}
+
+func prompt(reader *bufio.Reader, question, defaultValue string) (string, error) {
+	if defaultValue != "" {
+		fmt.Printf("%s [%s]: ", question, defaultValue)
+	} else {
+		fmt.Printf("%s: ", question)
+	}
+	answer, err := reader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("error reading input: %v", err)
+	}
+	if answer = strings.TrimSpace(answer); answer == "" {
+		return defaultValue, nil
+	}
+	return answer, nil
+}
+
+func loadPreconfigs(dir string) []*preconfig.Preconfig {
+	preconfigs, err := preconfig.LoadDir(dir)
+	if err != nil {
+		fmt.Printf("Pre-configs not loaded, only strategies are suggested: %v\n", err)
+	}
+	return preconfigs
+}
+
+func runInteractive() int {
+	reader := bufio.NewReader(os.Stdin)
+	defer func() {
+		fmt.Println("\nPress Enter to exit...")
+		reader.ReadString('\n')
+	}()
+
+	fmt.Printf("%sAnalyze capture of blocked connection%s (version %s)\n", colorCyan, colorReset, version)
+	fmt.Println("Capture traffic with Wireshark while opening blocked site with winws stopped, then save it as pcap or pcapng.")
+
+	var config Config
+	var err error
+	// Quotes are kept when path is dragged into console window
+	if config.path, err = prompt(reader, "\nPath to capture file", ""); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	config.path = strings.Trim(config.path, `"`)
+	if config.target, err = prompt(reader, "Blocked domain or IP address (empty to analyze all connections)", ""); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	config.preconfigs = loadPreconfigs(preconfigsDir)
+
+	fmt.Println()
+	if _, err := analyze(config); err != nil {
+		fmt.Printf("%sError: %v%s\n", colorRed, err, colorReset)
+		return 1
+	}
+	return 0
+}
+
+func runCLI(args []string) int {
+	var config Config
+	fs := flag.NewFlagSet("pcap_analyzer", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: pcap_analyzer [flags] FILE")
+		fmt.Fprintln(fs.Output(), "\nExit code is 0 if no interference found, 3 if found, 1 on errors and 2 on invalid flags.\n\nFlags:")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&config.target, "target", "", "analyze only connections to this domain (and its subdomains) or IP address")
+	fs.BoolVar(&config.allPorts, "all", false, "analyze connections to all ports, not only 80 and 443")
+	dir := fs.String("preconfigs", preconfigsDir, "folder with pre-configs to suggest from")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	config.path = fs.Arg(0)
+	config.preconfigs = loadPreconfigs(*dir)
+
+	blocked, err := analyze(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if blocked > 0 {
+		return 3
+	}
+	return 0
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1:]))
+	}
+	os.Exit(runInteractive())
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ankddev/zapret-discord-youtube/internal/pcap"
+	"github.com/ankddev/zapret-discord-youtube/internal/preconfig"
+)
+
+// Suggestion is strategy that counters interference
+type Suggestion struct {
+	Strategy string
+	// Modes are values of --dpi-desync that implement strategy, empty when
+	// winws can't help
+	Modes []string
+}
+
+var suggestions = map[Verdict]Suggestion{
+	VerdictRSTInjection: {
+		Strategy: "send fake request before real one, so DPI resets fake: --dpi-desync=fake with --dpi-desync-fooling=md5sig or badseq, or with --dpi-desync-autottl",
+		Modes:    []string{"fake", "fake,split2", "fake,disorder2", "fake,tamper"},
+	},
+	VerdictNoServerHello: {
+		Strategy: "split ClientHello, so DPI can't read SNI: --dpi-desync=fake,split2 or fake,disorder2",
+		Modes:    []string{"fake,split2", "fake,disorder2", "split2", "disorder2", "syndata,split2", "syndata,disorder2"},
+	},
+	VerdictHTTPStub: {
+		Strategy: "split HTTP request at Host header: --dpi-desync=split2 with --dpi-desync-split-http-req=host",
+		Modes:    []string{"split2", "fake,split2", "split", "fake,split"},
+	},
+	VerdictMidStream: {
+		Strategy: "DPI lets handshake through and cuts connection later, usually by IP address. Try syndata and fake with --dpi-desync-repeats, if it doesn't help, only VPN will",
+		Modes:    []string{"syndata", "syndata,split2", "syndata,disorder2", "fake,split2"},
+	},
+	VerdictQUICBlocked: {
+		Strategy: "send fake QUIC Initial: --dpi-desync=fake with --dpi-desync-fake-quic and --dpi-desync-repeats",
+		Modes:    []string{"fake"},
+	},
+	VerdictNoSYNACK: {
+		Strategy: "IP address is blocked, winws can't help. Use VPN or proxy for this address",
+	},
+}
+
+// Pre-config families for services, others are covered by UltimateFix
+var familyHosts = []struct {
+	family string
+	hosts  []string
+}{
+	{"DiscordFix", []string{"discord.com", "discord.gg", "discordapp.com", "discordapp.net", "discord.media"}},
+	{"YoutubeFix", []string{"youtube.com", "youtu.be", "googlevideo.com", "ytimg.com", "ggpht.com"}},
+	{"CloudflareFix", []string{"cloudflare.com", "cloudflare-dns.com"}},
+	{"UbisoftFix", []string{"ubisoft.com", "ubi.com"}},
+	{"ViberFix", []string{"viber.com"}},
+}
+
+// familyFor returns pre-config family that covers host
+func familyFor(host string) string {
+	host = strings.ToLower(host)
+	for _, fh := range familyHosts {
+		for _, h := range fh.hosts {
+			if host == h || strings.HasSuffix(host, "."+h) {
+				return fh.family
+			}
+		}
+	}
+	return "UltimateFix"
+}
+
+// matchingPreconfigs returns pre-configs of family that use one of modes in
+// profile for port of flow
+func matchingPreconfigs(preconfigs []*preconfig.Preconfig, family string, f *Flow, modes []string, limit int) []string {
+	filter := "filter-tcp"
+	if f.Proto == pcap.ProtoUDP {
+		filter = "filter-udp"
+	}
+
+	var names []string
+	for _, p := range preconfigs {
+		if p.Family != family {
+			continue
+		}
+		for _, profile := range p.Profiles {
+			ports, ok := profile.Get(filter)
+			desync, _ := profile.Get("dpi-desync")
+			if ok && portInList(f.Server.Port(), ports) && contains(modes, desync) {
+				names = append(names, p.Name)
+				break
+			}
+		}
+		if len(names) == limit {
+			break
+		}
+	}
+	return names
+}
+
+// portInList reports whether port is in list like "80,443,50000-65535"
+func portInList(port uint16, list string) bool {
+	for _, item := range strings.Split(list, ",") {
+		low, high, isRange := strings.Cut(item, "-")
+		if !isRange {
+			high = low
+		}
+		from, err1 := strconv.Atoi(low)
+		to, err2 := strconv.Atoi(high)
+		if err1 == nil && err2 == nil && int(port) >= from && int(port) <= to {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// BlockSignatures holds patterns of ISP stub pages, loaded from data file
+type BlockSignatures struct {
+	Redirects    []string
+	Bodies       []string
+	Certificates []string
+}
+
+// loadBlockSignatures reads signature file. Missing file isn't an error, but
+// no stub is detected without signatures.
+func loadBlockSignatures(path string) (BlockSignatures, error) {
+	var sigs BlockSignatures
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return sigs, nil
+	}
+	if err != nil {
+		return sigs, fmt.Errorf("error opening signatures file: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		kind, pattern, ok := strings.Cut(line, " ")
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if !ok || pattern == "" {
+			return sigs, fmt.Errorf("invalid signature at line %d: %s", lineNumber, line)
+		}
+
+		switch kind {
+		case "redirect":
+			sigs.Redirects = append(sigs.Redirects, pattern)
+		case "body":
+			sigs.Bodies = append(sigs.Bodies, pattern)
+		case "certificate":
+			sigs.Certificates = append(sigs.Certificates, pattern)
+		default:
+			return sigs, fmt.Errorf("unknown signature type '%s' at line %d", kind, lineNumber)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return sigs, fmt.Errorf("error reading signatures file: %v", err)
+	}
+	return sigs, nil
+}
+
+// StubResult describes stub page found instead of real site
+type StubResult struct {
+	Blocked  bool
+	Kind     string
+	Evidence string
+}
+
+// Maximal size of page body to search signatures in
+const stubBodyLimit = 64 * 1024
+
+// detectStub checks whether domain is replaced by ISP stub page: over plain
+// HTTP by redirect or page content, over HTTPS by certificate of stub host.
+// Port in domain is used for both of them instead of default ones.
+func detectStub(domain string, sigs BlockSignatures, timeout time.Duration) StubResult {
+	if result := detectHTTPStub(domain, sigs, timeout); result.Blocked {
+		return result
+	}
+	return detectCertificateStub(domain, sigs, timeout)
+}
+
+func detectHTTPStub(domain string, sigs BlockSignatures, timeout time.Duration) StubResult {
+	client := &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, body, err := fetchPage(client, "http://"+domain+"/")
+	if err != nil {
+		return StubResult{}
+	}
+
+	// Common phrases like "роскомнадзор" are found on ordinary sites too, so
+	// page text counts only on page of other host the site redirected to or
+	// on page linking to known stub host
+	location, err := resp.Location()
+	if err == nil {
+		if pattern := matchSignature(location.String(), sigs.Redirects); pattern != "" {
+			return StubResult{Blocked: true, Kind: "redirect", Evidence: location.String()}
+		}
+		if sameSite(location.Hostname(), hostOnly(domain)) {
+			return StubResult{}
+		}
+		if _, body, err = fetchPage(client, location.String()); err != nil {
+			return StubResult{}
+		}
+	} else if matchSignature(body, sigs.Redirects) == "" {
+		return StubResult{}
+	}
+
+	if pattern := matchSignature(body, sigs.Bodies); pattern != "" {
+		return StubResult{Blocked: true, Kind: "body", Evidence: pattern}
+	}
+	return StubResult{}
+}
+
+// fetchPage requests page without following redirects and returns its body
+// cut to stubBodyLimit
+func fetchPage(client *http.Client, url string) (*http.Response, string, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("User-Agent", "zapret-discord-youtube-tester")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, stubBodyLimit))
+	return resp, string(body), nil
+}
+
+// sameSite reports whether one host is other one or its subdomain, like www
+// redirect of the site itself
+func sameSite(host, domain string) bool {
+	return inDomain(host, domain) || inDomain(domain, host)
+}
+
+// inDomain reports whether host is domain or its subdomain
+func inDomain(host, domain string) bool {
+	host, domain = strings.ToLower(host), strings.ToLower(domain)
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+func detectCertificateStub(domain string, sigs BlockSignatures, timeout time.Duration) StubResult {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	host, port, err := net.SplitHostPort(domain)
+	if err != nil {
+		host, port = domain, "443"
+	}
+	dialer := &tls.Dialer{Config: &tls.Config{ServerName: host, RootCAs: rootCAs}}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err == nil {
+		conn.Close()
+		return StubResult{}
+	}
+
+	var hostErr x509.HostnameError
+	if !errors.As(err, &hostErr) || hostErr.Certificate == nil {
+		return StubResult{}
+	}
+
+	// CDNs and misconfigured sites present certificates of other hosts too,
+	// so only certificate of known stub host counts
+	names := append([]string{hostErr.Certificate.Subject.CommonName}, hostErr.Certificate.DNSNames...)
+	for _, name := range names {
+		for _, pattern := range sigs.Certificates {
+			if inDomain(name, pattern) {
+				return StubResult{Blocked: true, Kind: "certificate", Evidence: strings.Join(names, ", ")}
+			}
+		}
+	}
+	return StubResult{}
+}
+
+// matchSignature returns first pattern found in text, case-insensitive
+func matchSignature(text string, patterns []string) string {
+	text = strings.ToLower(text)
+	for _, pattern := range patterns {
+		if strings.Contains(text, pattern) {
+			return pattern
+		}
+	}
+	return ""
+}
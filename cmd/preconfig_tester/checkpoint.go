@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Checkpoint is saved after every tested pre-config, so interrupted run can be
+// resumed without testing same pre-configs again
+type Checkpoint struct {
+	Key       string    `json:"key"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Report    *Report   `json:"report"`
+}
+
+// checkpointKey identifies run by its domain set, pre-config filter, mode,
+// number of trials and throughput probe URL.
+// Only run with same key can be resumed.
+func checkpointKey(config Config, domains []string) string {
+	sorted := make([]string, len(domains))
+	copy(sorted, domains)
+	sort.Strings(sorted)
+
+	return strings.Join([]string{strings.Join(sorted, ","), config.filter.String(), runMode(config),
+		strconv.Itoa(config.trials), config.throughputURL}, "|")
+}
+
+// loadCheckpoint returns checkpoint of interrupted run with same key or nil if
+// there is nothing to resume
+func loadCheckpoint(config Config, domains []string) *Checkpoint {
+	data, err := os.ReadFile(config.stateFile)
+	if err != nil {
+		return nil
+	}
+
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil || checkpoint.Report == nil {
+		return nil
+	}
+	if checkpoint.Key != checkpointKey(config, domains) {
+		return nil
+	}
+	return &checkpoint
+}
+
+func saveCheckpoint(config Config, domains []string, report *Report) error {
+	checkpoint := Checkpoint{
+		Key:       checkpointKey(config, domains),
+		UpdatedAt: time.Now(),
+		Report:    report,
+	}
+	data, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding checkpoint: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(config.stateFile), 0755); err != nil {
+		return fmt.Errorf("error creating checkpoint directory: %v", err)
+	}
+
+	// Write to temporary file first, so checkpoint is never left half-written
+	tmp := config.stateFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("error writing checkpoint: %v", err)
+	}
+	if err := os.Rename(tmp, config.stateFile); err != nil {
+		return fmt.Errorf("error writing checkpoint: %v", err)
+	}
+	return nil
+}
+
+func removeCheckpoint(config Config) {
+	os.Remove(config.stateFile)
+}
+
+// testedPreconfigs returns results restored from checkpoint by pre-config path
+func (c *Checkpoint) testedPreconfigs() map[string]*PreconfigResult {
+	tested := make(map[string]*PreconfigResult)
+	for _, pr := range c.Report.Preconfigs {
+		tested[pr.Path] = resultFromReport(pr)
+	}
+	return tested
+}
+
+// probeError keeps error class of probe restored from checkpoint
+type probeError struct {
+	class   string
+	message string
+}
+
+func (e *probeError) Error() string {
+	return e.message
+}
+
+func resultFromReport(pr PreconfigReport) *PreconfigResult {
+	result := &PreconfigResult{
+		Name:       pr.Name,
+		Path:       pr.Path,
+		Started:    pr.Started,
+		Outages:    pr.Outages,
+		StartError: pr.StartError,
+		LogFile:    pr.Log,
+	}
+	for _, p := range pr.Probes {
+		probe := ProbeResult{
+			Domain:    p.Domain,
+			Trial:     p.Trial,
+			Success:   p.Success,
+			DNS:       fromMilliseconds(p.DNSMs),
+			Connect:   fromMilliseconds(p.ConnectMs),
+			Handshake: fromMilliseconds(p.HandshakeMs),
+			TTFB:      fromMilliseconds(p.TTFBMs),
+			Total:     fromMilliseconds(p.TotalMs),
+		}
+		if p.Error != "" || p.ErrorClass != "" {
+			probe.Err = &probeError{class: p.ErrorClass, message: p.Error}
+		}
+		result.Probes = append(result.Probes, probe)
+	}
+	if pr.Throughput != nil {
+		result.Throughput = pr.Throughput.result()
+		result.MinSpeed = pr.Throughput.MinSpeed
+		result.BaselineSpeed = pr.Throughput.BaselineSpeed
+	}
+	return result
+}
+
+func fromMilliseconds(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// restoredErrorClass returns class of error restored from checkpoint
+func restoredErrorClass(err error) (string, bool) {
+	var pe *probeError
+	if errors.As(err, &pe) {
+		return pe.class, true
+	}
+	return "", false
+}
+
+// askResume asks whether to resume interrupted run
+func askResume(checkpoint *Checkpoint, total int) bool {
+	fmt.Printf("\nFound interrupted run from %s (%d of %d pre-configs tested).\n",
+		checkpoint.UpdatedAt.Format("2006-01-02 15:04"), len(checkpoint.Report.Preconfigs), total)
+	fmt.Print("Resume it? [Y/n]: ")
+
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "" || answer == "y" || answer == "yes"
+}
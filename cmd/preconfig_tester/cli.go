@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Exit codes of non-interactive mode
+const (
+	exitFound       = 0 // working pre-config found or bypass not required
+	exitError       = 1 // unexpected error
+	exitUsage       = 2 // invalid flags
+	exitNotFound    = 3 // no pre-config works for all domains
+	exitNotElevated = 4 // administrative privileges missing
+	exitOffline     = 5 // control domains unreachable, no internet connection
+)
+
+// Outcome describes how bypass check finished
+type Outcome int
+
+const (
+	OutcomeFound Outcome = iota
+	OutcomeNotRequired
+	OutcomeNotFound
+	OutcomeOffline
+)
+
+func (o Outcome) exitCode() int {
+	switch o {
+	case OutcomeFound, OutcomeNotRequired:
+		return exitFound
+	case OutcomeOffline:
+		return exitOffline
+	default:
+		return exitNotFound
+	}
+}
+
+const cliUsage = `Usage: preconfig_tester [flags]
+
+Without flags tester runs in interactive mode.
+
+Flags:
+`
+
+const cliExitCodes = `
+Exit codes:
+  0  working pre-config found or bypass not required
+  1  unexpected error
+  2  invalid flags
+  3  no pre-config works for all domains (in search mode: no strategy found)
+  4  administrative privileges required
+  5  no internet connection, control domains unreachable
+`
+
+// parseFlags builds config for non-interactive mode from command line arguments.
+// Returns whether only history should be shown.
+func parseFlags(args []string, stderr io.Writer) (Config, bool, error) {
+	config := defaultConfig()
+
+	fs := flag.NewFlagSet("preconfig_tester", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, cliUsage)
+		fs.PrintDefaults()
+		fmt.Fprint(stderr, cliExitCodes)
+	}
+
+	domains := fs.String("domains", "", "comma or space separated list of domains to check")
+	domainsFile := fs.String("domains-file", "", "file with domains, one per line (lines starting with # are ignored)")
+	include := fs.String("preconfigs", "", "comma separated globs of pre-config names to test, for example \"UltimateFix*\"")
+	exclude := fs.String("exclude", "", "comma separated globs of pre-config names to skip")
+	families := fs.String("family", "", "comma separated pre-config families to test, for example \"youtube,general\"")
+	tags := fs.String("tag", "", "comma separated tags of pre-configs to test, for example \"youtube,discord\"")
+	fs.StringVar(&config.filter.Shortlist, "shortlist", "", "file with names of pre-configs to test, one per line")
+	fs.DurationVar(&config.connectionTimeout, "timeout", config.connectionTimeout, "connection timeout for every domain")
+	fs.IntVar(&config.trials, "trials", config.trials, "number of trials for every domain with every pre-config")
+	fs.DurationVar(&config.processWaitTime, "wait", config.processWaitTime, "time to wait for winws to start")
+	fs.StringVar(&config.reportDir, "report", config.reportDir, "directory to save reports to")
+	formats := fs.String("report-formats", strings.Join(reportFormats, ","), "comma separated report formats (json, csv, md)")
+	stopOnFirst := fs.Bool("stop-on-first", false, "stop at first working pre-config instead of testing all of them")
+	doh := fs.String("doh", strings.Join(config.dohResolvers, ","), "comma separated DNS-over-HTTPS resolvers to compare system resolver with")
+	fs.StringVar(&config.throughputURL, "throughput-url", "", "URL to download for measuring speed, disabled if empty")
+	fs.Int64Var(&config.throughputBytes, "throughput-bytes", config.throughputBytes, "number of bytes to download from throughput URL")
+	minSpeed := fs.Float64("throughput-min-speed", config.throughputMinSpeed/1024, "minimal download speed in KB/s to count pre-config as fix")
+	fs.DurationVar(&config.throughputTimeout, "throughput-timeout", config.throughputTimeout, "time limit for download")
+	showHistory := fs.Bool("history", false, "show how often pre-configs worked on this network and exit")
+	fs.IntVar(&config.historyDays, "history-days", config.historyDays, "number of days of history to show")
+	fs.StringVar(&config.historyFile, "history-file", config.historyFile, "file to keep history of results in")
+	fs.BoolVar(&config.lookupASN, "lookup-asn", false, "look up ISP of network at ipinfo.io to tell networks apart in history (sends your public IP to it)")
+	control := fs.String("control", strings.Join(config.controlTargets, ","), "comma separated domains known not to be blocked, used to detect connection loss (empty to disable)")
+	fs.DurationVar(&config.outageWait, "outage-wait", config.outageWait, "time to wait for connection to come back after it was lost")
+	fs.StringVar(&config.signaturesFile, "signatures", config.signaturesFile, "file with signatures of ISP stub pages")
+	fs.StringVar(&config.stateFile, "state", config.stateFile, "file to save progress to after every pre-config")
+	fs.BoolVar(&config.resume, "resume", false, "resume interrupted run with same domains, pre-configs and mode")
+	fs.BoolVar(&config.search, "search", false, "search for new strategy instead of testing pre-configs and save best ones to pre-configs folder")
+	fs.DurationVar(&config.searchBudget, "search-budget", config.searchBudget, "time limit for strategy search")
+	fs.IntVar(&config.searchMaxCandidates, "search-max", config.searchMaxCandidates, "maximal number of strategies to try in search")
+	fs.IntVar(&config.searchSave, "search-save", config.searchSave, "number of best strategies to save as pre-configs")
+	fs.StringVar(&config.searchList, "search-list", config.searchList, "hostlist from lists folder to bind found pre-configs to")
+
+	if err := fs.Parse(args); err != nil {
+		return config, false, err
+	}
+	if fs.NArg() > 0 {
+		return config, false, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if *showHistory {
+		if config.historyDays < 1 {
+			return config, false, fmt.Errorf("history days must be at least 1")
+		}
+		return config, true, nil
+	}
+
+	var domainArgs []string
+	if *domains != "" {
+		domainArgs = append(domainArgs, strings.FieldsFunc(*domains, func(r rune) bool {
+			return r == ',' || r == ' '
+		})...)
+	}
+	if *domainsFile != "" {
+		fileDomains, err := readDomainsFile(*domainsFile)
+		if err != nil {
+			return config, false, err
+		}
+		domainArgs = append(domainArgs, fileDomains...)
+	}
+	if len(domainArgs) == 0 {
+		return config, false, fmt.Errorf("no domains specified, use -domains or -domains-file")
+	}
+
+	var formatted []string
+	for _, domain := range domainArgs {
+		if !isValidDomain(domain) {
+			return config, false, fmt.Errorf("invalid domain format for '%s'. Use format domain.com", domain)
+		}
+		formatted = append(formatted, formatDomainWithPort(domain))
+	}
+	config.targetDomain = strings.Join(formatted, " ")
+
+	config.filter.Include = splitList(*include)
+	config.filter.Exclude = splitList(*exclude)
+	config.filter.Families = splitList(*families)
+	config.filter.Tags = splitList(*tags)
+	if err := config.filter.validate(); err != nil {
+		return config, false, err
+	}
+
+	config.dohResolvers = nil
+	for _, resolver := range strings.Split(*doh, ",") {
+		if resolver = strings.TrimSpace(resolver); resolver != "" {
+			config.dohResolvers = append(config.dohResolvers, resolver)
+		}
+	}
+
+	config.controlTargets = nil
+	for _, target := range strings.Split(*control, ",") {
+		if target = strings.TrimSpace(target); target == "" {
+			continue
+		}
+		if !isValidDomain(target) {
+			return config, false, fmt.Errorf("invalid control domain format for '%s'. Use format domain.com", target)
+		}
+		config.controlTargets = append(config.controlTargets, target)
+	}
+
+	config.reportFormats = nil
+	for _, format := range strings.Split(*formats, ",") {
+		format = strings.TrimSpace(format)
+		if format == "" {
+			continue
+		}
+		if !contains(reportFormats, format) {
+			return config, false, fmt.Errorf("unknown report format: %s", format)
+		}
+		config.reportFormats = append(config.reportFormats, format)
+	}
+
+	if config.connectionTimeout <= 0 || config.processWaitTime <= 0 {
+		return config, false, fmt.Errorf("timeout and wait must be positive")
+	}
+	if config.trials < 1 {
+		return config, false, fmt.Errorf("trials must be at least 1")
+	}
+
+	if config.search {
+		if config.searchBudget <= 0 || config.searchMaxCandidates < 1 || config.searchSave < 1 {
+			return config, false, fmt.Errorf("search budget, maximal number of strategies and number to save must be positive")
+		}
+		if _, err := os.Stat(filepath.Join("lists", config.searchList)); err != nil {
+			return config, false, fmt.Errorf("hostlist %s not found in lists folder", config.searchList)
+		}
+	}
+
+	if config.throughputURL != "" {
+		if config.throughputBytes <= 0 || *minSpeed <= 0 || config.throughputTimeout <= 0 {
+			return config, false, fmt.Errorf("throughput bytes, minimal speed and timeout must be positive")
+		}
+		config.throughputMinSpeed = *minSpeed * 1024
+	}
+
+	config.sweep = !*stopOnFirst
+	return config, false, nil
+}
+
+func readDomainsFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening domains file: %v", err)
+	}
+	defer file.Close()
+
+	var domains []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domains = append(domains, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading domains file: %v", err)
+	}
+	return domains, nil
+}
+
+// runCLI runs tester without any prompts and returns process exit code
+func runCLI(args []string) int {
+	config, showHistory, err := parseFlags(args, os.Stderr)
+	if err == flag.ErrHelp {
+		return exitFound
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+
+	if showHistory {
+		if err := printHistory(config, detectNetwork(config.connectionTimeout, config.lookupASN), 0); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitError
+		}
+		return exitFound
+	}
+
+	if !isElevated() {
+		fmt.Fprintln(os.Stderr, "Error: administrative privileges required, run tester from elevated console")
+		return exitNotElevated
+	}
+
+	setupSignalHandler(config, false)
+
+	start := time.Now()
+	outcome, err := runBypassCheck(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	fmt.Printf("\nFinished in %s\n", time.Since(start).Round(time.Second))
+	return outcome.exitCode()
+}
+
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Domains that are known not to be blocked in Russia. If none of them is
+// reachable, problem is in uplink, not in DPI.
+var defaultControlTargets = []string{
+	"ya.ru",
+	"vk.com",
+	"mail.ru",
+}
+
+// ControlStatus is verdict of probing control targets
+type ControlStatus int
+
+const (
+	ControlOnline ControlStatus = iota
+	ControlDegraded
+	ControlOffline
+)
+
+func (s ControlStatus) String() string {
+	switch s {
+	case ControlOnline:
+		return "online"
+	case ControlDegraded:
+		return "degraded"
+	case ControlOffline:
+		return "offline"
+	default:
+		return "unknown"
+	}
+}
+
+// checkControl probes every control target once. Connection is offline when
+// none of them is reachable and degraded when only some of them are.
+func checkControl(targets []string, timeout time.Duration) (ControlStatus, []ProbeResult) {
+	var probes []ProbeResult
+	reachable := 0
+	for _, target := range targets {
+		probe := probeDomain(formatDomainWithPort(target), timeout)
+		probes = append(probes, probe)
+		if probe.Success {
+			reachable++
+		}
+	}
+
+	switch {
+	case len(targets) == 0 || reachable == len(targets):
+		return ControlOnline, probes
+	case reachable == 0:
+		return ControlOffline, probes
+	default:
+		return ControlDegraded, probes
+	}
+}
+
+func printControl(status ControlStatus, probes []ProbeResult) {
+	var failed []string
+	for _, probe := range probes {
+		if !probe.Success {
+			failed = append(failed, fmt.Sprintf("%s (%s)", hostOnly(probe.Domain), classifyError(probe.Err)))
+		}
+	}
+
+	switch status {
+	case ControlOffline:
+		fmt.Printf("%sNone of control domains is reachable: %s. Check internet connection.%s\n",
+			colorRed, strings.Join(failed, ", "), colorReset)
+	case ControlDegraded:
+		fmt.Printf("%sSome of control domains aren't reachable: %s. Connection may be unstable, results may be inaccurate.%s\n",
+			colorRed, strings.Join(failed, ", "), colorReset)
+	}
+}
+
+// waitForConnection polls control targets until at least one of them becomes
+// reachable or timeout expires
+func waitForConnection(targets []string, probeTimeout, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if status, _ := checkControl(targets, probeTimeout); status != ControlOffline {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
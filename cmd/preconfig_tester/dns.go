@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Public DNS-over-HTTPS resolvers, addressed by IP so checking them doesn't
+// depend on system resolver which may be tampered
+var defaultDoHResolvers = []string{
+	"https://1.1.1.1/dns-query",
+	"https://8.8.8.8/dns-query",
+}
+
+// DNSStatus is verdict of comparing system resolver with DoH resolver
+type DNSStatus int
+
+const (
+	DNSConsistent DNSStatus = iota
+	DNSPoisoned
+	DNSBlocked
+	DNSUnverified
+)
+
+func (s DNSStatus) String() string {
+	switch s {
+	case DNSConsistent:
+		return "consistent"
+	case DNSPoisoned:
+		return "poisoned"
+	case DNSBlocked:
+		return "blocked"
+	case DNSUnverified:
+		return "unverified"
+	default:
+		return "unknown"
+	}
+}
+
+// DNSCheckResult holds addresses returned by both resolvers and verdict
+type DNSCheckResult struct {
+	Domain          string
+	Status          DNSStatus
+	SystemAddrs     []string
+	ResolverAddrs   []string
+	Resolver        string
+	Reason          string
+	SystemErr       error
+	ResolverErr     error
+	CertificateErr  error
+	CertificateAddr string
+}
+
+// DNSChecker compares system resolver answers with trusted DoH resolver.
+// All dependencies are fields, so they can be replaced by local stand-ins.
+type DNSChecker struct {
+	Resolvers  []string
+	Client     *http.Client
+	Timeout    time.Duration
+	LookupHost func(ctx context.Context, host string) ([]string, error)
+	// VerifyCert checks whether addr serves valid certificate for domain. It's
+	// used to tell CDN geo-balancing from spoofed answers.
+	VerifyCert func(ctx context.Context, addr, domain string) error
+}
+
+func newDNSChecker(resolvers []string, timeout time.Duration) *DNSChecker {
+	return &DNSChecker{
+		Resolvers:  resolvers,
+		Client:     &http.Client{Timeout: timeout},
+		Timeout:    timeout,
+		LookupHost: net.DefaultResolver.LookupHost,
+		VerifyCert: verifyCertificate,
+	}
+}
+
+// Check resolves domain with system resolver and every DoH resolver until one
+// of them answers, and compares results
+func (c *DNSChecker) Check(domain string) DNSCheckResult {
+	result := DNSCheckResult{Domain: domain}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+
+	result.SystemAddrs, result.SystemErr = c.LookupHost(ctx, domain)
+	sort.Strings(result.SystemAddrs)
+
+	for _, resolver := range c.Resolvers {
+		addrs, err := c.resolveDoH(ctx, resolver, domain)
+		if err != nil {
+			result.ResolverErr = err
+			continue
+		}
+		result.Resolver = resolver
+		result.ResolverAddrs = addrs
+		result.ResolverErr = nil
+		break
+	}
+
+	c.classify(ctx, &result)
+	return result
+}
+
+func (c *DNSChecker) classify(ctx context.Context, result *DNSCheckResult) {
+	if result.Resolver == "" {
+		result.Status = DNSUnverified
+		result.Reason = "no DoH resolver answered"
+		return
+	}
+	if len(result.ResolverAddrs) == 0 {
+		result.Status = DNSUnverified
+		result.Reason = "DoH resolver returned no addresses"
+		return
+	}
+
+	if result.SystemErr != nil || len(result.SystemAddrs) == 0 {
+		result.Status = DNSBlocked
+		result.Reason = "system resolver doesn't resolve domain, but DoH resolver does"
+		return
+	}
+
+	for _, addr := range result.SystemAddrs {
+		if isBogon(addr) {
+			result.Status = DNSPoisoned
+			result.Reason = fmt.Sprintf("system resolver returned non-public address %s", addr)
+			return
+		}
+	}
+
+	if intersects(result.SystemAddrs, result.ResolverAddrs) {
+		result.Status = DNSConsistent
+		return
+	}
+
+	// Different answers are normal for CDNs, so check whether address from
+	// system resolver really serves this domain
+	result.CertificateAddr = result.SystemAddrs[0]
+	result.CertificateErr = c.VerifyCert(ctx, result.CertificateAddr, result.Domain)
+	var certErr *tls.CertificateVerificationError
+	var hostErr x509.HostnameError
+	if errors.As(result.CertificateErr, &certErr) || errors.As(result.CertificateErr, &hostErr) {
+		result.Status = DNSPoisoned
+		result.Reason = fmt.Sprintf("%s from system resolver serves certificate for another host", result.CertificateAddr)
+		return
+	}
+
+	result.Status = DNSConsistent
+	result.Reason = "answers differ, but both serve valid certificate"
+}
+
+// resolveDoH queries A and AAAA records using RFC 8484 wire format
+func (c *DNSChecker) resolveDoH(ctx context.Context, resolver, domain string) ([]string, error) {
+	var addrs []string
+	for _, qtype := range []uint16{dnsTypeA, dnsTypeAAAA} {
+		query := buildDNSQuery(domain, qtype)
+		req, err := http.NewRequestWithContext(ctx, "POST", resolver, bytes.NewReader(query))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/dns-message")
+		req.Header.Set("Accept", "application/dns-message")
+
+		resp, err := c.Client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("DoH request failed: %v", err)
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read DoH response: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("DoH resolver returned status: %s", resp.Status)
+		}
+
+		answers, err := parseDNSResponse(body, qtype)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, answers...)
+	}
+	sort.Strings(addrs)
+	return addrs, nil
+}
+
+func verifyCertificate(ctx context.Context, addr, domain string) error {
+	dialer := &tls.Dialer{Config: &tls.Config{ServerName: domain}}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(addr, "443"))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+const (
+	dnsTypeA    = 1
+	dnsTypeAAAA = 28
+	dnsClassIN  = 1
+)
+
+func buildDNSQuery(domain string, qtype uint16) []byte {
+	var buf bytes.Buffer
+	header := make([]byte, 12)
+	binary.BigEndian.PutUint16(header[0:], uint16(rand.Intn(1<<16)))
+	binary.BigEndian.PutUint16(header[2:], 0x0100) // recursion desired
+	binary.BigEndian.PutUint16(header[4:], 1)      // one question
+	buf.Write(header)
+
+	for _, label := range strings.Split(strings.TrimSuffix(domain, "."), ".") {
+		buf.WriteByte(byte(len(label)))
+		buf.WriteString(label)
+	}
+	buf.WriteByte(0)
+
+	question := make([]byte, 4)
+	binary.BigEndian.PutUint16(question[0:], qtype)
+	binary.BigEndian.PutUint16(question[2:], dnsClassIN)
+	buf.Write(question)
+	return buf.Bytes()
+}
+
+// parseDNSResponse extracts addresses of given type from answer section
+func parseDNSResponse(msg []byte, qtype uint16) ([]string, error) {
+	if len(msg) < 12 {
+		return nil, fmt.Errorf("DNS response too short")
+	}
+	if rcode := msg[3] & 0x0f; rcode != 0 {
+		// NXDOMAIN and similar simply mean no addresses
+		return nil, nil
+	}
+	qdcount := int(binary.BigEndian.Uint16(msg[4:]))
+	ancount := int(binary.BigEndian.Uint16(msg[6:]))
+
+	offset := 12
+	for i := 0; i < qdcount; i++ {
+		next, err := skipDNSName(msg, offset)
+		if err != nil {
+			return nil, err
+		}
+		offset = next + 4
+	}
+
+	var addrs []string
+	for i := 0; i < ancount; i++ {
+		next, err := skipDNSName(msg, offset)
+		if err != nil {
+			return nil, err
+		}
+		if next+10 > len(msg) {
+			return nil, fmt.Errorf("DNS answer truncated")
+		}
+		rtype := binary.BigEndian.Uint16(msg[next:])
+		rdlength := int(binary.BigEndian.Uint16(msg[next+8:]))
+		rdata := next + 10
+		if rdata+rdlength > len(msg) {
+			return nil, fmt.Errorf("DNS answer truncated")
+		}
+		if rtype == qtype && (rdlength == net.IPv4len || rdlength == net.IPv6len) {
+			addrs = append(addrs, net.IP(msg[rdata:rdata+rdlength]).String())
+		}
+		offset = rdata + rdlength
+	}
+	return addrs, nil
+}
+
+func skipDNSName(msg []byte, offset int) (int, error) {
+	for {
+		if offset >= len(msg) {
+			return 0, fmt.Errorf("DNS name out of bounds")
+		}
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			return offset + 1, nil
+		case length&0xc0 == 0xc0:
+			// Compression pointer ends name
+			return offset + 2, nil
+		default:
+			offset += length + 1
+		}
+	}
+}
+
+// isBogon reports whether address can't belong to public server. ISPs often
+// answer with such stub addresses for blocked domains.
+func isBogon(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return true
+	}
+	return ip.IsUnspecified() || ip.IsLoopback() || ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() || ip.IsMulticast()
+}
+
+func intersects(a, b []string) bool {
+	for _, x := range a {
+		if contains(b, x) {
+			return true
+		}
+	}
+	return false
+}
+
+func printDNSCheck(result DNSCheckResult) {
+	switch result.Status {
+	case DNSPoisoned, DNSBlocked:
+		fmt.Printf("%sDNS answers for %s look tampered: %s.%s\n", colorRed, result.Domain, result.Reason, colorReset)
+		fmt.Printf("  System resolver: %s\n", formatAddrs(result.SystemAddrs, result.SystemErr))
+		fmt.Printf("  %s: %s\n", result.Resolver, formatAddrs(result.ResolverAddrs, nil))
+		fmt.Println("  Pre-configs can't fix this. Change DNS server or enable DNS-over-HTTPS in system settings.")
+	case DNSUnverified:
+		fmt.Printf("Couldn't verify DNS answers for %s: %s\n", result.Domain, result.Reason)
+	}
+}
+
+func formatAddrs(addrs []string, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	if len(addrs) == 0 {
+		return "no addresses"
+	}
+	return strings.Join(addrs, ", ")
+}
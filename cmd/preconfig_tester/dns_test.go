@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newDoHServer starts DoH resolver answering RFC 8484 wire queries from
+// records, domains without records get NXDOMAIN
+func newDoHServer(t *testing.T, records map[string][]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, err := io.ReadAll(r.Body)
+		if err != nil || r.Header.Get("Content-Type") != "application/dns-message" || len(query) < 12 {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		// Question is only one, name is written without compression
+		var labels []string
+		offset := 12
+		for query[offset] != 0 {
+			length := int(query[offset])
+			labels = append(labels, string(query[offset+1:offset+1+length]))
+			offset += length + 1
+		}
+		qtype := binary.BigEndian.Uint16(query[offset+1:])
+		question := query[12 : offset+5]
+
+		addrs, ok := records[strings.Join(labels, ".")]
+		response := append([]byte{}, query[:12]...)
+		binary.BigEndian.PutUint16(response[2:], 0x8180) // response, recursion available
+		if !ok {
+			response[3] |= 3 // NXDOMAIN
+		}
+		var answers []byte
+		count := 0
+		for _, addr := range addrs {
+			ip := net.ParseIP(addr)
+			rtype, data := uint16(dnsTypeAAAA), []byte(ip.To16())
+			if ip4 := ip.To4(); ip4 != nil {
+				rtype, data = dnsTypeA, ip4
+			}
+			if rtype != qtype {
+				continue
+			}
+			// Name is pointer to question
+			answer := []byte{0xc0, 12, 0, 0, 0, dnsClassIN, 0, 0, 0, 60, 0, 0}
+			binary.BigEndian.PutUint16(answer[2:], rtype)
+			binary.BigEndian.PutUint16(answer[10:], uint16(len(data)))
+			answers = append(append(answers, answer...), data...)
+			count++
+		}
+		binary.BigEndian.PutUint16(response[6:], uint16(count))
+		response = append(append(response, question...), answers...)
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(response)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDNSChecker(t *testing.T) {
+	doh := newDoHServer(t, map[string][]string{
+		"youtube.com": {"142.250.74.46", "2a00:1450:4010:c0e::5d"},
+		"discord.com": {"162.159.135.232"},
+	})
+	// Closed server stands for resolver blocked by ISP
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	hostnameErr := x509.HostnameError{Certificate: &x509.Certificate{}, Host: "youtube.com"}
+
+	tests := []struct {
+		name      string
+		domain    string
+		resolvers []string
+		system    []string
+		systemErr error
+		certErr   error
+		// certChecked means answers differ, so certificate must be checked
+		certChecked bool
+		status      DNSStatus
+		// reason is part of expected reason, empty if reason isn't checked
+		reason string
+	}{
+		{name: "consistent", domain: "youtube.com", system: []string{"142.250.74.46"}, status: DNSConsistent},
+		{name: "CDN with valid certificate", domain: "youtube.com", system: []string{"173.194.222.198"}, certChecked: true, status: DNSConsistent, reason: "both serve valid certificate"},
+		{name: "stub address", domain: "youtube.com", system: []string{"10.10.10.10"}, status: DNSPoisoned, reason: "non-public address"},
+		{name: "certificate mismatch", domain: "youtube.com", system: []string{"95.167.13.50"}, certErr: hostnameErr, certChecked: true, status: DNSPoisoned, reason: "certificate for another host"},
+		{name: "system resolver fails", domain: "discord.com", systemErr: errors.New("no such host"), status: DNSBlocked},
+		{name: "DoH unreachable", domain: "youtube.com", resolvers: []string{unreachable.URL}, system: []string{"142.250.74.46"}, status: DNSUnverified, reason: "no DoH resolver answered"},
+		{name: "next DoH resolver", domain: "youtube.com", resolvers: []string{unreachable.URL, doh.URL}, system: []string{"142.250.74.46"}, status: DNSConsistent},
+		{name: "NXDOMAIN", domain: "missing.example", system: []string{"142.250.74.46"}, status: DNSUnverified, reason: "returned no addresses"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolvers := test.resolvers
+			if resolvers == nil {
+				resolvers = []string{doh.URL}
+			}
+			checker := newDNSChecker(resolvers, 2*time.Second)
+			checker.LookupHost = func(ctx context.Context, host string) ([]string, error) {
+				if host != test.domain {
+					t.Errorf("system resolver asked for %s", host)
+				}
+				return test.system, test.systemErr
+			}
+			var verified []string
+			checker.VerifyCert = func(ctx context.Context, addr, domain string) error {
+				verified = append(verified, addr+" "+domain)
+				return test.certErr
+			}
+
+			result := checker.Check(test.domain)
+			if result.Status != test.status {
+				t.Errorf("status = %s (%s), want %s", result.Status, result.Reason, test.status)
+			}
+			if !strings.Contains(result.Reason, test.reason) {
+				t.Errorf("reason = %q, want %q", result.Reason, test.reason)
+			}
+			if test.certChecked != (len(verified) > 0) {
+				t.Errorf("certificates checked: %v", verified)
+			}
+			if result.Resolver != "" && result.Resolver != doh.URL {
+				t.Errorf("answer from %s, want %s", result.Resolver, doh.URL)
+			}
+		})
+	}
+}
+
+func TestResolveDoH(t *testing.T) {
+	doh := newDoHServer(t, map[string][]string{"youtube.com": {"142.250.74.46", "2a00:1450:4010:c0e::5d", "142.250.74.78"}})
+	checker := newDNSChecker([]string{doh.URL}, 2*time.Second)
+
+	addrs, err := checker.resolveDoH(context.Background(), doh.URL, "youtube.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"142.250.74.46", "142.250.74.78", "2a00:1450:4010:c0e::5d"}; !reflect.DeepEqual(addrs, want) {
+		t.Errorf("addresses = %v, want %v", addrs, want)
+	}
+
+	addrs, err = checker.resolveDoH(context.Background(), doh.URL, "missing.example")
+	if err != nil || len(addrs) != 0 {
+		t.Errorf("NXDOMAIN gave %v, %v", addrs, err)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ankddev/zapret-discord-youtube/internal/winws"
+)
+
+// buildFakeWinws builds fake_winws and makes tools run it instead of winws.
+// It returns path of file every run of fake winws is logged to.
+func buildFakeWinws(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "fake_winws")
+	if runtime.GOOS == "windows" {
+		path += ".exe"
+	}
+	build := exec.Command("go", "build", "-o", path, "github.com/ankddev/zapret-discord-youtube/cmd/fake_winws")
+	if output, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("building fake_winws failed: %v\n%s", err, output)
+	}
+	logFile := filepath.Join(dir, "winws.jsonl")
+	t.Setenv(winws.EnvPath, path)
+	t.Setenv("FAKE_WINWS_LOG", logFile)
+	return logFile
+}
+
+// fakeWinwsRun is part of run record of fake winws checked by tests
+type fakeWinwsRun struct {
+	Args  []string `json:"args"`
+	Mode  string   `json:"mode"`
+	Error string   `json:"error"`
+}
+
+func readFakeWinwsLog(t *testing.T, path string) []fakeWinwsRun {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var runs []fakeWinwsRun
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var run fakeWinwsRun
+		if err := json.Unmarshal(scanner.Bytes(), &run); err != nil {
+			t.Fatal(err)
+		}
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+// TestFakeWinws runs tester with real supervisor, which starts fake winws
+// with options of every pre-config like it starts winws.exe
+func TestFakeWinws(t *testing.T) {
+	winwsLog := buildFakeWinws(t)
+	script := filepath.Join(t.TempDir(), "script.txt")
+	if err := os.WriteFile(script, []byte("dpi-desync=syndata crash\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FAKE_WINWS_SCRIPT", script)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	rootCAs = pool
+	t.Cleanup(func() { rootCAs = nil })
+
+	supervisor := newWindowsSupervisor(winws.ProcessName())
+	// Fake winws is started directly, so process list is only asked for
+	// foreign winws, and there is no PowerShell outside Windows
+	supervisor.list = func() ([]WinwsProcess, error) { return nil, nil }
+	supervisor.output = &strings.Builder{}
+	config := testConfig(t, nil)
+	config.supervisor = supervisor
+	config.targetDomain = server.Listener.Addr().String()
+
+	preconfigs := []struct{ name, options string }{
+		{"1 unknown option", "--wf-tcp=443 --dpi-desync=fake --dpi-desync-foo=1"},
+		{"2 crash", "--wf-tcp=443 --dpi-desync=syndata"},
+		{"3 works", "--wf-tcp=443 --filter-tcp=443 --dpi-desync=fake --new --filter-udp=443 --dpi-desync=fake"},
+		{"4 not tested", "--wf-tcp=443 --dpi-desync=fake"},
+	}
+	if err := os.MkdirAll(config.batchDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range preconfigs {
+		content := "@echo off\r\nset BIN=%~dp0..\\bin\\\r\nstart \"zapret\" /min \"%BIN%winws.exe\" " + p.options + "\r\n"
+		if err := os.WriteFile(filepath.Join(config.batchDir, p.name+".bat"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	outcome, err := runBypassCheck(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outcome != OutcomeFound {
+		t.Errorf("outcome = %v, want %v", outcome, OutcomeFound)
+	}
+
+	runs := readFakeWinwsLog(t, winwsLog)
+	if len(runs) != 3 {
+		t.Fatalf("fake winws ran %d times, want 3: %+v", len(runs), runs)
+	}
+	for i, run := range runs {
+		if args := strings.Join(run.Args, " "); args != preconfigs[i].options {
+			t.Errorf("%s: fake winws got %q", preconfigs[i].name, args)
+		}
+	}
+	if !strings.Contains(runs[0].Error, "unrecognized option '--dpi-desync-foo'") {
+		t.Errorf("unknown option isn't rejected: %+v", runs[0])
+	}
+	if runs[1].Mode != "crash" || runs[2].Mode != "start" {
+		t.Errorf("modes are %q and %q, want crash and start", runs[1].Mode, runs[2].Mode)
+	}
+
+	// Output of winws that didn't start is kept in logs of run
+	logs, err := filepath.Glob(filepath.Join(config.reportDir, "*-logs", "*.log"))
+	if err != nil || len(logs) != 3 {
+		t.Fatalf("found logs %v, %v, want 3", logs, err)
+	}
+	for i, want := range []string{"unrecognized option", "Access is denied", "capture is started"} {
+		log, err := os.ReadFile(filepath.Join(filepath.Dir(logs[0]), preconfigs[i].name+".log"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(log), want) {
+			t.Errorf("log of %s doesn't contain %q:\n%s", preconfigs[i].name, want, log)
+		}
+	}
+}
+
+// TestStopWhileStarting stops supervisor from other goroutine during grace
+// period of winws, like signal handler does
+func TestStopWhileStarting(t *testing.T) {
+	winwsLog := buildFakeWinws(t)
+	dir := t.TempDir()
+	batFile := writePreconfigs(t, filepath.Join(dir, "pre-configs"), "general")[0]
+
+	supervisor := newWindowsSupervisor(winws.ProcessName())
+	supervisor.list = func() ([]WinwsProcess, error) { return nil, nil }
+	started := make(chan error)
+	go func() {
+		_, err := supervisor.Start(batFile, filepath.Join(dir, "general.log"), time.Second)
+		started <- err
+	}()
+
+	// Wait for fake winws to start, it logs run right away
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if _, err := os.Stat(winwsLog); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("fake winws didn't start")
+		}
+	}
+	if err := supervisor.Stop(); err != nil {
+		t.Errorf("Stop: %v", err)
+	}
+	if err := <-started; err != errStopped {
+		t.Errorf("Start error = %v, want %v", err, errStopped)
+	}
+	if err := supervisor.Stop(); err != nil {
+		t.Errorf("second Stop: %v", err)
+	}
+}
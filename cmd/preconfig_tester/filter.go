@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ankddev/zapret-discord-youtube/internal/preconfig"
+)
+
+// PreconfigFilter selects pre-configs to test. Empty filter selects all of
+// them. Pre-config is tested when it matches every non-empty include
+// criterion and none of exclude globs.
+type PreconfigFilter struct {
+	Include  []string // globs of file names, any of them must match
+	Exclude  []string // globs of file names, none of them may match
+	Families []string // family prefixes, like "youtube" for "YoutubeFix"
+	Tags     []string // tags from lists pre-config uses or from ":: tags:" comment
+	// Shortlist is file with names of pre-configs, one per line
+	Shortlist string
+}
+
+// Default file interactive mode saves shortlist to
+var defaultShortlistFile = filepath.Join("lists", "preconfig-shortlist.txt")
+
+func (f PreconfigFilter) IsEmpty() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0 && len(f.Families) == 0 &&
+		len(f.Tags) == 0 && f.Shortlist == ""
+}
+
+// String describes filter, it's also part of checkpoint key
+func (f PreconfigFilter) String() string {
+	if f.IsEmpty() {
+		return "all"
+	}
+	var parts []string
+	add := func(name string, values []string) {
+		if len(values) > 0 {
+			parts = append(parts, name+"="+strings.Join(values, ","))
+		}
+	}
+	add("include", f.Include)
+	add("exclude", f.Exclude)
+	add("family", f.Families)
+	add("tag", f.Tags)
+	if f.Shortlist != "" {
+		parts = append(parts, "shortlist="+f.Shortlist)
+	}
+	return strings.Join(parts, " ")
+}
+
+// validate checks globs, so typo doesn't silently filter out everything
+func (f PreconfigFilter) validate() error {
+	for _, pattern := range append(append([]string{}, f.Include...), f.Exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pre-config glob '%s': %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Match reports whether pre-config passes filter. Shortlist holds names
+// from shortlist file, nil when filter has no shortlist.
+func (f PreconfigFilter) Match(p *preconfig.Preconfig, shortlist map[string]bool) bool {
+	file := p.Name + ".bat"
+	if len(f.Include) > 0 && !matchAnyGlob(f.Include, file) {
+		return false
+	}
+	if matchAnyGlob(f.Exclude, file) {
+		return false
+	}
+	if len(f.Families) > 0 {
+		matched := false
+		for _, family := range f.Families {
+			if strings.HasPrefix(strings.ToLower(p.Family), strings.ToLower(family)) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(f.Tags) > 0 {
+		matched := false
+		for _, tag := range f.Tags {
+			if p.HasTag(tag) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if shortlist != nil && !shortlist[p.Name] {
+		return false
+	}
+	return true
+}
+
+func matchAnyGlob(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		// Globs are case-insensitive like file names on Windows
+		if matched, _ := filepath.Match(strings.ToLower(pattern), strings.ToLower(name)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList splits comma separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// loadShortlist reads pre-config names from file. Names may be written with
+// or without .bat extension, lines starting with # are ignored.
+func loadShortlist(path string) (map[string]bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening shortlist: %v", err)
+	}
+	defer file.Close()
+
+	names := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names[strings.TrimSuffix(line, ".bat")] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading shortlist: %v", err)
+	}
+	return names, nil
+}
+
+// saveShortlist writes pre-config names to file, one per line
+func saveShortlist(path string, preconfigs []*preconfig.Preconfig) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating shortlist directory: %v", err)
+	}
+	var content strings.Builder
+	content.WriteString("# Pre-configs to test, one per line\n")
+	for _, p := range preconfigs {
+		content.WriteString(p.Name + "\n")
+	}
+	return os.WriteFile(path, []byte(content.String()), 0644)
+}
+
+// loadPreconfigs parses every pre-config in directory. Pre-config that can't
+// be parsed is still returned with name and family, so it can be tested and
+// filtered by name.
+func loadPreconfigs(dir string) ([]*preconfig.Preconfig, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading batch directory: %v", err)
+	}
+
+	var preconfigs []*preconfig.Preconfig
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".bat") {
+			continue
+		}
+		path := filepath.Join(dir, f.Name())
+		p, err := preconfig.Load(path)
+		if err != nil {
+			name := strings.TrimSuffix(f.Name(), ".bat")
+			p = &preconfig.Preconfig{Path: path, Name: name, Family: preconfig.FamilyOf(name)}
+		}
+		preconfigs = append(preconfigs, p)
+	}
+	return preconfigs, nil
+}
+
+// filterPreconfigs returns pre-configs from directory that pass filter
+func filterPreconfigs(dir string, filter PreconfigFilter) ([]*preconfig.Preconfig, error) {
+	preconfigs, err := loadPreconfigs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var shortlist map[string]bool
+	if filter.Shortlist != "" {
+		if shortlist, err = loadShortlist(filter.Shortlist); err != nil {
+			return nil, err
+		}
+	}
+
+	var selected []*preconfig.Preconfig
+	for _, p := range preconfigs {
+		if filter.Match(p, shortlist) {
+			selected = append(selected, p)
+		}
+	}
+	return selected, nil
+}
+
+// countBy groups pre-configs by key and returns keys sorted by name with
+// number of pre-configs for every key
+func countBy(preconfigs []*preconfig.Preconfig, keys func(*preconfig.Preconfig) []string) ([]string, map[string]int) {
+	counts := make(map[string]int)
+	for _, p := range preconfigs {
+		for _, key := range keys(p) {
+			counts[key]++
+		}
+	}
+	var names []string
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, counts
+}
+
+// getFilterChoice asks which pre-configs to test
+func getFilterChoice(config Config) (PreconfigFilter, error) {
+	preconfigs, err := loadPreconfigs(config.batchDir)
+	if err != nil {
+		return PreconfigFilter{}, err
+	}
+	_, shortlistErr := os.Stat(defaultShortlistFile)
+	hasShortlist := shortlistErr == nil
+
+	fmt.Println("\nSelect pre-configs to test:")
+	fmt.Printf("1. All pre-configs (%d)\n", len(preconfigs))
+	fmt.Println("2. By family")
+	fmt.Println("3. By tag")
+	fmt.Println("4. By name pattern")
+	if hasShortlist {
+		fmt.Printf("5. From shortlist (%s)\n", defaultShortlistFile)
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	var filter PreconfigFilter
+	for {
+		fmt.Print("\nEnter number of variant: ")
+		choice, err := reader.ReadString('\n')
+		if err != nil {
+			return filter, fmt.Errorf("error reading input: %v", err)
+		}
+
+		switch strings.TrimSpace(choice) {
+		case "1":
+			return filter, nil
+		case "2":
+			filter.Families, err = choose(reader, "families", preconfigs, func(p *preconfig.Preconfig) []string {
+				return []string{p.Family}
+			})
+		case "3":
+			filter.Tags, err = choose(reader, "tags", preconfigs, func(p *preconfig.Preconfig) []string {
+				return p.Tags
+			})
+		case "4":
+			fmt.Print("Enter name patterns separated by commas (for example, YoutubeFix*,*MGTS*): ")
+			var patterns string
+			if patterns, err = reader.ReadString('\n'); err == nil {
+				filter.Include = splitList(patterns)
+			}
+		case "5":
+			if hasShortlist {
+				filter.Shortlist = defaultShortlistFile
+				return filter, nil
+			}
+			fallthrough
+		default:
+			fmt.Println("Invalid selection. Please select one of listed numbers")
+			continue
+		}
+		if err != nil {
+			return filter, err
+		}
+
+		fmt.Print("Enter name patterns to exclude separated by commas (empty for none): ")
+		exclude, err := reader.ReadString('\n')
+		if err != nil {
+			return filter, fmt.Errorf("error reading input: %v", err)
+		}
+		filter.Exclude = splitList(exclude)
+		if err := filter.validate(); err != nil {
+			fmt.Println(err)
+			filter = PreconfigFilter{}
+			continue
+		}
+
+		selected, err := filterPreconfigs(config.batchDir, filter)
+		if err != nil {
+			return filter, err
+		}
+		if len(selected) == 0 {
+			fmt.Println("No pre-configs match selection, try again")
+			filter = PreconfigFilter{}
+			continue
+		}
+		fmt.Printf("Selected %d of %d pre-configs.\n", len(selected), len(preconfigs))
+		askSaveShortlist(reader, selected)
+		return filter, nil
+	}
+}
+
+// choose lists values with number of pre-configs and asks to pick some of them
+func choose(reader *bufio.Reader, what string, preconfigs []*preconfig.Preconfig, keys func(*preconfig.Preconfig) []string) ([]string, error) {
+	names, counts := countBy(preconfigs, keys)
+	fmt.Println()
+	for i, name := range names {
+		fmt.Printf("%d. %s (%d)\n", i+1, name, counts[name])
+	}
+
+	for {
+		fmt.Printf("\nEnter numbers of %s separated by spaces: ", what)
+		input, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("error reading input: %v", err)
+		}
+
+		var chosen []string
+		valid := true
+		for _, field := range strings.Fields(input) {
+			n, err := strconv.Atoi(field)
+			if err != nil || n < 1 || n > len(names) {
+				valid = false
+				break
+			}
+			chosen = append(chosen, names[n-1])
+		}
+		if valid && len(chosen) > 0 {
+			return chosen, nil
+		}
+		fmt.Printf("Invalid selection. Please select numbers from 1 to %d\n", len(names))
+	}
+}
+
+func askSaveShortlist(reader *bufio.Reader, selected []*preconfig.Preconfig) {
+	fmt.Printf("Save selection as shortlist to %s? (y/N): ", defaultShortlistFile)
+	answer, _ := reader.ReadString('\n')
+	if !strings.EqualFold(strings.TrimSpace(answer), "y") {
+		return
+	}
+	if err := saveShortlist(defaultShortlistFile, selected); err != nil {
+		fmt.Printf("Error saving shortlist: %v\n", err)
+		return
+	}
+	fmt.Println("Shortlist saved. Next time select it with \"From shortlist\".")
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Service used to find out public IP and ASN of network. It's asked only when
+// user allows it, as it learns public IP of user.
+const networkInfoURL = "https://ipinfo.io/json"
+
+// NetworkInfo identifies network the tester runs in. Results of pre-configs
+// depend on ISP, so history is kept per network.
+type NetworkInfo struct {
+	Gateway     string `json:"gateway"`
+	ASN         string `json:"asn"`
+	Org         string `json:"org"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+func (n NetworkInfo) String() string {
+	var parts []string
+	if n.Org != "" {
+		parts = append(parts, n.Org)
+	} else if n.ASN != "" {
+		parts = append(parts, n.ASN)
+	}
+	if n.Gateway != "" {
+		parts = append(parts, "gateway "+n.Gateway)
+	}
+	if len(parts) == 0 {
+		return "unknown network"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// HistoryRecord is single line of history file: result of one domain with one
+// pre-config in one run
+type HistoryRecord struct {
+	RunID          string    `json:"run_id"`
+	Time           time.Time `json:"time"`
+	Network        string    `json:"network"`
+	Gateway        string    `json:"gateway,omitempty"`
+	ASN            string    `json:"asn,omitempty"`
+	Preconfig      string    `json:"preconfig"`
+	Domain         string    `json:"domain"`
+	Started        bool      `json:"started"`
+	Trials         int       `json:"trials"`
+	Successes      int       `json:"successes"`
+	AvgHandshakeMs float64   `json:"avg_handshake_ms"`
+	Speed          float64   `json:"speed_bytes_per_sec,omitempty"`
+	Worked         bool      `json:"worked"`
+}
+
+// Network is detected once per run, history is shown and saved for the same one
+var (
+	networkMu     sync.Mutex
+	cachedNetwork *NetworkInfo
+)
+
+// detectNetwork collects default gateway and, if lookupASN is set, public ASN.
+// Both are optional: when nothing is detected all results go to "unknown"
+// network.
+func detectNetwork(timeout time.Duration, lookupASN bool) NetworkInfo {
+	networkMu.Lock()
+	defer networkMu.Unlock()
+	if cachedNetwork != nil {
+		return *cachedNetwork
+	}
+
+	info := NetworkInfo{Gateway: detectGateway()}
+	if lookupASN {
+		info.ASN, info.Org = detectASN(timeout)
+	}
+
+	if info.Gateway == "" && info.ASN == "" {
+		info.Fingerprint = "unknown"
+	} else {
+		sum := sha256.Sum256([]byte(info.Gateway + "|" + info.ASN))
+		info.Fingerprint = hex.EncodeToString(sum[:6])
+	}
+	cachedNetwork = &info
+	return info
+}
+
+// matches reports whether record was saved in this network. ASN identifies
+// ISP and is compared when both have it, otherwise gateway is, so failed or
+// disabled ASN lookup doesn't split history into another network.
+func (n NetworkInfo) matches(record HistoryRecord) bool {
+	switch {
+	case n.ASN != "" && record.ASN != "":
+		return n.ASN == record.ASN
+	case n.Gateway != "" && record.Gateway != "":
+		return n.Gateway == record.Gateway
+	}
+	return n.Fingerprint == record.Network
+}
+
+func detectGateway() string {
+	if runtime.GOOS == "windows" {
+		output, err := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command",
+			"(Get-NetRoute -DestinationPrefix '0.0.0.0/0' | Sort-Object RouteMetric | Select-Object -First 1).NextHop").Output()
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(output))
+	}
+
+	// Linux keeps routes in hex, little-endian
+	content, err := os.ReadFile("/proc/net/route")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(content), "\n")[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+		var gw uint32
+		if _, err := fmt.Sscanf(fields[2], "%x", &gw); err != nil {
+			continue
+		}
+		return net.IPv4(byte(gw), byte(gw>>8), byte(gw>>16), byte(gw>>24)).String()
+	}
+	return ""
+}
+
+func detectASN(timeout time.Duration) (asn, org string) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", networkInfoURL, nil)
+	if err != nil {
+		return "", ""
+	}
+	req.Header.Set("User-Agent", "zapret-discord-youtube-tester")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", ""
+	}
+	defer resp.Body.Close()
+
+	var info struct {
+		Org string `json:"org"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return "", ""
+	}
+
+	// Org looks like "AS12389 PJSC Rostelecom"
+	asn, _, _ = strings.Cut(info.Org, " ")
+	if !strings.HasPrefix(asn, "AS") {
+		return "", info.Org
+	}
+	return asn, info.Org
+}
+
+// appendHistory saves result of pre-config to history file, one line per
+// tested domain. Pre-config whose winws didn't start gets failed line for
+// every domain too, so it lowers its trend.
+func appendHistory(path string, runID string, network NetworkInfo, result *PreconfigResult, domains []string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating history directory: %v", err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening history file: %v", err)
+	}
+	defer file.Close()
+
+	stats := make(map[string]DomainStats)
+	for _, s := range result.DomainStats() {
+		stats[s.Domain] = s
+	}
+
+	now := time.Now()
+	encoder := json.NewEncoder(file)
+	for _, domain := range domains {
+		s := stats[domain]
+		record := HistoryRecord{
+			RunID:          runID,
+			Time:           now,
+			Network:        network.Fingerprint,
+			Gateway:        network.Gateway,
+			ASN:            network.ASN,
+			Preconfig:      result.Name,
+			Domain:         hostOnly(domain),
+			Started:        result.Started,
+			Trials:         s.Trials,
+			Successes:      s.Successes,
+			AvgHandshakeMs: milliseconds(result.AverageTimings(domain).Handshake),
+			Speed:          result.Speed(),
+			Worked:         result.Started && s.Trials > 0 && s.Successes == s.Trials && result.ThroughputRecovered(),
+		}
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("error writing history: %v", err)
+		}
+	}
+	return nil
+}
+
+// loadHistory reads all records of network since given time. Broken lines are
+// skipped, so partially written file doesn't break history.
+func loadHistory(path string, network NetworkInfo, since time.Time) ([]HistoryRecord, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening history file: %v", err)
+	}
+	defer file.Close()
+
+	var records []HistoryRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record HistoryRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		if !network.matches(record) || record.Time.Before(since) {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// PreconfigTrend summarizes how often pre-config worked in past runs
+type PreconfigTrend struct {
+	Preconfig string
+	Runs      int
+	Worked    int
+	LastRun   time.Time
+	Domains   []string
+}
+
+// Ratio returns share of runs where pre-config worked for all tested domains
+func (t PreconfigTrend) Ratio() float64 {
+	if t.Runs == 0 {
+		return 0
+	}
+	return float64(t.Worked) / float64(t.Runs)
+}
+
+// historyTrends groups records by pre-config and run. Pre-config counts as
+// worked in run only when it worked for every domain tested in that run.
+func historyTrends(records []HistoryRecord) []PreconfigTrend {
+	type runKey struct{ preconfig, run string }
+	worked := make(map[runKey]bool)
+	trends := make(map[string]*PreconfigTrend)
+
+	for _, record := range records {
+		trend, ok := trends[record.Preconfig]
+		if !ok {
+			trend = &PreconfigTrend{Preconfig: record.Preconfig}
+			trends[record.Preconfig] = trend
+		}
+		if record.Time.After(trend.LastRun) {
+			trend.LastRun = record.Time
+		}
+		if !contains(trend.Domains, record.Domain) {
+			trend.Domains = append(trend.Domains, record.Domain)
+		}
+
+		key := runKey{record.Preconfig, record.RunID}
+		if prev, seen := worked[key]; seen {
+			worked[key] = prev && record.Worked
+		} else {
+			worked[key] = record.Worked
+		}
+	}
+
+	for key, ok := range worked {
+		trends[key.preconfig].Runs++
+		if ok {
+			trends[key.preconfig].Worked++
+		}
+	}
+
+	var result []PreconfigTrend
+	for _, trend := range trends {
+		sort.Strings(trend.Domains)
+		result = append(result, *trend)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Ratio() != result[j].Ratio() {
+			return result[i].Ratio() > result[j].Ratio()
+		}
+		if result[i].Runs != result[j].Runs {
+			return result[i].Runs > result[j].Runs
+		}
+		return result[i].Preconfig < result[j].Preconfig
+	})
+	return result
+}
+
+// printHistory shows trends of pre-configs on current network. Limit of 0
+// shows all pre-configs.
+func printHistory(config Config, network NetworkInfo, limit int) error {
+	since := time.Now().AddDate(0, 0, -config.historyDays)
+	records, err := loadHistory(config.historyFile, network, since)
+	if err != nil {
+		return err
+	}
+
+	trends := historyTrends(records)
+	if len(trends) == 0 {
+		fmt.Printf("\nNo results on this network (%s) in the past %d days.\n", network, config.historyDays)
+		return nil
+	}
+
+	fmt.Printf("\nResults on this network (%s) in the past %d days:\n", network, config.historyDays)
+	for i, trend := range trends {
+		if limit > 0 && i >= limit {
+			break
+		}
+		color := colorRed
+		if trend.Worked > 0 {
+			color = colorGreen
+		}
+		fmt.Printf("%s%s worked %d/%d times (last run %s, domains: %s)%s\n", color, trend.Preconfig,
+			trend.Worked, trend.Runs, trend.LastRun.Format("2006-01-02"), strings.Join(trend.Domains, ", "), colorReset)
+	}
+	return nil
+}
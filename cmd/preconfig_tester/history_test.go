@@ -0,0 +1,73 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNetworkMatches(t *testing.T) {
+	network := NetworkInfo{Gateway: "192.168.1.1", ASN: "AS12389", Fingerprint: "aaaaaaaaaaaa"}
+	noASN := NetworkInfo{Gateway: "192.168.1.1", Fingerprint: "bbbbbbbbbbbb"}
+
+	tests := []struct {
+		name    string
+		network NetworkInfo
+		record  HistoryRecord
+		matches bool
+	}{
+		{name: "same ASN, other router", network: network, record: HistoryRecord{Gateway: "10.0.0.1", ASN: "AS12389"}, matches: true},
+		{name: "other ASN", network: network, record: HistoryRecord{Gateway: "192.168.1.1", ASN: "AS8359"}},
+		// Lookup failed in one of runs, so only gateways are compared
+		{name: "ASN lookup failed now", network: noASN, record: HistoryRecord{Gateway: "192.168.1.1", ASN: "AS12389"}, matches: true},
+		{name: "ASN lookup failed before", network: network, record: HistoryRecord{Gateway: "192.168.1.1"}, matches: true},
+		{name: "other gateway without ASN", network: noASN, record: HistoryRecord{Gateway: "192.168.0.1"}},
+		// Records saved before gateway was kept have only fingerprint
+		{name: "old record", network: network, record: HistoryRecord{Network: "aaaaaaaaaaaa"}, matches: true},
+		{name: "old record of other network", network: network, record: HistoryRecord{Network: "cccccccccccc"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if matches := test.network.matches(test.record); matches != test.matches {
+				t.Errorf("matches = %v, want %v", matches, test.matches)
+			}
+		})
+	}
+}
+
+func TestHistoryOfFailedStart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	network := NetworkInfo{Gateway: "192.168.1.1", Fingerprint: "aaaaaaaaaaaa"}
+	domains := []string{"youtube.com", "discord.com"}
+
+	worked := &PreconfigResult{Name: "general.bat", Started: true}
+	for _, domain := range domains {
+		worked.Probes = append(worked.Probes, ProbeResult{Domain: domain, Trial: 1, Success: true})
+	}
+	failed := &PreconfigResult{Name: "general.bat", StartError: "winws.exe exited right after start"}
+
+	if err := appendHistory(path, "run 1", network, worked, domains); err != nil {
+		t.Fatal(err)
+	}
+	if err := appendHistory(path, "run 2", network, failed, domains); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := loadHistory(path, network, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("got %d records, want 4", len(records))
+	}
+	for _, record := range records[2:] {
+		if record.Started || record.Worked || record.Trials != 0 {
+			t.Errorf("record of failed start = %+v", record)
+		}
+	}
+
+	trends := historyTrends(records)
+	if len(trends) != 1 || trends[0].Runs != 2 || trends[0].Worked != 1 {
+		t.Errorf("trends = %+v, want 1 of 2 runs worked", trends)
+	}
+}
@@ -10,8 +10,11 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
+
+	"github.com/ankddev/zapret-discord-youtube/internal/winws"
 )
 
 const (
@@ -32,6 +35,8 @@ const (
 	bufferSize = 4096
 )
 
+var version string
+
 var domainList = []struct {
 	number string
 	domain string
@@ -50,8 +55,67 @@ type Config struct {
 	batchDir          string
 	targetDomain      string
 	processName       string
+	supervisor        Supervisor
+	dpiCheck          func(domain string) (DPITestResult, error)
 	processWaitTime   time.Duration
 	connectionTimeout time.Duration
+	trials            int
+	sweep             bool
+	reportDir         string
+	reportFormats     []string
+	filter            PreconfigFilter
+	stateFile         string
+	resume            bool
+	dohResolvers      []string
+	historyFile       string
+	lookupASN         bool
+	historyDays       int
+	controlTargets    []string
+	signaturesFile    string
+	outageWait        time.Duration
+
+	// Strategy search generates pre-configs instead of testing shipped ones
+	search              bool
+	searchBudget        time.Duration
+	searchMaxCandidates int
+	searchSave          int
+	searchList          string
+
+	// Throughput probe is disabled when URL is empty
+	throughputURL      string
+	throughputBytes    int64
+	throughputMinSpeed float64
+	throughputTimeout  time.Duration
+}
+
+func defaultConfig() Config {
+	return Config{
+		batchDir:          "pre-configs",
+		processName:       winws.ProcessName(),
+		supervisor:        newWindowsSupervisor(winws.ProcessName()),
+		dpiCheck:          checkDPIFingerprint,
+		processWaitTime:   10 * time.Second,
+		connectionTimeout: 5 * time.Second,
+		trials:            3,
+		reportDir:         "reports",
+		reportFormats:     reportFormats,
+		stateFile:         filepath.Join("reports", "preconfig-tester-state.json"),
+		dohResolvers:      defaultDoHResolvers,
+		historyFile:       filepath.Join("reports", "history.jsonl"),
+		historyDays:       30,
+		controlTargets:    defaultControlTargets,
+		signaturesFile:    filepath.Join("lists", "stub-signatures.txt"),
+		outageWait:        2 * time.Minute,
+
+		searchBudget:        30 * time.Minute,
+		searchMaxCandidates: 60,
+		searchSave:          3,
+		searchList:          "list-ultimate.txt",
+
+		throughputBytes:    10 * 1024 * 1024,
+		throughputMinSpeed: 500 * 1024,
+		throughputTimeout:  30 * time.Second,
+	}
 }
 
 type DPITestResult int
@@ -60,6 +124,8 @@ const (
 	NoDPI DPITestResult = iota
 	HasDPI
 	NoConnection
+	DNSTampering
+	BlockedByStub
 )
 
 func (r DPITestResult) String() string {
@@ -70,22 +136,24 @@ func (r DPITestResult) String() string {
 		return "DPI blocks detected"
 	case NoConnection:
 		return "No connection"
+	case DNSTampering:
+		return "DNS tampering detected"
+	case BlockedByStub:
+		return "Blocked by ISP stub page"
 	default:
 		return "Unknown"
 	}
 }
 
 func (c *Config) getBatchFiles() ([]string, error) {
-	var batFiles []string
-	files, err := os.ReadDir(c.batchDir)
+	preconfigs, err := filterPreconfigs(c.batchDir, c.filter)
 	if err != nil {
-		return nil, fmt.Errorf("error reading batch directory: %v", err)
+		return nil, err
 	}
 
-	for _, f := range files {
-		if !f.IsDir() && strings.HasSuffix(f.Name(), ".bat") {
-			batFiles = append(batFiles, filepath.Join(c.batchDir, f.Name()))
-		}
+	var batFiles []string
+	for _, p := range preconfigs {
+		batFiles = append(batFiles, p.Path)
 	}
 	return batFiles, nil
 }
@@ -114,41 +182,6 @@ func requestElevation() error {
 	return cmd.Run()
 }
 
-func ensureProcessTerminated(processName string) {
-	cmd := exec.Command("taskkill", "/F", "/IM", processName)
-	cmd.Run() // Ignore errors as the process may not exist
-}
-
-func waitForProcess(processName string, timeout time.Duration) bool {
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
-		cmd := exec.Command("tasklist", "/FI", fmt.Sprintf("IMAGENAME eq %s", processName))
-		output, _ := cmd.Output()
-		if strings.Contains(string(output), processName) {
-			return true
-		}
-		time.Sleep(100 * time.Millisecond)
-	}
-	return false
-}
-
-func testConnection(domain string, timeout time.Duration) bool {
-	cmd := exec.Command("powershell", "-Command", fmt.Sprintf(`
-		$webRequest = [System.Net.WebRequest]::Create("https://%s")
-		$webRequest.Timeout = %d
-		try {
-			$response = $webRequest.GetResponse()
-			$response.Close()
-			return $true
-		} catch {
-			return $false
-		}
-	`, domain, int(timeout.Milliseconds())))
-
-	err := cmd.Run()
-	return err == nil
-}
-
 func getDomainChoice() ([]string, error) {
 	fmt.Println("\nSelect domain for checking:")
 	for _, item := range domainList {
@@ -223,6 +256,33 @@ func getDomainChoice() ([]string, error) {
 	}
 }
 
+// getModeChoice returns whether all pre-configs should be tested and whether
+// new strategy should be searched instead of testing pre-configs
+func getModeChoice() (sweep, search bool, err error) {
+	fmt.Println("\nSelect testing mode:")
+	fmt.Println("1. Stop at first working pre-config")
+	fmt.Println("2. Test all pre-configs and rank them")
+	fmt.Println("3. Search for new strategy and save it as pre-config")
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Print("\nEnter number of variant: ")
+		choice, err := reader.ReadString('\n')
+		if err != nil {
+			return false, false, fmt.Errorf("error reading input: %v", err)
+		}
+		switch strings.TrimSpace(choice) {
+		case "1":
+			return false, false, nil
+		case "2":
+			return true, false, nil
+		case "3":
+			return true, true, nil
+		}
+		fmt.Println("Invalid selection. Please select number from 1 to 3")
+	}
+}
+
 const DEFAULT_PORT = 443
 
 func isValidDomain(domain string) bool {
@@ -325,23 +385,48 @@ func checkDPIFingerprint(domain string) (DPITestResult, error) {
 	return DPITestResult(result), nil
 }
 
-func runBypassCheck(config Config) error {
-	domains := strings.Split(config.targetDomain, " ")
+// checkDomains runs DNS and DPI pre-checks for every domain and reports whether
+// any of them needs bypass and whether DNS answers of any of them are tampered
+func checkDomains(config Config, domains []string, report *Report) (needBypass, dnsTampered bool) {
+	dnsChecker := newDNSChecker(config.dohResolvers, config.connectionTimeout)
 
-	fmt.Printf("\nStarting testing domains: %s\n", config.targetDomain)
-	fmt.Println("------------------------------------------------")
+	sigs, err := loadBlockSignatures(config.signaturesFile)
+	if err != nil {
+		fmt.Printf("%sError loading stub page signatures: %v%s\n", colorRed, err, colorReset)
+	}
 
-	needBypass := false
 	// Check DPI for each domain
 	for _, domain := range domains {
 		domainForCheck := strings.Split(domain, ":")[0]
+
+		fmt.Printf("\nChecking DNS answers for %s...\n", domainForCheck)
+		dnsResult := dnsChecker.Check(domainForCheck)
+		printDNSCheck(dnsResult)
+		if dnsResult.Status == DNSPoisoned || dnsResult.Status == DNSBlocked {
+			// Connection goes to wrong address, so DPI check would only blame DPI
+			// for something winws can't fix
+			report.addDPICheck(domainForCheck, DNSTampering, nil, dnsResult)
+			dnsTampered = true
+			continue
+		}
+
+		fmt.Printf("\nChecking ISP stub pages for %s...\n", domainForCheck)
+		if stub := detectStub(domainForCheck, sigs, config.connectionTimeout); stub.Blocked {
+			fmt.Printf("Checking result for %s: %s (%s: %s)\n", domainForCheck, BlockedByStub, stub.Kind, stub.Evidence)
+			report.addDPICheck(domainForCheck, BlockedByStub, nil, dnsResult)
+			report.setStub(domainForCheck, stub)
+			needBypass = true
+			continue
+		}
+
 		fmt.Printf("\nChecking DPI blocks for %s...\n", domainForCheck)
-		result, err := checkDPIFingerprint(domainForCheck)
+		result, err := config.dpiCheck(domainForCheck)
 		if err != nil {
 			fmt.Printf("Checking result for %s: %s (with error: %v)\n", domainForCheck, result, err)
 		} else {
 			fmt.Printf("Checking result for %s: %s\n", domainForCheck, result)
 		}
+		report.addDPICheck(domainForCheck, result, err, dnsResult)
 
 		if result == NoDPI {
 			fmt.Printf("Using DPI spoofer not required for %s.\n", domainForCheck)
@@ -356,120 +441,318 @@ func runBypassCheck(config Config) error {
 		needBypass = true
 	}
 
-	if !needBypass {
+	// Throttled service passes handshake checks, so it can be detected only
+	// by measuring download speed
+	if config.throughputURL != "" {
+		fmt.Printf("\nMeasuring download speed without winws from %s...\n", config.throughputURL)
+		baseline := probeThroughput(config.throughputURL, config.throughputBytes, config.throughputTimeout)
+		printThroughput(baseline, 0, config.throughputMinSpeed)
+		report.Baseline = newThroughputReport(baseline, config.throughputMinSpeed, 0)
+		if !baseline.Recovered(config.throughputMinSpeed, 0) {
+			fmt.Println("Download speed is below minimal, throttling detected.")
+			needBypass = true
+		}
+	}
+
+	return needBypass, dnsTampered
+}
+
+func runBypassCheck(config Config) (Outcome, error) {
+	domains := strings.Split(config.targetDomain, " ")
+
+	fmt.Printf("\nStarting testing domains: %s\n", config.targetDomain)
+	fmt.Println("------------------------------------------------")
+
+	var checkpoint *Checkpoint
+	if config.resume {
+		checkpoint = loadCheckpoint(config, domains)
+	}
+
+	report := newReport(config, domains)
+	if checkpoint != nil {
+		report = checkpoint.Report
+		report.FinishedAt = time.Time{}
+	}
+	defer saveReport(report, config)
+
+	network := detectNetwork(config.connectionTimeout, config.lookupASN)
+	report.Environment.Network = network
+	runID := report.StartedAt.Format(time.RFC3339Nano)
+
+	// winws of autorun service would bypass DPI for pre-check and pre-configs
+	warnForeignWinws(config.supervisor)
+
+	// Make sure internet works at all, otherwise every domain would look blocked
+	if len(config.controlTargets) > 0 {
+		fmt.Printf("\nChecking control domains: %s...\n", strings.Join(config.controlTargets, ", "))
+		status, probes := checkControl(config.controlTargets, config.connectionTimeout)
+		report.setControl(status, probes)
+		printControl(status, probes)
+		if status == ControlOffline {
+			report.finish("No internet connection")
+			return OutcomeOffline, nil
+		}
+	}
+
+	if checkpoint != nil {
+		fmt.Printf("\nResuming interrupted run, %d pre-configs already tested.\n", len(report.Preconfigs))
+	} else if needBypass, dnsTampered := checkDomains(config, domains, report); !needBypass {
+		if dnsTampered {
+			fmt.Println("\nDNS answers are tampered, pre-configs can't help. Change DNS server and run tester again.")
+			report.finish("DNS tampering detected, change DNS server")
+			return OutcomeNotFound, nil
+		}
 		fmt.Println("\nNo DPI blocks detected for any domain. No need to test pre-configs.")
-		return nil
+		report.finish("No DPI blocks detected, pre-config is not required")
+		return OutcomeNotRequired, nil
 	}
 
 	fmt.Println("------------------------------------------------")
+	if config.search {
+		return runSearch(config, domains, report)
+	}
 	fmt.Println("Testing pre-configs...")
 
 	batFiles, err := config.getBatchFiles()
 	if err != nil {
-		return err
+		return OutcomeNotFound, err
+	}
+	if len(batFiles) == 0 {
+		if !config.filter.IsEmpty() {
+			return OutcomeNotFound, fmt.Errorf("no pre-configs in %s match filter %s", config.batchDir, config.filter)
+		}
+		return OutcomeNotFound, fmt.Errorf("no pre-configs found in %s", config.batchDir)
+	}
+
+	tested := make(map[string]*PreconfigResult)
+	if checkpoint != nil {
+		tested = checkpoint.testedPreconfigs()
 	}
 
-	success := false
+	var results []*PreconfigResult
+	var best *PreconfigResult
 	for _, batFile := range batFiles {
-		fmt.Printf("\n%sRunning pre-config: %s%s\n", colorMagenta, batFile, colorReset)
-
-		ensureProcessTerminated(config.processName)
-
-		cmd := exec.Command("powershell", "-Command", fmt.Sprintf(`
-			# Save console settings
-			$originalForeground = $host.UI.RawUI.ForegroundColor
-			$originalBackground = $host.UI.RawUI.BackgroundColor
-			$originalBufferSize = $host.UI.RawUI.BufferSize
-			$originalWindowSize = $host.UI.RawUI.WindowSize
-
-			# Save font settings
-			$key = 'HKCU:\Console'
-			$originalFontSize = Get-ItemProperty -Path $key -Name 'FontSize' -ErrorAction SilentlyContinue
-			$originalFaceName = Get-ItemProperty -Path $key -Name 'FaceName' -ErrorAction SilentlyContinue
-			$originalFontFamily = Get-ItemProperty -Path $key -Name 'FontFamily' -ErrorAction SilentlyContinue
-			
-			try {
-				# Execute BAT file
-				cmd /c "%s"
-			} finally {
-				# Restore console settings
-				$host.UI.RawUI.ForegroundColor = $originalForeground
-				$host.UI.RawUI.BackgroundColor = $originalBackground
-				$host.UI.RawUI.BufferSize = $originalBufferSize
-				$host.UI.RawUI.WindowSize = $originalWindowSize
-
-				# Restore font settings
-				if ($originalFontSize) {
-					Set-ItemProperty -Path $key -Name 'FontSize' -Value $originalFontSize.FontSize
-				}
-				if ($originalFaceName) {
-					Set-ItemProperty -Path $key -Name 'FaceName' -Value $originalFaceName.FaceName
-				}
-				if ($originalFontFamily) {
-					Set-ItemProperty -Path $key -Name 'FontFamily' -Value $originalFontFamily.FontFamily
+		result, alreadyTested := tested[batFile]
+		if alreadyTested {
+			fmt.Printf("\n%sSkipping already tested pre-config: %s%s\n", colorMagenta, batFile, colorReset)
+		} else {
+			fmt.Printf("\n%sRunning pre-config: %s%s\n", colorMagenta, batFile, colorReset)
+
+			result = testPreconfig(config, batFile, domains, report.Baseline, report.StartedAt)
+			if !result.AllPassed() && len(config.controlTargets) > 0 {
+				var ok bool
+				result, ok = retestAfterOutage(config, batFile, domains, report, result)
+				if !ok {
+					fmt.Printf("\n%sConnection didn't come back in %s. Progress saved, run tester again to resume.%s\n",
+						colorRed, config.outageWait, colorReset)
+					report.finish("Connection lost during testing")
+					return OutcomeOffline, nil
 				}
 			}
-		`, batFile))
+			report.addPreconfig(result)
+			if err := saveCheckpoint(config, domains, report); err != nil {
+				fmt.Printf("%sError saving progress: %v%s\n", colorRed, err, colorReset)
+			}
+			if err := appendHistory(config.historyFile, runID, network, result, domains); err != nil {
+				fmt.Printf("%sError saving history: %v%s\n", colorRed, err, colorReset)
+			}
+		}
+		results = append(results, result)
+
+		if result.AllPassed() {
+			filename := filepath.Base(batFile)
+			fmt.Printf("\n%s!!!!!!!!!!!!!\n[SUCCESS] It seems, this pre-config is suitable for all specified domains - %s\n!!!!!!!!!!!!!\n%s\n",
+				colorGreen, filename, colorReset)
+			if best == nil {
+				best = result
+			}
+			if !config.sweep {
+				break
+			}
+		}
+	}
 
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+	config.supervisor.Stop()
+	removeCheckpoint(config)
 
-		if err := cmd.Start(); err != nil {
-			fmt.Printf("%sFailed to run pre-config %s: %v%s\n", colorRed, batFile, err, colorReset)
-			continue
+	if config.sweep {
+		printRanking(results, len(domains))
+		if ranked := rankResults(results); len(ranked) > 0 && ranked[0].AllPassed() {
+			best = ranked[0]
+			fmt.Printf("\n%sMost robust pre-config: %s%s\n", colorGreen, best.Name, colorReset)
 		}
+	}
 
-		if !waitForProcess(config.processName, config.processWaitTime) {
-			fmt.Printf("%s%s not started for pre-config %s%s\n", colorRed, config.processName, batFile, colorReset)
-			cmd.Process.Kill()
-			continue
+	if best == nil {
+		fmt.Println("\n------------------------------------------------")
+		fmt.Println("Unfortunately, not found pre-config we can establish connection with for all specified domains :(")
+		fmt.Println("Try to run BLOCKCHECK, to find necessary parameters for BAT file.")
+		report.finish("No working pre-config found, run BLOCKCHECK")
+		return OutcomeNotFound, nil
+	}
+
+	report.finish(best.Name)
+	return OutcomeFound, nil
+}
+
+// retestAfterOutage checks control domains after pre-config failed. If they're
+// unreachable too, failure was caused by connection loss, not by pre-config, so
+// pre-config is tested again once connection is back. Returns false if
+// connection didn't come back.
+func retestAfterOutage(config Config, batFile string, domains []string, report *Report, result *PreconfigResult) (*PreconfigResult, bool) {
+	// Stop pre-config first, so broken pre-config isn't mistaken for outage
+	config.supervisor.Stop()
+
+	status, probes := checkControl(config.controlTargets, config.connectionTimeout)
+	if status != ControlOffline {
+		return result, true
+	}
+	printControl(status, probes)
+
+	fmt.Println("Waiting for connection to come back...")
+	if !waitForConnection(config.controlTargets, config.connectionTimeout, config.outageWait) {
+		return result, false
+	}
+
+	fmt.Printf("Connection is back, testing pre-config %s again.\n", batFile)
+	retest := testPreconfig(config, batFile, domains, report.Baseline, report.StartedAt)
+	retest.Outages = result.Outages + 1
+	return retest, true
+}
+
+// saveReport writes report in all formats and prints where to find it
+func saveReport(report *Report, config Config) {
+	if report.FinishedAt.IsZero() {
+		report.finish("")
+	}
+
+	paths, err := writeReports(report, config.reportDir, config.reportFormats)
+	if err != nil {
+		fmt.Printf("%sError saving report: %v%s\n", colorRed, err, colorReset)
+	}
+	if len(paths) > 0 {
+		fmt.Println("\nReports saved:")
+		for _, path := range paths {
+			fmt.Printf("  %s\n", path)
+		}
+	}
+}
+
+// testPreconfig runs pre-config and probes every domain through it. In sweep
+// mode all domains are probed even after failure to collect full statistics.
+func testPreconfig(config Config, batFile string, domains []string, baseline *ThroughputReport, startedAt time.Time) *PreconfigResult {
+	result := newPreconfigResult(batFile)
+
+	result.LogFile = runLogPath(startedAt, result.Name)
+	logFile := filepath.Join(config.reportDir, result.LogFile)
+	winws, err := config.supervisor.Start(batFile, logFile, config.processWaitTime)
+	if err != nil {
+		result.StartError = err.Error()
+		fmt.Printf("%s%s not started for pre-config %s: %v%s\n", colorRed, config.processName, batFile, err, colorReset)
+		if tail := logTail(logFile, 10); tail != "" {
+			fmt.Printf("Output of %s (full log in %s):\n%s\n", config.processName, logFile, tail)
 		}
+		return result
+	}
+	fmt.Printf("%s started (PID %d)\n", config.processName, winws.PID)
+	result.Started = true
 
-		// Check all domains
-		allDomainsWork := true
+	// Check all domains, repeating every trial for all of them, so transient
+	// failures are spread across domains instead of hitting only one
+	for trial := 1; trial <= config.trials; trial++ {
 		for _, domain := range domains {
-			if !testConnection(domain, config.connectionTimeout) {
-				fmt.Printf("%s[FAIL] Failed to establish connection to %s using pre-config: %s%s\n",
-					colorRed, domain, batFile, colorReset)
-				allDomainsWork = false
-				break
+			probe := probeDomain(domain, config.connectionTimeout)
+			probe.Trial = trial
+			result.Probes = append(result.Probes, probe)
+			if !probe.Success {
+				fmt.Printf("%s[FAIL] Failed to establish connection to %s using pre-config: %s (trial %d/%d)%s\n",
+					colorRed, domain, batFile, trial, config.trials, colorReset)
+				if !config.sweep {
+					return result
+				}
+				continue
 			}
+			fmt.Printf("%s[OK] %s (handshake %s, trial %d/%d)%s\n", colorGreen, domain,
+				probe.Handshake.Round(time.Millisecond), trial, config.trials, colorReset)
 		}
+	}
 
-		if allDomainsWork {
-			filename := filepath.Base(batFile)
-			fmt.Printf("\n%s!!!!!!!!!!!!!\n[SUCCESS] It seems, this pre-config is suitable for all specified domains - %s\n!!!!!!!!!!!!!\n%s\n",
-				colorGreen, filename, colorReset)
-			cmd.Process.Kill()
-			success = true
-			break
+	for _, stats := range result.DomainStats() {
+		if stats.Successes > 0 {
+			fmt.Printf("  %s: %s\n", hostOnly(stats.Domain), result.AverageTimings(stats.Domain))
 		}
+	}
 
-		cmd.Process.Kill()
+	if config.throughputURL != "" {
+		throughput := probeThroughput(config.throughputURL, config.throughputBytes, config.throughputTimeout)
+		result.Throughput = &throughput
+		result.MinSpeed = config.throughputMinSpeed
+		if baseline != nil && baseline.Error == "" {
+			result.BaselineSpeed = baseline.Speed
+		}
+		printThroughput(throughput, result.BaselineSpeed, config.throughputMinSpeed)
 	}
 
-	ensureProcessTerminated(config.processName)
-	time.Sleep(500 * time.Millisecond)
-	ensureProcessTerminated(config.processName)
+	return result
+}
 
-	if !success {
-		fmt.Println("\n------------------------------------------------")
-		fmt.Println("Unfortunately, not found pre-config we can establish connection with for all specified domains :(")
-		fmt.Println("Try to run BLOCKCHECK, to find necessary parameters for BAT file.")
+func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1:]))
 	}
+	runInteractive()
+}
+
+// Cleanups registered by onInterrupt, removed ones are nil
+var (
+	interruptMu       sync.Mutex
+	interruptCleanups []func()
+)
 
-	return nil
+// onInterrupt registers cleanup to run when tester is interrupted, deferred
+// calls don't run then. Returned function unregisters it.
+func onInterrupt(cleanup func()) func() {
+	interruptMu.Lock()
+	defer interruptMu.Unlock()
+	i := len(interruptCleanups)
+	interruptCleanups = append(interruptCleanups, cleanup)
+	return func() {
+		interruptMu.Lock()
+		defer interruptMu.Unlock()
+		interruptCleanups[i] = nil
+	}
 }
 
-func main() {
-	// Add signal handling at the start of main
+// setupSignalHandler stops winws started by tester on interrupt. Progress is
+// already saved to checkpoint after every pre-config, so run can be resumed.
+func setupSignalHandler(config Config, restoreTerminal bool) {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-c
-		fmt.Print(showCursor + exitAltScreen)
+		config.supervisor.Stop()
+		interruptMu.Lock()
+		for _, cleanup := range interruptCleanups {
+			if cleanup != nil {
+				cleanup()
+			}
+		}
+		interruptMu.Unlock()
+		if _, err := os.Stat(config.stateFile); err == nil {
+			fmt.Println("\nInterrupted. Progress saved, run tester again with same domains to resume.")
+		}
+		if restoreTerminal {
+			fmt.Print(showCursor + exitAltScreen)
+		}
 		os.Exit(1)
 	}()
+}
+
+func runInteractive() {
+	config := defaultConfig()
+
+	// Add signal handling at the start of main
+	setupSignalHandler(config, true)
 
 	var buf bytes.Buffer
 	buf.Grow(bufferSize)
@@ -501,18 +784,44 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Remind what worked on this network before
+	if err := printHistory(config, detectNetwork(config.connectionTimeout, config.lookupASN), 5); err != nil {
+		fmt.Printf("Error reading history: %v\n", err)
+	}
+
 	targetDomains, err := getDomainChoice()
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		return
 	}
 
-	config := Config{
-		batchDir:          "pre-configs",
-		targetDomain:      strings.Join(targetDomains, " "),
-		processName:       "winws.exe",
-		processWaitTime:   10 * time.Second,
-		connectionTimeout: 5 * time.Second,
+	sweep, search, err := getModeChoice()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	// Search doesn't use shipped pre-configs
+	var filter PreconfigFilter
+	if !search {
+		filter, err = getFilterChoice(config)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+	}
+
+	config.targetDomain = strings.Join(targetDomains, " ")
+	config.sweep = sweep
+	config.search = search
+	config.filter = filter
+
+	if checkpoint := loadCheckpoint(config, targetDomains); checkpoint != nil {
+		total := len(checkpoint.Report.Preconfigs)
+		if batFiles, err := config.getBatchFiles(); err == nil {
+			total = len(batFiles)
+		}
+		config.resume = askResume(checkpoint, total)
 	}
 
 	// Use buffered output for all writes
@@ -521,7 +830,7 @@ func main() {
 	output.Write(buf.Bytes())
 	output.Flush()
 
-	if err := runBypassCheck(config); err != nil {
+	if _, err := runBypassCheck(config); err != nil {
 		fmt.Printf("Error: %v\n", err)
 	}
 
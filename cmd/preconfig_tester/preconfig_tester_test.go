@@ -0,0 +1,251 @@
+package main
+
+import (
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newBypassServer starts HTTPS server reachable only while fake winws passes
+// connections and makes probes trust it. It returns domain in host:port form.
+func newBypassServer(t *testing.T, winws *fakeSupervisor) string {
+	t.Helper()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Listener = &bypassListener{Listener: server.Listener, winws: winws}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	rootCAs = pool
+	t.Cleanup(func() { rootCAs = nil })
+	return server.Listener.Addr().String()
+}
+
+// testConfig returns config keeping all files in temporary directory. DPI
+// pre-check always finds blocks, so pre-configs are tested.
+func testConfig(t *testing.T, winws *fakeSupervisor) Config {
+	t.Helper()
+	dir := t.TempDir()
+	config := defaultConfig()
+	config.batchDir = filepath.Join(dir, "pre-configs")
+	config.supervisor = winws
+	config.dpiCheck = func(string) (DPITestResult, error) { return HasDPI, nil }
+	config.processWaitTime = time.Second
+	config.connectionTimeout = 2 * time.Second
+	config.reportDir = filepath.Join(dir, "reports")
+	config.reportFormats = []string{"json"}
+	config.stateFile = filepath.Join(dir, "reports", "state.json")
+	config.historyFile = filepath.Join(dir, "reports", "history.jsonl")
+	config.signaturesFile = filepath.Join(dir, "stub-signatures.txt")
+	config.dohResolvers = nil
+	config.controlTargets = nil
+	return config
+}
+
+// writePreconfigs creates pre-configs with given names and returns their paths
+func writePreconfigs(t *testing.T, dir string, names ...string) []string {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, name := range names {
+		path := filepath.Join(dir, name+".bat")
+		content := "@echo off\r\nset BIN=%~dp0..\\bin\\\r\nstart \"zapret\" /min \"%BIN%winws.exe\" --wf-tcp=443 --dpi-desync=fake\r\n"
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func TestTestPreconfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		failing bool
+		// crashAfter is number of connections before crash, 0 never crashes
+		crashAfter int
+		started    bool
+		probes     []bool
+	}{
+		{name: "ready", started: true, probes: []bool{true, true, true}},
+		// Probes aren't run when winws didn't get ready
+		{name: "not ready", failing: true},
+		// Bypass is gone after crash, so rest of trials fail
+		{name: "crash", crashAfter: 1, started: true, probes: []bool{true, false, false}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			winws := newFakeSupervisor()
+			domain := newBypassServer(t, winws)
+			config := testConfig(t, winws)
+			config.sweep = true
+			batFile := writePreconfigs(t, config.batchDir, "general")[0]
+			winws.Failing[batFile] = test.failing
+			if test.crashAfter > 0 {
+				winws.CrashAfter[batFile] = test.crashAfter
+			}
+
+			result := testPreconfig(config, batFile, []string{domain}, nil, time.Now())
+			winws.Stop()
+
+			if result.Started != test.started {
+				t.Errorf("Started = %v, want %v", result.Started, test.started)
+			}
+			var probes []bool
+			for _, probe := range result.Probes {
+				probes = append(probes, probe.Success)
+				if !probe.Success && classifyError(probe.Err) != "reset" {
+					t.Errorf("trial %d failed with %v, want reset", probe.Trial, probe.Err)
+				}
+			}
+			if !reflect.DeepEqual(probes, test.probes) {
+				t.Errorf("probes = %v, want %v", probes, test.probes)
+			}
+			if result.AllPassed() != (test.crashAfter == 0 && !test.failing) {
+				t.Errorf("AllPassed = %v", result.AllPassed())
+			}
+
+			log, err := os.ReadFile(filepath.Join(config.reportDir, result.LogFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.failing {
+				if !strings.Contains(result.StartError, "exited right after start") {
+					t.Errorf("StartError = %q", result.StartError)
+				}
+				if !strings.Contains(string(log), "fake winws: exiting") {
+					t.Errorf("log doesn't hold output of winws:\n%s", log)
+				}
+			}
+		})
+	}
+}
+
+func TestRunBypassCheck(t *testing.T) {
+	winws := newFakeSupervisor()
+	// winws of autorun service keeps running during whole test
+	winws.ForeignProcesses = []WinwsProcess{{PID: 500, ParentPID: 4}}
+	domain := newBypassServer(t, winws)
+	config := testConfig(t, winws)
+	config.targetDomain = domain
+	paths := writePreconfigs(t, config.batchDir, "1 not ready", "2 crash", "3 works", "4 not tested")
+	winws.Failing[paths[0]] = true
+	winws.CrashAfter[paths[1]] = 2
+
+	outcome, err := runBypassCheck(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outcome != OutcomeFound {
+		t.Errorf("outcome = %v, want %v", outcome, OutcomeFound)
+	}
+	if !reflect.DeepEqual(winws.Started, paths[:3]) {
+		t.Errorf("started %v, want %v", winws.Started, paths[:3])
+	}
+	// Crashed winws is torn down before next pre-config, working one at the
+	// end, winws that never got ready and foreign one aren't touched
+	if want := []int{1002, 1003}; !reflect.DeepEqual(winws.Stopped, want) {
+		t.Errorf("stopped PIDs %v, want %v", winws.Stopped, want)
+	}
+	if running := winws.Running(); running != "" {
+		t.Errorf("%s is still running", running)
+	}
+	if _, err := os.Stat(config.stateFile); !os.IsNotExist(err) {
+		t.Errorf("checkpoint isn't removed after finished run: %v", err)
+	}
+}
+
+func TestWindowsSupervisorOwnTree(t *testing.T) {
+	service := WinwsProcess{PID: 500, ParentPID: 4}
+	child := WinwsProcess{PID: 701, ParentPID: 700}
+
+	tests := []struct {
+		name string
+		// polls holds process lists returned one by one, last one repeats
+		polls  [][]WinwsProcess
+		ready  bool
+		err    string
+		killed []int
+	}{
+		{name: "ready", polls: [][]WinwsProcess{{service, child}}, ready: true, killed: []int{child.PID}},
+		// Child seen once is killed even if it exited, its children may live
+		{name: "exited", polls: [][]WinwsProcess{{service, child}, {service}}, err: "exited right after start", killed: []int{child.PID}},
+		{name: "not started", polls: [][]WinwsProcess{{service}}, err: "not started in time"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newWindowsSupervisor("winws.exe")
+			poll := 0
+			s.list = func() ([]WinwsProcess, error) {
+				processes := test.polls[min(poll, len(test.polls)-1)]
+				poll++
+				return processes, nil
+			}
+			var killed []int
+			s.kill = func(pid int) error {
+				killed = append(killed, pid)
+				return nil
+			}
+			s.cmdPID = child.ParentPID
+
+			p, err := s.waitReady(child.ParentPID, time.Now().Add(time.Second))
+			if test.ready {
+				if err != nil || p != child {
+					t.Fatalf("waitReady = %v, %v, want %v", p, err, child)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("waitReady error = %v, want %q", err, test.err)
+			}
+
+			foreign, err := s.Foreign()
+			if err != nil || !reflect.DeepEqual(foreign, []WinwsProcess{service}) {
+				t.Errorf("Foreign = %v, %v, want only %v", foreign, err, service)
+			}
+
+			s.Stop()
+			if !reflect.DeepEqual(killed, test.killed) {
+				t.Errorf("killed %v, want %v", killed, test.killed)
+			}
+		})
+	}
+}
+
+// TestWindowsSupervisorStopWhileWaiting stops supervisor while it waits for
+// winws of pre-config: winws that shows up after that is killed right away
+func TestWindowsSupervisorStopWhileWaiting(t *testing.T) {
+	child := WinwsProcess{PID: 701, ParentPID: 700}
+	s := newWindowsSupervisor("winws.exe")
+	s.cmdPID = child.ParentPID
+	var killed []int
+	s.kill = func(pid int) error {
+		killed = append(killed, pid)
+		return nil
+	}
+	polls := 0
+	s.list = func() ([]WinwsProcess, error) {
+		polls++
+		if polls == 1 {
+			return nil, nil
+		}
+		s.Stop()
+		return []WinwsProcess{child}, nil
+	}
+
+	if _, err := s.waitReady(child.ParentPID, time.Now().Add(time.Second)); err != errStopped {
+		t.Fatalf("waitReady error = %v, want %v", err, errStopped)
+	}
+	if !reflect.DeepEqual(killed, []int{child.PID}) {
+		t.Errorf("killed %v, want %v", killed, []int{child.PID})
+	}
+}
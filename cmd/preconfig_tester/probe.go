@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ProbeResult holds outcome of single connection attempt to domain with
+// duration of every phase of connection
+type ProbeResult struct {
+	Domain    string
+	Trial     int
+	Success   bool
+	DNS       time.Duration
+	Connect   time.Duration
+	Handshake time.Duration
+	TTFB      time.Duration
+	Total     time.Duration
+	Err       error
+}
+
+// rootCAs are certificates probes trust, nil means roots of system. Tests
+// set it to trust their local servers.
+var rootCAs *x509.CertPool
+
+// probeDomain connects to domain (in host:port form), performs TLS handshake and
+// sends HEAD request over established connection. Every phase is timed
+// separately: desync strategies mostly affect TLS handshake, but some of them
+// slow down connect or first response byte too.
+func probeDomain(domain string, timeout time.Duration) ProbeResult {
+	result := ProbeResult{Domain: domain}
+	host, port, err := net.SplitHostPort(domain)
+	if err != nil {
+		host, port = domain, "443"
+	}
+
+	start := time.Now()
+	deadline := start.Add(timeout)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	dnsStart := time.Now()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	result.DNS = time.Since(dnsStart)
+	if err != nil {
+		result.Err = err
+		result.Total = time.Since(start)
+		return result
+	}
+
+	// Try addresses in order like net.Dial does, connect time covers all attempts
+	connectStart := time.Now()
+	var rawConn net.Conn
+	dialer := &net.Dialer{}
+	for _, addr := range addrs {
+		rawConn, err = dialer.DialContext(ctx, "tcp", net.JoinHostPort(addr, port))
+		if err == nil {
+			break
+		}
+	}
+	result.Connect = time.Since(connectStart)
+	if err != nil {
+		result.Err = err
+		result.Total = time.Since(start)
+		return result
+	}
+	defer rawConn.Close()
+	rawConn.SetDeadline(deadline)
+
+	handshakeStart := time.Now()
+	conn := tls.Client(rawConn, &tls.Config{ServerName: host, RootCAs: rootCAs})
+	if err := conn.Handshake(); err != nil {
+		result.Err = fmt.Errorf("TLS handshake failed: %w", err)
+		result.Total = time.Since(start)
+		return result
+	}
+	result.Handshake = time.Since(handshakeStart)
+
+	req, err := http.NewRequest("HEAD", "https://"+host+"/", nil)
+	if err != nil {
+		result.Err = err
+		result.Total = time.Since(start)
+		return result
+	}
+	req.Header.Set("User-Agent", "zapret-discord-youtube-tester")
+	req.Close = true
+
+	requestStart := time.Now()
+	if err := req.Write(conn); err != nil {
+		result.Err = fmt.Errorf("failed to send request: %w", err)
+		result.Total = time.Since(start)
+		return result
+	}
+
+	reader := bufio.NewReader(conn)
+	if _, err := reader.Peek(1); err != nil {
+		result.Err = fmt.Errorf("failed to read response: %w", err)
+		result.Total = time.Since(start)
+		return result
+	}
+	result.TTFB = time.Since(requestStart)
+
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		result.Err = fmt.Errorf("failed to read response: %w", err)
+		result.Total = time.Since(start)
+		return result
+	}
+	resp.Body.Close()
+
+	result.Success = true
+	result.Total = time.Since(start)
+	return result
+}
+
+// formatTimings returns compact line with duration of every phase of probe
+func formatTimings(dns, connect, handshake, ttfb time.Duration) string {
+	round := func(d time.Duration) string {
+		return d.Round(time.Millisecond).String()
+	}
+	return fmt.Sprintf("dns %s, tcp %s, tls %s, ttfb %s", round(dns), round(connect), round(handshake), round(ttfb))
+}
+
+// hostOnly strips port from domain in host:port form
+func hostOnly(domain string) string {
+	return strings.Split(domain, ":")[0]
+}
+
+// classifyError maps probe error to short class name used in reports
+func classifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+	if class, ok := restoredErrorClass(err); ok {
+		return class
+	}
+
+	var netErr net.Error
+	var dnsErr *net.DNSError
+	var hostErr x509.HostnameError
+	switch {
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.As(err, &hostErr):
+		// Certificate for another host usually means ISP stub page
+		return "stub"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, syscall.ECONNRESET):
+		return "reset"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "refused"
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "eof"
+	}
+
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "forcibly closed"), strings.Contains(msg, "connection reset"):
+		return "reset"
+	case strings.Contains(msg, "actively refused"), strings.Contains(msg, "connection refused"):
+		return "refused"
+	case strings.Contains(msg, "certificate"), strings.Contains(msg, "tls"):
+		return "tls"
+	default:
+		return "other"
+	}
+}
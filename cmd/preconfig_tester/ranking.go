@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PreconfigResult holds results of testing single pre-config against all domains
+type PreconfigResult struct {
+	Name    string
+	Path    string
+	Started bool
+	Probes  []ProbeResult
+	// Throughput is set only when throughput probe is enabled
+	Throughput *ThroughputResult
+	MinSpeed   float64
+	// BaselineSpeed is download speed without winws, 0 if it wasn't measured
+	BaselineSpeed float64
+	// Outages counts how many times pre-config was retested because
+	// connection was lost during testing
+	Outages int
+	// LogFile holds output of winws, StartError why it didn't start
+	LogFile    string
+	StartError string
+}
+
+// DomainStats groups probes by domain, keeping order in which domains were probed
+func (r *PreconfigResult) DomainStats() []DomainStats {
+	var stats []DomainStats
+	index := make(map[string]int)
+	for _, probe := range r.Probes {
+		i, ok := index[probe.Domain]
+		if !ok {
+			i = len(stats)
+			index[probe.Domain] = i
+			stats = append(stats, DomainStats{Domain: probe.Domain})
+		}
+		stats[i].Trials++
+		if probe.Success {
+			stats[i].Successes++
+		}
+	}
+	return stats
+}
+
+// Passed returns number of domains that were reachable in every trial
+func (r *PreconfigResult) Passed() int {
+	passed := 0
+	for _, s := range r.DomainStats() {
+		if s.Successes == s.Trials {
+			passed++
+		}
+	}
+	return passed
+}
+
+// AllPassed reports whether every domain was reachable in every trial and
+// download speed recovered, if it was measured
+func (r *PreconfigResult) AllPassed() bool {
+	stats := r.DomainStats()
+	return r.Started && len(stats) > 0 && r.Passed() == len(stats) && r.ThroughputRecovered()
+}
+
+// ThroughputRecovered reports whether download speed reached minimal speed
+// and got well above speed without winws if that was throttled. It's always
+// true when throughput wasn't measured.
+func (r *PreconfigResult) ThroughputRecovered() bool {
+	return r.Throughput == nil || r.Throughput.Recovered(r.MinSpeed, r.BaselineSpeed)
+}
+
+// Speed returns measured download speed or 0 if it wasn't measured
+func (r *PreconfigResult) Speed() float64 {
+	if r.Throughput == nil {
+		return 0
+	}
+	return r.Throughput.Speed()
+}
+
+// Flaky reports whether any domain both passed and failed across trials
+func (r *PreconfigResult) Flaky() bool {
+	for _, s := range r.DomainStats() {
+		if s.Flaky() {
+			return true
+		}
+	}
+	return false
+}
+
+// Score returns mean lower bound of success ratio confidence interval across
+// domains. Pre-config that passed once out of three gets lower score than one
+// that passed every time, and more trials give more confident score.
+func (r *PreconfigResult) Score() float64 {
+	stats := r.DomainStats()
+	if len(stats) == 0 {
+		return 0
+	}
+	var total float64
+	for _, s := range stats {
+		low, _ := s.Interval()
+		total += low
+	}
+	return total / float64(len(stats))
+}
+
+// AverageHandshake returns mean TLS handshake latency of successful probes
+func (r *PreconfigResult) AverageHandshake() time.Duration {
+	var total time.Duration
+	passed := 0
+	for _, probe := range r.Probes {
+		if probe.Success {
+			total += probe.Handshake
+			passed++
+		}
+	}
+	if passed == 0 {
+		return 0
+	}
+	return total / time.Duration(passed)
+}
+
+// Timings holds mean duration of every phase of successful probes
+type Timings struct {
+	DNS       time.Duration
+	Connect   time.Duration
+	Handshake time.Duration
+	TTFB      time.Duration
+}
+
+func (t Timings) String() string {
+	return formatTimings(t.DNS, t.Connect, t.Handshake, t.TTFB)
+}
+
+// AverageTimings returns mean phase durations of successful probes of domain,
+// or of all domains if domain is empty
+func (r *PreconfigResult) AverageTimings(domain string) Timings {
+	var total Timings
+	passed := 0
+	for _, probe := range r.Probes {
+		if !probe.Success || (domain != "" && probe.Domain != domain) {
+			continue
+		}
+		total.DNS += probe.DNS
+		total.Connect += probe.Connect
+		total.Handshake += probe.Handshake
+		total.TTFB += probe.TTFB
+		passed++
+	}
+	if passed == 0 {
+		return Timings{}
+	}
+	n := time.Duration(passed)
+	return Timings{total.DNS / n, total.Connect / n, total.Handshake / n, total.TTFB / n}
+}
+
+// Failures returns domains that failed in at least one trial with their success ratio
+func (r *PreconfigResult) Failures() []string {
+	var failures []string
+	for _, s := range r.DomainStats() {
+		if s.Successes < s.Trials {
+			failures = append(failures, fmt.Sprintf("%s (%d/%d)", hostOnly(s.Domain), s.Successes, s.Trials))
+		}
+	}
+	return failures
+}
+
+func newPreconfigResult(batFile string) *PreconfigResult {
+	return &PreconfigResult{
+		Name: strings.TrimSuffix(filepath.Base(batFile), ".bat"),
+		Path: batFile,
+	}
+}
+
+// rankResults sorts results from most to least robust pre-config: working ones
+// first, then higher score, higher download speed, lower handshake latency and
+// name for stable output.
+func rankResults(results []*PreconfigResult) []*PreconfigResult {
+	ranked := make([]*PreconfigResult, len(results))
+	copy(ranked, results)
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.AllPassed() != b.AllPassed() {
+			return a.AllPassed()
+		}
+		if a.Score() != b.Score() {
+			return a.Score() > b.Score()
+		}
+		if a.Speed() != b.Speed() {
+			return a.Speed() > b.Speed()
+		}
+		if a.Passed() != b.Passed() {
+			return a.Passed() > b.Passed()
+		}
+		if a.Passed() > 0 && a.AverageHandshake() != b.AverageHandshake() {
+			return a.AverageHandshake() < b.AverageHandshake()
+		}
+		return a.Name < b.Name
+	})
+	return ranked
+}
+
+func printRanking(results []*PreconfigResult, domainCount int) {
+	ranked := rankResults(results)
+
+	nameWidth := len("Pre-config")
+	for _, r := range ranked {
+		nameWidth = max(nameWidth, len(r.Name))
+	}
+
+	fmt.Println("\n------------------------------------------------")
+	fmt.Println("Ranking of pre-configs:")
+	fmt.Printf("\n%-4s %-*s %-8s %-6s %-10s %-10s %-10s %s\n", "#", nameWidth, "Pre-config", "Passed", "Score", "Handshake", "TTFB", "Speed", "Failures")
+	for i, r := range ranked {
+		color := colorRed
+		if r.AllPassed() {
+			color = colorGreen
+		}
+
+		handshake, ttfb := "-", "-"
+		if r.AverageHandshake() > 0 {
+			timings := r.AverageTimings("")
+			handshake = timings.Handshake.Round(time.Millisecond).String()
+			ttfb = timings.TTFB.Round(time.Millisecond).String()
+		}
+
+		speed := "-"
+		if r.Throughput != nil {
+			speed = formatSpeed(r.Speed())
+		}
+
+		failures := strings.Join(r.Failures(), ", ")
+		if !r.ThroughputRecovered() {
+			failures = strings.TrimPrefix(failures+", throughput", ", ")
+		}
+		if r.Flaky() {
+			failures = "flaky: " + failures
+		}
+		if !r.Started {
+			failures = "not started"
+		}
+
+		fmt.Printf("%s%-4d %-*s %-8s %-6.2f %-10s %-10s %-10s %s%s\n", color, i+1, nameWidth, r.Name,
+			fmt.Sprintf("%d/%d", r.Passed(), domainCount), r.Score(), handshake, ttfb, speed, failures, colorReset)
+	}
+}
@@ -0,0 +1,497 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Report is machine-readable summary of single tester run
+type Report struct {
+	Version        string            `json:"version"`
+	StartedAt      time.Time         `json:"started_at"`
+	FinishedAt     time.Time         `json:"finished_at"`
+	Environment    ReportEnvironment `json:"environment"`
+	Mode           string            `json:"mode"`
+	Trials         int               `json:"trials"`
+	Domains        []string          `json:"domains"`
+	ControlStatus  string            `json:"control_status"`
+	ControlProbes  []ProbeReport     `json:"control_probes"`
+	DPIChecks      []DPICheckReport  `json:"dpi_checks"`
+	Baseline       *ThroughputReport `json:"baseline_throughput,omitempty"`
+	Preconfigs     []PreconfigReport `json:"preconfigs"`
+	Recommendation string            `json:"recommendation"`
+}
+
+// ReportEnvironment describes machine the tester ran on
+type ReportEnvironment struct {
+	OS       string      `json:"os"`
+	Arch     string      `json:"arch"`
+	Hostname string      `json:"hostname"`
+	WorkDir  string      `json:"work_dir"`
+	Network  NetworkInfo `json:"network"`
+}
+
+// DPICheckReport holds result of DPI pre-check for single domain
+type DPICheckReport struct {
+	Domain        string   `json:"domain"`
+	Result        string   `json:"result"`
+	Error         string   `json:"error,omitempty"`
+	DNSStatus     string   `json:"dns_status"`
+	DNSReason     string   `json:"dns_reason,omitempty"`
+	SystemAddrs   []string `json:"system_addrs"`
+	ResolverAddrs []string `json:"resolver_addrs"`
+	Resolver      string   `json:"resolver,omitempty"`
+	StubKind      string   `json:"stub_kind,omitempty"`
+	StubEvidence  string   `json:"stub_evidence,omitempty"`
+}
+
+// PreconfigReport holds results of single pre-config
+type PreconfigReport struct {
+	Name           string              `json:"name"`
+	Path           string              `json:"path"`
+	Started        bool                `json:"started"`
+	Passed         int                 `json:"passed"`
+	Total          int                 `json:"total"`
+	AvgHandshakeMs float64             `json:"avg_handshake_ms"`
+	Score          float64             `json:"score"`
+	Flaky          bool                `json:"flaky"`
+	Domains        []DomainStatsReport `json:"domains"`
+	Probes         []ProbeReport       `json:"probes"`
+	Throughput     *ThroughputReport   `json:"throughput,omitempty"`
+	Outages        int                 `json:"outages"`
+	StartError     string              `json:"start_error,omitempty"`
+	// Log is path to winws output relative to report directory
+	Log string `json:"log,omitempty"`
+	// Stage is stage of strategy search candidate was tested at, empty for
+	// pre-configs
+	Stage string `json:"stage,omitempty"`
+}
+
+// Stages of strategy search
+const (
+	// stageScreening tries every basic strategy with single trial
+	stageScreening = "screening"
+	// stageRefinement tests variations of best ones with all trials
+	stageRefinement = "refinement"
+)
+
+// ThroughputReport holds result of throughput probe
+type ThroughputReport struct {
+	URL        string  `json:"url"`
+	Bytes      int64   `json:"bytes"`
+	TTFBMs     float64 `json:"ttfb_ms"`
+	DurationMs float64 `json:"duration_ms"`
+	Speed      float64 `json:"speed_bytes_per_sec"`
+	MinSpeed   float64 `json:"min_speed_bytes_per_sec"`
+	// BaselineSpeed is speed without winws pre-config is compared with
+	BaselineSpeed float64 `json:"baseline_speed_bytes_per_sec,omitempty"`
+	Recovered     bool    `json:"recovered"`
+	Error         string  `json:"error,omitempty"`
+}
+
+func newThroughputReport(t ThroughputResult, minSpeed, baseline float64) *ThroughputReport {
+	tr := &ThroughputReport{
+		URL:           t.URL,
+		Bytes:         t.Bytes,
+		TTFBMs:        milliseconds(t.TTFB),
+		DurationMs:    milliseconds(t.Duration),
+		Speed:         t.Speed(),
+		MinSpeed:      minSpeed,
+		BaselineSpeed: baseline,
+		Recovered:     t.Recovered(minSpeed, baseline),
+	}
+	if t.Err != nil {
+		tr.Error = t.Err.Error()
+	}
+	return tr
+}
+
+// result restores throughput result from report
+func (tr *ThroughputReport) result() *ThroughputResult {
+	t := &ThroughputResult{
+		URL:      tr.URL,
+		Bytes:    tr.Bytes,
+		TTFB:     fromMilliseconds(tr.TTFBMs),
+		Duration: fromMilliseconds(tr.DurationMs),
+	}
+	if tr.Error != "" {
+		t.Err = errors.New(tr.Error)
+	}
+	return t
+}
+
+// DomainStatsReport holds success ratio of domain across trials
+type DomainStatsReport struct {
+	Domain    string  `json:"domain"`
+	Trials    int     `json:"trials"`
+	Successes int     `json:"successes"`
+	Ratio     float64 `json:"ratio"`
+	CILow     float64 `json:"ci_low"`
+	CIHigh    float64 `json:"ci_high"`
+	Flaky     bool    `json:"flaky"`
+	// Mean phase durations of successful probes
+	AvgDNSMs       float64 `json:"avg_dns_ms"`
+	AvgConnectMs   float64 `json:"avg_connect_ms"`
+	AvgHandshakeMs float64 `json:"avg_handshake_ms"`
+	AvgTTFBMs      float64 `json:"avg_ttfb_ms"`
+}
+
+// ProbeReport holds result of single probe of domain
+type ProbeReport struct {
+	Domain      string  `json:"domain"`
+	Trial       int     `json:"trial"`
+	Success     bool    `json:"success"`
+	DNSMs       float64 `json:"dns_ms"`
+	ConnectMs   float64 `json:"connect_ms"`
+	HandshakeMs float64 `json:"handshake_ms"`
+	TTFBMs      float64 `json:"ttfb_ms"`
+	TotalMs     float64 `json:"total_ms"`
+	ErrorClass  string  `json:"error_class,omitempty"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// Report formats
+const (
+	reportJSON     = "json"
+	reportCSV      = "csv"
+	reportMarkdown = "md"
+)
+
+var reportFormats = []string{reportJSON, reportCSV, reportMarkdown}
+
+// runMode returns name of testing mode used in reports and checkpoints.
+// Search never saves checkpoint, so its runs are never resumed.
+func runMode(config Config) string {
+	switch {
+	case config.search:
+		return "search"
+	case config.sweep:
+		return "sweep"
+	default:
+		return "first"
+	}
+}
+
+func newReport(config Config, domains []string) *Report {
+	hostname, _ := os.Hostname()
+	workDir, _ := os.Getwd()
+
+	return &Report{
+		Version:   version,
+		StartedAt: time.Now(),
+		Environment: ReportEnvironment{
+			OS:       runtime.GOOS,
+			Arch:     runtime.GOARCH,
+			Hostname: hostname,
+			WorkDir:  workDir,
+		},
+		Mode:    runMode(config),
+		Trials:  config.trials,
+		Domains: domains,
+	}
+}
+
+func (r *Report) addDPICheck(domain string, result DPITestResult, err error, dns DNSCheckResult) {
+	check := DPICheckReport{
+		Domain:        domain,
+		Result:        result.String(),
+		DNSStatus:     dns.Status.String(),
+		DNSReason:     dns.Reason,
+		SystemAddrs:   dns.SystemAddrs,
+		ResolverAddrs: dns.ResolverAddrs,
+		Resolver:      dns.Resolver,
+	}
+	if err != nil {
+		check.Error = err.Error()
+	}
+	r.DPIChecks = append(r.DPIChecks, check)
+}
+
+// setStub attaches found stub page to DPI check of domain
+func (r *Report) setStub(domain string, stub StubResult) {
+	for i := range r.DPIChecks {
+		if r.DPIChecks[i].Domain == domain {
+			r.DPIChecks[i].StubKind = stub.Kind
+			r.DPIChecks[i].StubEvidence = stub.Evidence
+		}
+	}
+}
+
+func (r *Report) addPreconfig(result *PreconfigResult) {
+	pr := PreconfigReport{
+		Name:           result.Name,
+		Path:           result.Path,
+		Started:        result.Started,
+		Passed:         result.Passed(),
+		Total:          len(r.Domains),
+		AvgHandshakeMs: milliseconds(result.AverageHandshake()),
+		Score:          result.Score(),
+		Flaky:          result.Flaky(),
+		Outages:        result.Outages,
+		StartError:     result.StartError,
+		Log:            result.LogFile,
+	}
+	if result.Throughput != nil {
+		pr.Throughput = newThroughputReport(*result.Throughput, result.MinSpeed, result.BaselineSpeed)
+	}
+	for _, stats := range result.DomainStats() {
+		low, high := stats.Interval()
+		timings := result.AverageTimings(stats.Domain)
+		pr.Domains = append(pr.Domains, DomainStatsReport{
+			Domain:         stats.Domain,
+			Trials:         stats.Trials,
+			Successes:      stats.Successes,
+			Ratio:          stats.Ratio(),
+			CILow:          low,
+			CIHigh:         high,
+			Flaky:          stats.Flaky(),
+			AvgDNSMs:       milliseconds(timings.DNS),
+			AvgConnectMs:   milliseconds(timings.Connect),
+			AvgHandshakeMs: milliseconds(timings.Handshake),
+			AvgTTFBMs:      milliseconds(timings.TTFB),
+		})
+	}
+	for _, probe := range result.Probes {
+		pr.Probes = append(pr.Probes, newProbeReport(probe))
+	}
+	r.Preconfigs = append(r.Preconfigs, pr)
+}
+
+// addSearchResult adds candidate of strategy search tested at given stage
+func (r *Report) addSearchResult(result *PreconfigResult, stage string) {
+	r.addPreconfig(result)
+	r.Preconfigs[len(r.Preconfigs)-1].Stage = stage
+}
+
+func (r *Report) setControl(status ControlStatus, probes []ProbeResult) {
+	r.ControlStatus = status.String()
+	r.ControlProbes = nil
+	for _, probe := range probes {
+		r.ControlProbes = append(r.ControlProbes, newProbeReport(probe))
+	}
+}
+
+func newProbeReport(probe ProbeResult) ProbeReport {
+	p := ProbeReport{
+		Domain:      probe.Domain,
+		Trial:       probe.Trial,
+		Success:     probe.Success,
+		DNSMs:       milliseconds(probe.DNS),
+		ConnectMs:   milliseconds(probe.Connect),
+		HandshakeMs: milliseconds(probe.Handshake),
+		TTFBMs:      milliseconds(probe.TTFB),
+		TotalMs:     milliseconds(probe.Total),
+	}
+	if probe.Err != nil {
+		p.ErrorClass = classifyError(probe.Err)
+		p.Error = probe.Err.Error()
+	}
+	return p
+}
+
+func (r *Report) finish(recommendation string) {
+	r.FinishedAt = time.Now()
+	r.Recommendation = recommendation
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// reportBaseName returns file name of reports without extension
+func reportBaseName(startedAt time.Time) string {
+	return "preconfig-test-" + startedAt.Format("20060102-150405")
+}
+
+// runLogPath returns path of winws log of pre-config relative to report
+// directory. Logs of run are kept next to its reports.
+func runLogPath(startedAt time.Time, name string) string {
+	return filepath.Join(reportBaseName(startedAt)+"-logs", name+".log")
+}
+
+// writeReports saves report in every given format to dir, named after run
+// start time. Returns paths of written files.
+func writeReports(r *Report, dir string, formats []string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating reports directory: %v", err)
+	}
+
+	base := filepath.Join(dir, reportBaseName(r.StartedAt))
+	var paths []string
+	for _, format := range formats {
+		var data []byte
+		var err error
+		switch format {
+		case reportJSON:
+			data, err = r.JSON()
+		case reportCSV:
+			data, err = r.CSV()
+		case reportMarkdown:
+			data = r.Markdown()
+		default:
+			return paths, fmt.Errorf("unknown report format: %s", format)
+		}
+		if err != nil {
+			return paths, fmt.Errorf("error encoding %s report: %v", format, err)
+		}
+
+		path := base + "." + format
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return paths, fmt.Errorf("error writing report: %v", err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// JSON encodes report with all details
+func (r *Report) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// CSV encodes report as one row per probe, suitable for spreadsheets
+func (r *Report) CSV() ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"preconfig", "started", "domain", "trial", "success", "dns_ms", "connect_ms", "handshake_ms", "ttfb_ms", "total_ms", "error_class", "error", "stage"})
+	for _, pr := range r.Preconfigs {
+		if len(pr.Probes) == 0 {
+			w.Write([]string{pr.Name, strconv.FormatBool(pr.Started), "", "", "", "", "", "", "", "", "", pr.StartError, pr.Stage})
+			continue
+		}
+		for _, p := range pr.Probes {
+			w.Write([]string{
+				pr.Name,
+				strconv.FormatBool(pr.Started),
+				p.Domain,
+				strconv.Itoa(p.Trial),
+				strconv.FormatBool(p.Success),
+				strconv.FormatFloat(p.DNSMs, 'f', 1, 64),
+				strconv.FormatFloat(p.ConnectMs, 'f', 1, 64),
+				strconv.FormatFloat(p.HandshakeMs, 'f', 1, 64),
+				strconv.FormatFloat(p.TTFBMs, 'f', 1, 64),
+				strconv.FormatFloat(p.TotalMs, 'f', 1, 64),
+				p.ErrorClass,
+				p.Error,
+				pr.Stage,
+			})
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// Markdown renders report for pasting into issues
+func (r *Report) Markdown() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("# Pre-config test report\n\n")
+	fmt.Fprintf(&buf, "* Version: %s\n", valueOrDash(r.Version))
+	fmt.Fprintf(&buf, "* Started: %s\n", r.StartedAt.Format(time.RFC3339))
+	fmt.Fprintf(&buf, "* Finished: %s\n", r.FinishedAt.Format(time.RFC3339))
+	fmt.Fprintf(&buf, "* OS: %s/%s\n", r.Environment.OS, r.Environment.Arch)
+	fmt.Fprintf(&buf, "* Network: %s\n", r.Environment.Network)
+	fmt.Fprintf(&buf, "* Control domains: %s\n", valueOrDash(r.ControlStatus))
+	fmt.Fprintf(&buf, "* Mode: %s\n", r.Mode)
+	fmt.Fprintf(&buf, "* Trials: %d\n", r.Trials)
+	fmt.Fprintf(&buf, "* Domains: %s\n", strings.Join(r.Domains, ", "))
+	if r.Baseline != nil {
+		fmt.Fprintf(&buf, "* Speed without winws: %s (TTFB %.0f ms)\n", formatSpeed(r.Baseline.Speed), r.Baseline.TTFBMs)
+	}
+	fmt.Fprintf(&buf, "* Recommendation: %s\n", valueOrDash(r.Recommendation))
+
+	if len(r.DPIChecks) > 0 {
+		buf.WriteString("\n## DPI checks\n\n")
+		buf.WriteString("| Domain | Result | DNS | System resolver | DoH resolver | Error |\n|---|---|---|---|---|---|\n")
+		for _, c := range r.DPIChecks {
+			result := c.Result
+			if c.StubKind != "" {
+				result = fmt.Sprintf("%s (%s: %s)", c.Result, c.StubKind, c.StubEvidence)
+			}
+			fmt.Fprintf(&buf, "| %s | %s | %s | %s | %s | %s |\n", c.Domain, markdownCell(result), c.DNSStatus,
+				strings.Join(c.SystemAddrs, ", "), strings.Join(c.ResolverAddrs, ", "), markdownCell(c.Error))
+		}
+	}
+
+	if len(r.Preconfigs) > 0 {
+		buf.WriteString("\n## Pre-configs\n\n")
+		buf.WriteString("| Pre-config | Started | Passed | Score | Flaky | Avg handshake, ms | Speed | Failures | Log |\n|---|---|---|---|---|---|---|---|---|\n")
+		for _, pr := range r.Preconfigs {
+			var failures []string
+			for _, d := range pr.Domains {
+				if d.Successes < d.Trials {
+					failures = append(failures, fmt.Sprintf("%s (%d/%d, %s)", hostOnly(d.Domain),
+						d.Successes, d.Trials, strings.Join(pr.errorClasses(d.Domain), "/")))
+				}
+			}
+			speed := "-"
+			if pr.Throughput != nil {
+				speed = formatSpeed(pr.Throughput.Speed)
+				if !pr.Throughput.Recovered {
+					failures = append(failures, "throughput")
+				}
+			}
+			if pr.StartError != "" {
+				failures = append([]string{"not started: " + pr.StartError}, failures...)
+			}
+			log := "-"
+			if pr.Log != "" {
+				log = fmt.Sprintf("[log](<%s>)", filepath.ToSlash(pr.Log))
+			}
+			name := pr.Name
+			if pr.Stage != "" {
+				name += " (" + pr.Stage + ")"
+			}
+			fmt.Fprintf(&buf, "| %s | %t | %d/%d | %.2f | %t | %.1f | %s | %s | %s |\n", markdownCell(name), pr.Started,
+				pr.Passed, pr.Total, pr.Score, pr.Flaky, pr.AvgHandshakeMs, speed, markdownCell(strings.Join(failures, ", ")), log)
+		}
+	}
+
+	var timings bytes.Buffer
+	for _, pr := range r.Preconfigs {
+		for _, d := range pr.Domains {
+			if d.Successes > 0 {
+				fmt.Fprintf(&timings, "| %s | %s | %.1f | %.1f | %.1f | %.1f |\n", markdownCell(pr.Name), hostOnly(d.Domain),
+					d.AvgDNSMs, d.AvgConnectMs, d.AvgHandshakeMs, d.AvgTTFBMs)
+			}
+		}
+	}
+	if timings.Len() > 0 {
+		buf.WriteString("\n## Timings\n\nMean duration of connection phases of successful probes, ms.\n\n")
+		buf.WriteString("| Pre-config | Domain | DNS | TCP connect | TLS handshake | TTFB |\n|---|---|---|---|---|---|\n")
+		buf.Write(timings.Bytes())
+	}
+
+	return buf.Bytes()
+}
+
+// errorClasses returns distinct error classes of failed probes of domain
+func (pr PreconfigReport) errorClasses(domain string) []string {
+	var classes []string
+	for _, p := range pr.Probes {
+		if p.Domain == domain && !p.Success && !contains(classes, p.ErrorClass) {
+			classes = append(classes, p.ErrorClass)
+		}
+	}
+	return classes
+}
+
+func valueOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.ReplaceAll(s, "\n", " ")
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ankddev/zapret-discord-youtube/internal/preconfig"
+)
+
+// Candidate pre-configs are written to folder next to pre-configs, so
+// relative paths like %~dp0..\bin\ resolve the same way
+const searchDir = "pre-configs-search"
+
+// Number of best candidates of screening stage that are refined
+const searchSurvivors = 3
+
+// Fake payloads shipped in bin folder
+var searchFakePayloads = []string{
+	"tls_clienthello_www_google_com.bin",
+	`blockcheck\zapret\files\fake\tls_clienthello_iana_org.bin`,
+}
+
+// Candidate is winws strategy for TCP 443 tried by strategy search
+type Candidate struct {
+	Mode      string // --dpi-desync
+	Fooling   string // --dpi-desync-fooling
+	AutoTTL   int    // --dpi-desync-autottl, 0 to omit
+	Repeats   int    // --dpi-desync-repeats, 0 to omit
+	SplitPos  string // --dpi-desync-split-pos
+	SeqOvl    bool   // --dpi-desync-split-seqovl with fake pattern
+	FakeTLS   string // --dpi-desync-fake-tls, file in bin folder
+	Screening bool   // tested with single trial in screening stage
+}
+
+func (c Candidate) hasFake() bool {
+	return strings.Contains(c.Mode, "fake")
+}
+
+// hasSyndata reports whether fake is sent in SYN packet. It doesn't use
+// repeats, TTL or fake ClientHello of other fakes.
+func (c Candidate) hasSyndata() bool {
+	return strings.Contains(c.Mode, "syndata")
+}
+
+func (c Candidate) hasSplit() bool {
+	return strings.Contains(c.Mode, "split") || strings.Contains(c.Mode, "disorder")
+}
+
+// Profile returns profile of candidate for TCP 443 limited to hostlist, the
+// same when candidate is tested and when it's saved
+func (c Candidate) Profile() preconfig.Profile {
+	options := []preconfig.Option{preconfig.NewOption("filter-tcp", "443"), preconfig.HostlistOption()}
+	return preconfig.Profile{Options: append(options, c.Options()...)}
+}
+
+// Options returns winws options of candidate without filter and hostlist
+func (c Candidate) Options() []preconfig.Option {
+	options := []preconfig.Option{preconfig.NewOption("dpi-desync", c.Mode)}
+	if c.Fooling != "" {
+		options = append(options, preconfig.NewOption("dpi-desync-fooling", c.Fooling))
+	}
+	if c.AutoTTL > 0 {
+		options = append(options, preconfig.NewOption("dpi-desync-autottl", fmt.Sprint(c.AutoTTL)))
+	}
+	if c.Repeats > 0 {
+		options = append(options, preconfig.NewOption("dpi-desync-repeats", fmt.Sprint(c.Repeats)))
+	}
+	if c.SplitPos != "" {
+		options = append(options, preconfig.NewOption("dpi-desync-split-pos", c.SplitPos))
+	}
+	if c.SeqOvl {
+		options = append(options, preconfig.NewOption("dpi-desync-split-seqovl", "652"),
+			preconfig.QuotedOption("dpi-desync-split-seqovl-pattern", "%BIN%"+searchFakePayloads[0]))
+	}
+	if c.FakeTLS != "" {
+		options = append(options, preconfig.QuotedOption("dpi-desync-fake-tls", "%BIN%"+c.FakeTLS))
+	}
+	return options
+}
+
+// String returns short description used as name in ranking
+func (c Candidate) String() string {
+	var parts []string
+	for _, o := range c.Options() {
+		value := o.Value
+		if strings.HasPrefix(value, "%BIN%") {
+			value = filepath.Base(strings.ReplaceAll(strings.TrimPrefix(value, "%BIN%"), `\`, "/"))
+		}
+		parts = append(parts, strings.TrimPrefix(o.Name, "dpi-desync-")+"="+value)
+	}
+	return strings.Join(parts, " ")
+}
+
+// key identifies strategy regardless of stage it's tested in
+func (c Candidate) key() string {
+	c.Screening = false
+	return c.String()
+}
+
+// screeningCandidates returns basic desync modes with default parameters.
+// Modes sending fakes need fooling or TTL limit, otherwise fake reaches
+// server and breaks connection, so each of them is tried with both.
+func screeningCandidates() []Candidate {
+	var candidates []Candidate
+	for _, mode := range []string{"fake", "fake,split2", "fake,disorder2"} {
+		candidates = append(candidates,
+			Candidate{Mode: mode, Fooling: "md5sig"},
+			Candidate{Mode: mode, Fooling: "badseq"},
+			Candidate{Mode: mode, AutoTTL: 2},
+		)
+	}
+	for _, mode := range []string{"split2", "disorder2"} {
+		candidates = append(candidates, Candidate{Mode: mode, SplitPos: "2"})
+	}
+	candidates = append(candidates, Candidate{Mode: "syndata"})
+	for i := range candidates {
+		candidates[i].Screening = true
+	}
+	return candidates
+}
+
+// neighbors returns variations of candidate changing one parameter at a time
+func neighbors(c Candidate) []Candidate {
+	c.Screening = false
+	result := []Candidate{c}
+	vary := func(change func(*Candidate)) {
+		n := c
+		change(&n)
+		result = append(result, n)
+	}
+
+	if c.hasFake() {
+		for _, repeats := range []int{6, 11} {
+			vary(func(n *Candidate) { n.Repeats = repeats })
+		}
+		for _, ttl := range []int{2, 3} {
+			if ttl != c.AutoTTL {
+				vary(func(n *Candidate) { n.AutoTTL = ttl })
+			}
+		}
+		for _, payload := range searchFakePayloads {
+			vary(func(n *Candidate) { n.FakeTLS = payload })
+		}
+	}
+	if c.hasSyndata() && !c.hasSplit() {
+		// Data is split after SYN with fake is sent
+		for _, mode := range []string{"syndata,split2", "syndata,disorder2"} {
+			vary(func(n *Candidate) { n.Mode, n.SplitPos = mode, "2" })
+		}
+	}
+	if c.hasSplit() {
+		for _, pos := range []string{"1", "3", "midsld"} {
+			if pos != c.SplitPos {
+				vary(func(n *Candidate) { n.SplitPos = pos })
+			}
+		}
+		if !c.hasFake() {
+			vary(func(n *Candidate) { n.SeqOvl = true })
+		}
+	}
+	return result
+}
+
+// searchState tracks budgets of strategy search
+type searchState struct {
+	config   Config
+	domains  []string
+	report   *Report
+	deadline time.Time
+	tested   map[string]bool
+	count    int
+}
+
+func (s *searchState) exhausted() bool {
+	return time.Now().After(s.deadline) || s.count >= s.config.searchMaxCandidates
+}
+
+// test writes candidate to temporary pre-config and runs it through the same
+// run and probe loop as shipped pre-configs
+func (s *searchState) test(c Candidate) (*PreconfigResult, error) {
+	s.count++
+	s.tested[c.key()] = true
+
+	template := preconfig.Template{
+		Title:    "Strategy search",
+		List:     s.config.searchList,
+		Profiles: []preconfig.Profile{c.Profile()},
+	}
+	name := fmt.Sprintf("search-%03d", s.count)
+	batFile, err := template.Save(searchDir, name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// All domains are probed, so candidates can be ranked
+	config := s.config
+	config.sweep = true
+	if c.Screening {
+		config.trials = 1
+	}
+	fmt.Printf("\n%s[%d/%d] Trying %s%s\n", colorMagenta, s.count, s.config.searchMaxCandidates, c, colorReset)
+	result := testPreconfig(config, batFile, s.domains, s.report.Baseline, s.report.StartedAt)
+	result.Name = c.String()
+	return result, nil
+}
+
+// runSearch looks for working winws strategy for TCP 443 of domains. First
+// every basic desync mode is screened with single trial, then few best of
+// them are refined by changing one parameter at a time with full number of
+// trials. Search stops when time or candidate budget is exhausted.
+func runSearch(config Config, domains []string, report *Report) (Outcome, error) {
+	// Candidates are limited to hostlist, winws doesn't touch other domains
+	listPath := filepath.Join("lists", config.searchList)
+	missing, err := missingFromList(listPath, hostsOnly(domains))
+	if err != nil {
+		return OutcomeNotFound, err
+	}
+	if len(missing) > 0 {
+		return OutcomeNotFound, fmt.Errorf("%s not in hostlist %s, add them to it or choose another one", strings.Join(missing, ", "), config.searchList)
+	}
+
+	if err := os.MkdirAll(searchDir, 0755); err != nil {
+		return OutcomeNotFound, fmt.Errorf("error creating search directory: %v", err)
+	}
+	// Deferred calls don't run when tester is interrupted
+	removeDir := func() { os.RemoveAll(searchDir) }
+	defer onInterrupt(removeDir)()
+	defer removeDir()
+
+	state := &searchState{
+		config:   config,
+		domains:  domains,
+		report:   report,
+		deadline: time.Now().Add(config.searchBudget),
+		tested:   make(map[string]bool),
+	}
+
+	fmt.Printf("\nSearching for strategy, budget %s or %d candidates...\n", config.searchBudget, config.searchMaxCandidates)
+
+	candidates := make(map[string]Candidate)
+	var screening []*PreconfigResult
+	for _, c := range screeningCandidates() {
+		if state.exhausted() {
+			break
+		}
+		result, err := state.test(c)
+		if err != nil {
+			return OutcomeNotFound, err
+		}
+		candidates[result.Name] = c
+		screening = append(screening, result)
+		// Screening results show why candidates were discarded
+		report.addSearchResult(result, stageScreening)
+	}
+
+	// Candidates that didn't pass any domain are pruned with all their
+	// variations
+	var survivors []Candidate
+	for _, r := range rankResults(screening) {
+		if len(survivors) == searchSurvivors || r.Passed() == 0 {
+			break
+		}
+		survivors = append(survivors, candidates[r.Name])
+	}
+
+	var results []*PreconfigResult
+	for _, survivor := range survivors {
+		for _, c := range neighbors(survivor) {
+			// Survivor itself is retested with full number of trials
+			if state.exhausted() || (state.tested[c.key()] && c.key() != survivor.key()) {
+				continue
+			}
+			result, err := state.test(c)
+			if err != nil {
+				return OutcomeNotFound, err
+			}
+			candidates[result.Name] = c
+			results = append(results, result)
+			report.addSearchResult(result, stageRefinement)
+		}
+	}
+	config.supervisor.Stop()
+
+	if state.exhausted() {
+		fmt.Printf("\n%sSearch budget exhausted after %d candidates.%s\n", colorRed, state.count, colorReset)
+	}
+	if len(results) == 0 {
+		fmt.Println("\nNo strategy passed any domain. Try BLOCKCHECK or another domain set.")
+		report.finish("Strategy search found nothing, run BLOCKCHECK")
+		return OutcomeNotFound, nil
+	}
+
+	printRanking(results, len(domains))
+
+	var saved []string
+	for _, r := range rankResults(results) {
+		if !r.AllPassed() || len(saved) == config.searchSave {
+			break
+		}
+		path, err := saveSearchResult(config, candidates[r.Name], len(saved)+1)
+		if err != nil {
+			fmt.Printf("%sError saving pre-config: %v%s\n", colorRed, err, colorReset)
+			continue
+		}
+		saved = append(saved, filepath.Base(path))
+	}
+
+	if len(saved) == 0 {
+		fmt.Println("\nNo strategy worked for all domains in every trial.")
+		report.finish("Strategy search found nothing, run BLOCKCHECK")
+		return OutcomeNotFound, nil
+	}
+	fmt.Printf("\n%sSaved pre-configs: %s%s\n", colorGreen, strings.Join(saved, ", "), colorReset)
+	report.finish(strings.TrimSuffix(saved[0], ".bat"))
+	return OutcomeFound, nil
+}
+
+// saveSearchResult saves working candidate as pre-config bound to hostlist
+func saveSearchResult(config Config, c Candidate, n int) (string, error) {
+	template := preconfig.Template{
+		List: config.searchList,
+		Comments: []string{
+			fmt.Sprintf("Found by strategy search on %s for %s", time.Now().Format("2006-01-02"),
+				strings.Join(hostsOnly(strings.Split(config.targetDomain, " ")), ", ")),
+		},
+		Tags:     []string{"custom", "search"},
+		Profiles: []preconfig.Profile{c.Profile()},
+	}
+
+	// Don't overwrite pre-configs found by previous searches
+	for i := n; ; i++ {
+		name := fmt.Sprintf("SearchFix (%s v%d)", time.Now().Format("2006-01-02"), i)
+		template.Title = name
+		path, err := template.Save(config.batchDir, name, false)
+		if !errors.Is(err, fs.ErrExist) {
+			return path, err
+		}
+	}
+}
+
+func hostsOnly(domains []string) []string {
+	hosts := make([]string, len(domains))
+	for i, domain := range domains {
+		hosts[i] = hostOnly(domain)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// missingFromList returns hosts hostlist doesn't cover. Like in winws, entry
+// of list covers its subdomains too.
+func missingFromList(path string, hosts []string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening hostlist: %v", err)
+	}
+	defer file.Close()
+
+	listed := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if line != "" && !strings.HasPrefix(line, "#") {
+			listed[line] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading hostlist: %v", err)
+	}
+
+	var missing []string
+	for _, host := range hosts {
+		covered := false
+		for domain := strings.ToLower(host); domain != "" && !covered; {
+			covered = listed[domain]
+			_, domain, _ = strings.Cut(domain, ".")
+		}
+		if !covered {
+			missing = append(missing, host)
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRunSearch(t *testing.T) {
+	winws := newFakeSupervisor()
+	domain := newBypassServer(t, winws)
+	config := testConfig(t, winws)
+	config.targetDomain = domain
+	config.searchBudget = time.Minute
+	config.searchMaxCandidates = 16
+	config.searchSave = 2
+	config.searchList = "list-general.txt"
+
+	t.Chdir(t.TempDir())
+	if err := os.MkdirAll("lists", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("lists", config.searchList), []byte(hostOnly(domain)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Strategy found by previous search isn't overwritten
+	existing := "SearchFix (" + time.Now().Format("2006-01-02") + " v1).bat"
+	if err := os.MkdirAll(config.batchDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(config.batchDir, existing), []byte("@echo off\r\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// First candidate doesn't start, so it's discarded at screening
+	winws.Failing[filepath.Join(searchDir, "search-001.bat")] = true
+
+	report := newReport(config, []string{domain})
+	outcome, err := runSearch(config, []string{domain}, report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outcome != OutcomeFound {
+		t.Errorf("outcome = %v, want %v", outcome, OutcomeFound)
+	}
+
+	stages := make(map[string]int)
+	for _, pr := range report.Preconfigs {
+		stages[pr.Stage]++
+	}
+	screening := len(screeningCandidates())
+	if want := map[string]int{stageScreening: screening, stageRefinement: config.searchMaxCandidates - screening}; !reflect.DeepEqual(stages, want) {
+		t.Errorf("stages of reported candidates = %v, want %v", stages, want)
+	}
+	if first := report.Preconfigs[0]; first.Stage != stageScreening || first.Started || first.StartError == "" {
+		t.Errorf("discarded candidate is reported as %+v", first)
+	}
+
+	files, err := filepath.Glob(filepath.Join(config.batchDir, "SearchFix*.bat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, file := range files {
+		names = append(names, filepath.Base(file))
+	}
+	want := []string{existing}
+	for _, n := range []string{"v2", "v3"} {
+		want = append(want, "SearchFix ("+time.Now().Format("2006-01-02")+" "+n+").bat")
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("saved pre-configs %v, want %v", names, want)
+	}
+	if _, err := os.Stat(searchDir); !os.IsNotExist(err) {
+		t.Errorf("search directory isn't removed: %v", err)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ankddev/zapret-discord-youtube/internal/dpisim"
+)
+
+// Size of file upstream serves for throughput probe
+const testFileSize = 64 * 1024
+
+// localhostCertificate creates self-signed certificate of upstream site
+func localhostCertificate(t *testing.T) (tls.Certificate, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, leaf
+}
+
+// startSimulator runs DPI simulator with rules from testdata in front of
+// HTTPS site of localhost. It returns domain of site in host:port form of
+// simulator.
+func startSimulator(t *testing.T, rulesFile, stub string) string {
+	t.Helper()
+	certificate, leaf := localhostCertificate(t)
+	site := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bytes.Repeat([]byte("x"), testFileSize))
+	}))
+	site.TLS = &tls.Config{Certificates: []tls.Certificate{certificate}}
+	site.StartTLS()
+	t.Cleanup(site.Close)
+
+	rules, err := dpisim.LoadRules(filepath.Join("testdata", rulesFile), stub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	simulator, err := dpisim.New(dpisim.Config{
+		Rules:      rules,
+		Upstream:   site.Listener.Addr().String(),
+		Reassemble: true,
+		Timeout:    time.Second,
+		Stub:       stub,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go simulator.ServeTCP(listener)
+
+	// Stub certificate is trusted, so it's seen as certificate of other host
+	// instead of unknown one, like certificates of real stub hosts
+	stubLeaf, err := x509.ParseCertificate(simulator.Certificate().Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	pool.AddCert(stubLeaf)
+	rootCAs = pool
+	t.Cleanup(func() { rootCAs = nil })
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	return net.JoinHostPort("localhost", port)
+}
+
+// startStubPage runs page of stub host without known signature in address,
+// it's recognized only by its text
+func startStubPage(t *testing.T) string {
+	t.Helper()
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><body>Доступ к ресурсу ограничен на основании решения Роскомнадзора</body></html>"))
+	}))
+	t.Cleanup(page.Close)
+	return page.URL + "/blocked"
+}
+
+func TestSimulatedBlocks(t *testing.T) {
+	sigs, err := loadBlockSignatures(filepath.Join("..", "..", "lists", "stub-signatures.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		rules string
+		// stub is default stub URL, "page" starts local stub page
+		stub    string
+		timeout time.Duration
+		// class is expected class of probe error, empty for success
+		class string
+		// stubKind is kind of stub page detected, empty if none
+		stubKind string
+		// minHandshake is lower limit of handshake of successful probe
+		minHandshake time.Duration
+		// throttled means download speed must be below minimal
+		throttled bool
+	}{
+		{name: "pass", rules: "pass.rules"},
+		{name: "rst", rules: "rst.rules", class: "reset"},
+		{name: "drop", rules: "drop.rules", timeout: time.Second, class: "timeout"},
+		// Delay holds ClientHello, so handshake takes longer
+		{name: "delay", rules: "delay.rules", minHandshake: 300 * time.Millisecond},
+		// Throttled site passes handshake and is caught only by download speed
+		{name: "throttle", rules: "throttle.rules", throttled: true},
+		{name: "stub redirect", rules: "stub.rules", stub: "http://warning.rt.ru/", class: "stub", stubKind: "redirect"},
+		// Address of page isn't known, text counts because site redirected
+		// to other host
+		{name: "stub page", rules: "stub.rules", stub: "page", class: "stub", stubKind: "body"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stub := test.stub
+			if stub == "" {
+				stub = "http://warning.rt.ru/"
+			} else if stub == "page" {
+				stub = startStubPage(t)
+			}
+			domain := startSimulator(t, test.rules, stub)
+			timeout := test.timeout
+			if timeout == 0 {
+				timeout = 5 * time.Second
+			}
+
+			probe := probeDomain(domain, timeout)
+			if class := classifyError(probe.Err); class != test.class {
+				t.Errorf("probe error %v has class %q, want %q", probe.Err, class, test.class)
+			}
+			if probe.Success && probe.Handshake < test.minHandshake {
+				t.Errorf("handshake took %s, want at least %s", probe.Handshake, test.minHandshake)
+			}
+
+			if result := detectStub(domain, sigs, timeout); result.Kind != test.stubKind {
+				t.Errorf("detected stub %+v, want kind %q", result, test.stubKind)
+			}
+
+			if probe.Success {
+				throughput := probeThroughput("https://"+domain+"/", testFileSize, 10*time.Second)
+				if throughput.Err != nil {
+					t.Fatalf("throughput probe failed: %v", throughput.Err)
+				}
+				const minSpeed = 256 * 1024
+				if recovered := throughput.Recovered(minSpeed, 0); recovered == test.throttled {
+					t.Errorf("speed %s, throttled = %v", formatSpeed(throughput.Speed()), test.throttled)
+				}
+			}
+		})
+	}
+}
+
+func TestCertificateStub(t *testing.T) {
+	sigs, err := loadBlockSignatures(filepath.Join("..", "..", "lists", "stub-signatures.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		stub    string
+		blocked bool
+	}{
+		{name: "known stub host", stub: "http://warning.rt.ru/", blocked: true},
+		{name: "subdomain of stub host", stub: "http://m.warning.rt.ru/", blocked: true},
+		// Certificate of other host alone isn't enough, CDNs present them too
+		{name: "unknown host", stub: "http://cdn.example/"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			domain := startSimulator(t, "stub.rules", test.stub)
+			result := detectCertificateStub(domain, sigs, 5*time.Second)
+			if result.Blocked != test.blocked {
+				t.Errorf("detected %+v, want blocked %v", result, test.blocked)
+			}
+		})
+	}
+}
+
+// TestThrottleRecovery compares speed with pre-config to throttled speed
+// without winws measured through simulator
+func TestThrottleRecovery(t *testing.T) {
+	domain := startSimulator(t, "throttle.rules", "http://warning.rt.ru/")
+	baseline := probeThroughput("https://"+domain+"/", testFileSize, 10*time.Second)
+	if baseline.Err != nil {
+		t.Fatal(baseline.Err)
+	}
+	// Minimal speed is above throttled one, like speed of video which
+	// doesn't play without winws
+	minSpeed := baseline.Speed() * 1.25
+
+	tests := []struct {
+		name      string
+		rules     string
+		recovered bool
+	}{
+		{name: "bypassed", rules: "pass.rules", recovered: true},
+		// Pre-config leaves site throttled, speed is above minimal only
+		// because throttling is a bit weaker
+		{name: "still throttled", rules: "throttle48.rules"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			domain := startSimulator(t, test.rules, "http://warning.rt.ru/")
+			throughput := probeThroughput("https://"+domain+"/", testFileSize, 10*time.Second)
+			if throughput.Err != nil {
+				t.Fatal(throughput.Err)
+			}
+			if throughput.Speed() < minSpeed {
+				t.Fatalf("speed %s is below minimal %s", formatSpeed(throughput.Speed()), formatSpeed(minSpeed))
+			}
+
+			result := PreconfigResult{Throughput: &throughput, MinSpeed: minSpeed, BaselineSpeed: baseline.Speed()}
+			if result.ThroughputRecovered() != test.recovered {
+				t.Errorf("speed %s with baseline %s, recovered = %v, want %v", formatSpeed(throughput.Speed()),
+					formatSpeed(baseline.Speed()), result.ThroughputRecovered(), test.recovered)
+			}
+			// Without baseline speed above minimal is enough
+			result.BaselineSpeed = 0
+			if !result.ThroughputRecovered() {
+				t.Errorf("speed %s above minimal isn't recovered without baseline", formatSpeed(throughput.Speed()))
+			}
+		})
+	}
+}
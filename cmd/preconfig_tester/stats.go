@@ -0,0 +1,47 @@
+package main
+
+import "math"
+
+// z-score for 95% confidence interval
+const confidenceZ = 1.96
+
+// DomainStats summarizes repeated trials of single domain with one pre-config
+type DomainStats struct {
+	Domain    string
+	Trials    int
+	Successes int
+}
+
+// Ratio returns share of successful trials
+func (s DomainStats) Ratio() float64 {
+	if s.Trials == 0 {
+		return 0
+	}
+	return float64(s.Successes) / float64(s.Trials)
+}
+
+// Interval returns 95% Wilson score interval of success ratio. Unlike normal
+// approximation it stays meaningful for small number of trials and for ratios
+// close to 0 or 1, which is exactly what tester sees.
+func (s DomainStats) Interval() (low, high float64) {
+	return wilsonInterval(s.Successes, s.Trials)
+}
+
+// Flaky reports whether domain both passed and failed across trials
+func (s DomainStats) Flaky() bool {
+	return s.Successes > 0 && s.Successes < s.Trials
+}
+
+func wilsonInterval(successes, trials int) (low, high float64) {
+	if trials == 0 {
+		return 0, 0
+	}
+
+	n := float64(trials)
+	p := float64(successes) / n
+	z2 := confidenceZ * confidenceZ
+
+	center := (p + z2/(2*n)) / (1 + z2/n)
+	margin := confidenceZ * math.Sqrt(p*(1-p)/n+z2/(4*n*n)) / (1 + z2/n)
+	return math.Max(0, center-margin), math.Min(1, center+margin)
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ankddev/zapret-discord-youtube/internal/winws"
+)
+
+// Name of service add_to_autorun installs, winws it runs isn't touched by tester
+const autorunServiceName = "zapret_by_ankddev"
+
+// WinwsProcess is running winws instance
+type WinwsProcess struct {
+	PID       int
+	ParentPID int
+}
+
+// Supervisor runs pre-configs and controls winws processes they start. It
+// only ever stops processes it started itself, so winws of autorun service
+// keeps running.
+type Supervisor interface {
+	// Foreign returns winws processes that weren't started by supervisor
+	Foreign() ([]WinwsProcess, error)
+	// Start runs pre-config and waits until winws it started is ready.
+	// Previous pre-config is stopped first. Output of winws is appended to
+	// log file.
+	Start(batFile, logFile string, timeout time.Duration) (WinwsProcess, error)
+	// Stop terminates process tree of running pre-config, if any
+	Stop() error
+}
+
+// Interval between polls of process list
+const supervisorPollInterval = 100 * time.Millisecond
+
+// errStopped is returned by Start interrupted by Stop
+var errStopped = errors.New("stopped while waiting for winws")
+
+// windowsSupervisor runs winws with arguments from pre-config directly, so
+// its output can be captured. Pre-config that can't be parsed is run through
+// cmd instead and winws is tracked by parent PID: "start" in pre-config makes
+// winws child of that cmd.
+type windowsSupervisor struct {
+	processName string
+	output      io.Writer
+
+	// mu guards processes below: Stop is called by signal handler while
+	// Start may be waiting for winws
+	mu      sync.Mutex
+	direct  *exec.Cmd
+	exited  chan struct{}
+	log     *os.File
+	wrapper *exec.Cmd
+	cmdPID  int
+	winws   []int
+
+	// list and kill work with process list of system, tests replace them
+	list func() ([]WinwsProcess, error)
+	kill func(pid int) error
+}
+
+func newWindowsSupervisor(processName string) *windowsSupervisor {
+	s := &windowsSupervisor{processName: processName, output: os.Stdout, kill: killTree}
+	s.list = s.listProcesses
+	return s
+}
+
+// listProcesses returns all processes with given image name
+func (s *windowsSupervisor) listProcesses() ([]WinwsProcess, error) {
+	output, err := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", fmt.Sprintf(
+		`Get-CimInstance Win32_Process -Filter "Name='%s'" | ForEach-Object { "$($_.ProcessId) $($_.ParentProcessId)" }`,
+		s.processName)).Output()
+	if err != nil {
+		return nil, fmt.Errorf("error listing processes: %v", err)
+	}
+
+	var processes []WinwsProcess
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		pid, err1 := strconv.Atoi(fields[0])
+		parent, err2 := strconv.Atoi(fields[1])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		processes = append(processes, WinwsProcess{PID: pid, ParentPID: parent})
+	}
+	return processes, nil
+}
+
+func (s *windowsSupervisor) Foreign() ([]WinwsProcess, error) {
+	processes, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var foreign []WinwsProcess
+	for _, p := range processes {
+		if !s.owns(p) {
+			foreign = append(foreign, p)
+		}
+	}
+	return foreign, nil
+}
+
+func (s *windowsSupervisor) owns(p WinwsProcess) bool {
+	if s.cmdPID != 0 && p.ParentPID == s.cmdPID {
+		return true
+	}
+	if s.direct != nil && s.direct.Process != nil && s.direct.Process.Pid == p.PID {
+		return true
+	}
+	for _, pid := range s.winws {
+		if pid == p.PID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *windowsSupervisor) Start(batFile, logFile string, timeout time.Duration) (WinwsProcess, error) {
+	s.Stop()
+
+	log, err := openRunLog(logFile, batFile)
+	if err != nil {
+		return WinwsProcess{}, err
+	}
+	s.mu.Lock()
+	s.log = log
+
+	command, err := winws.Command(batFile)
+	if err != nil {
+		fmt.Fprintf(log, "Can't parse pre-config, running it through cmd, winws output isn't captured: %v\n", err)
+		s.mu.Unlock()
+		return s.startBat(batFile, timeout)
+	}
+	fmt.Fprintf(log, "%s\n\n", strings.Join(command.Args, " "))
+
+	command.Stdout = log
+	command.Stderr = log
+	if err := command.Start(); err != nil {
+		s.mu.Unlock()
+		return WinwsProcess{}, fmt.Errorf("failed to run %s: %v", s.processName, err)
+	}
+	// Process is visible to Stop before grace period, so interrupt during
+	// it doesn't leave winws running
+	exited := make(chan struct{})
+	s.direct, s.exited = command, exited
+	s.mu.Unlock()
+	go func() {
+		command.Wait()
+		close(exited)
+	}()
+
+	// winws exits right away on unknown option or when WinDivert can't be
+	// initialized, so it's ready once it survived grace period
+	select {
+	case <-exited:
+		s.mu.Lock()
+		stopped := s.exited != exited
+		s.mu.Unlock()
+		if stopped {
+			return WinwsProcess{}, errStopped
+		}
+		return WinwsProcess{}, fmt.Errorf("%s exited right after start: %s", s.processName, command.ProcessState)
+	case <-time.After(min(winws.ReadyGrace, timeout)):
+		return WinwsProcess{PID: command.Process.Pid, ParentPID: os.Getpid()}, nil
+	}
+}
+
+// openRunLog opens log of pre-config run for appending, so retest of same
+// pre-config keeps output of previous attempt
+func openRunLog(path, batFile string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("error creating log directory: %v", err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening log file: %v", err)
+	}
+	fmt.Fprintf(file, "=== %s %s ===\n", time.Now().Format(time.RFC3339), filepath.Base(batFile))
+	return file, nil
+}
+
+// startBat runs pre-config through cmd and waits for winws started by it
+func (s *windowsSupervisor) startBat(batFile string, timeout time.Duration) (WinwsProcess, error) {
+	// Wrapper restores console settings pre-config may change and prints PID
+	// of cmd running pre-config, so winws can be found by its parent PID
+	wrapper := exec.Command("powershell", "-Command", fmt.Sprintf(`
+		# Save console settings
+		$originalForeground = $host.UI.RawUI.ForegroundColor
+		$originalBackground = $host.UI.RawUI.BackgroundColor
+		$originalBufferSize = $host.UI.RawUI.BufferSize
+		$originalWindowSize = $host.UI.RawUI.WindowSize
+
+		# Save font settings
+		$key = 'HKCU:\Console'
+		$originalFontSize = Get-ItemProperty -Path $key -Name 'FontSize' -ErrorAction SilentlyContinue
+		$originalFaceName = Get-ItemProperty -Path $key -Name 'FaceName' -ErrorAction SilentlyContinue
+		$originalFontFamily = Get-ItemProperty -Path $key -Name 'FontFamily' -ErrorAction SilentlyContinue
+
+		try {
+			# Execute BAT file
+			$process = Start-Process -FilePath 'cmd.exe' -ArgumentList '/c', '"%s"' -NoNewWindow -PassThru
+			Write-Output "PID $($process.Id)"
+			$process.WaitForExit()
+		} finally {
+			# Restore console settings
+			$host.UI.RawUI.ForegroundColor = $originalForeground
+			$host.UI.RawUI.BackgroundColor = $originalBackground
+			$host.UI.RawUI.BufferSize = $originalBufferSize
+			$host.UI.RawUI.WindowSize = $originalWindowSize
+
+			# Restore font settings
+			if ($originalFontSize) {
+				Set-ItemProperty -Path $key -Name 'FontSize' -Value $originalFontSize.FontSize
+			}
+			if ($originalFaceName) {
+				Set-ItemProperty -Path $key -Name 'FaceName' -Value $originalFaceName.FaceName
+			}
+			if ($originalFontFamily) {
+				Set-ItemProperty -Path $key -Name 'FontFamily' -Value $originalFontFamily.FontFamily
+			}
+		}
+	`, batFile))
+	wrapper.Stderr = s.output
+
+	stdout, err := wrapper.StdoutPipe()
+	if err != nil {
+		return WinwsProcess{}, err
+	}
+	s.mu.Lock()
+	if err := wrapper.Start(); err != nil {
+		s.mu.Unlock()
+		return WinwsProcess{}, fmt.Errorf("failed to run pre-config: %v", err)
+	}
+	s.wrapper = wrapper
+	s.mu.Unlock()
+
+	pids := make(chan int, 1)
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		sent := false
+		for scanner.Scan() {
+			line := scanner.Text()
+			if !sent && strings.HasPrefix(line, "PID ") {
+				if pid, err := strconv.Atoi(strings.TrimSpace(line[4:])); err == nil {
+					pids <- pid
+					sent = true
+					continue
+				}
+			}
+			fmt.Fprintln(s.output, line)
+		}
+		if !sent {
+			close(pids)
+		}
+	}()
+
+	deadline := time.Now().Add(timeout)
+	select {
+	case pid, ok := <-pids:
+		if !ok {
+			return WinwsProcess{}, fmt.Errorf("pre-config exited before starting")
+		}
+		s.mu.Lock()
+		stopped := s.wrapper != wrapper
+		if !stopped {
+			s.cmdPID = pid
+		}
+		s.mu.Unlock()
+		if stopped {
+			return WinwsProcess{}, errStopped
+		}
+		return s.waitReady(pid, deadline)
+	case <-time.After(timeout):
+		return WinwsProcess{}, fmt.Errorf("pre-config didn't start in %s", timeout)
+	}
+}
+
+// waitReady waits until winws started by cmd with given PID appears and
+// stays alive for grace period
+func (s *windowsSupervisor) waitReady(cmdPID int, deadline time.Time) (WinwsProcess, error) {
+	var candidate WinwsProcess
+	var seenAt time.Time
+	for time.Now().Before(deadline) {
+		processes, err := s.list()
+		if err != nil {
+			return WinwsProcess{}, err
+		}
+
+		var children []WinwsProcess
+		for _, p := range processes {
+			if p.ParentPID == cmdPID {
+				children = append(children, p)
+			}
+		}
+		// winws that appeared after Stop isn't tracked anymore, so it's
+		// killed right away
+		s.mu.Lock()
+		stopped := s.cmdPID != cmdPID
+		for _, p := range children {
+			if stopped {
+				s.kill(p.PID)
+			} else if !containsPID(s.winws, p.PID) {
+				s.winws = append(s.winws, p.PID)
+			}
+		}
+		s.mu.Unlock()
+		if stopped {
+			return WinwsProcess{}, errStopped
+		}
+
+		found := false
+		for _, p := range children {
+			if p.PID == candidate.PID {
+				found = true
+			} else if candidate.PID == 0 {
+				candidate, seenAt, found = p, time.Now(), true
+			}
+		}
+
+		switch {
+		case found && time.Since(seenAt) >= winws.ReadyGrace:
+			return candidate, nil
+		case !found && candidate.PID != 0:
+			return WinwsProcess{}, fmt.Errorf("%s (PID %d) exited right after start", s.processName, candidate.PID)
+		}
+		time.Sleep(supervisorPollInterval)
+	}
+	return WinwsProcess{}, fmt.Errorf("%s not started in time", s.processName)
+}
+
+// Stop can be called any number of times, also while Start is waiting for
+// winws
+func (s *windowsSupervisor) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var firstErr error
+	if s.direct != nil {
+		select {
+		case <-s.exited:
+		default:
+			firstErr = s.direct.Process.Kill()
+			<-s.exited
+		}
+	}
+	if s.log != nil {
+		s.log.Close()
+	}
+	for _, pid := range s.winws {
+		// Process may have already exited, so errors are remembered only to report
+		if err := s.kill(pid); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if s.wrapper != nil && s.wrapper.Process != nil {
+		s.kill(s.wrapper.Process.Pid)
+		s.wrapper.Wait()
+	}
+	s.direct, s.exited, s.log = nil, nil, nil
+	s.wrapper, s.cmdPID, s.winws = nil, 0, nil
+	return firstErr
+}
+
+// killTree terminates process with all its children
+func killTree(pid int) error {
+	return exec.Command("taskkill", "/F", "/T", "/PID", strconv.Itoa(pid)).Run()
+}
+
+func containsPID(pids []int, pid int) bool {
+	for _, p := range pids {
+		if p == pid {
+			return true
+		}
+	}
+	return false
+}
+
+// warnForeignWinws tells about winws started outside of tester. It keeps
+// processing traffic while pre-configs are tested, so results are unreliable.
+func warnForeignWinws(supervisor Supervisor) {
+	foreign, err := supervisor.Foreign()
+	if err != nil || len(foreign) == 0 {
+		return
+	}
+	var pids []string
+	for _, p := range foreign {
+		pids = append(pids, strconv.Itoa(p.PID))
+	}
+	fmt.Printf("%sWarning: winws is already running (PID %s), probably started by %s service or another pre-config.\n"+
+		"Tester won't stop it, but it also processes traffic, so results may be inaccurate. Stop it before testing.%s\n",
+		colorRed, strings.Join(pids, ", "), autorunServiceName, colorReset)
+}
+
+// logTail returns last lines of log file, empty if file can't be read
+func logTail(path string, lines int) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	all := strings.Split(strings.TrimRight(string(content), "\r\n"), "\n")
+	if len(all) > lines {
+		all = all[len(all)-lines:]
+	}
+	return strings.Join(all, "\n")
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// fakeSupervisor pretends to run pre-configs without starting any process, so
+// tester flow can be exercised on any OS. Connections accepted by listener
+// from bypass pass only while fake winws is running, like blocked site that
+// works only through winws.
+type fakeSupervisor struct {
+	// Failing holds pre-configs whose winws "exits" right after start
+	Failing map[string]bool
+	// CrashAfter holds number of connections winws of pre-config passes
+	// before it "crashes"
+	CrashAfter map[string]int
+	// ForeignProcesses are reported as started outside of tester
+	ForeignProcesses []WinwsProcess
+
+	// Started records every pre-config passed to Start in order
+	Started []string
+	// Stopped records PIDs of process trees torn down by Stop
+	Stopped []int
+
+	mu sync.Mutex
+	// running is pre-config currently "running", empty after Stop or crash
+	running string
+	pid     int
+	passed  int
+	nextPID int
+}
+
+func newFakeSupervisor() *fakeSupervisor {
+	return &fakeSupervisor{Failing: make(map[string]bool), CrashAfter: make(map[string]int), nextPID: 1000}
+}
+
+func (s *fakeSupervisor) Foreign() ([]WinwsProcess, error) {
+	return s.ForeignProcesses, nil
+}
+
+func (s *fakeSupervisor) Start(batFile, logFile string, timeout time.Duration) (WinwsProcess, error) {
+	s.Stop()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Started = append(s.Started, batFile)
+	s.nextPID++
+
+	log, err := openRunLog(logFile, batFile)
+	if err != nil {
+		return WinwsProcess{}, err
+	}
+	defer log.Close()
+	if s.Failing[batFile] {
+		fmt.Fprintln(log, "fake winws: exiting")
+		return WinwsProcess{}, fmt.Errorf("winws.exe (PID %d) exited right after start", s.nextPID)
+	}
+	fmt.Fprintln(log, "fake winws: started")
+	s.running, s.pid, s.passed = batFile, s.nextPID, 0
+	return WinwsProcess{PID: s.nextPID, ParentPID: s.nextPID - 1}, nil
+}
+
+func (s *fakeSupervisor) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pid != 0 {
+		s.Stopped = append(s.Stopped, s.pid)
+	}
+	s.running, s.pid = "", 0
+	return nil
+}
+
+// Running returns pre-config whose winws is running
+func (s *fakeSupervisor) Running() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running
+}
+
+// pass reports whether connection gets through. Winws crashing keeps its PID,
+// so Stop still tears down its process tree.
+func (s *fakeSupervisor) pass() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running == "" {
+		return false
+	}
+	s.passed++
+	if limit, ok := s.CrashAfter[s.running]; ok && s.passed >= limit {
+		s.running = ""
+	}
+	return true
+}
+
+// bypassListener resets connections fake winws doesn't pass
+type bypassListener struct {
+	net.Listener
+	winws *fakeSupervisor
+}
+
+func (l *bypassListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.winws.pass() {
+			return conn, nil
+		}
+		conn.(*net.TCPConn).SetLinger(0)
+		conn.Close()
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+)
+
+// ThroughputResult holds outcome of downloading test file
+type ThroughputResult struct {
+	URL      string
+	Bytes    int64
+	TTFB     time.Duration
+	Duration time.Duration
+	Err      error
+}
+
+// Speed returns sustained download speed in bytes per second, measured from
+// first byte, so slow handshake doesn't hide throttling of the stream itself
+func (t ThroughputResult) Speed() float64 {
+	transfer := t.Duration - t.TTFB
+	if t.Bytes == 0 || transfer <= 0 {
+		return 0
+	}
+	return float64(t.Bytes) / transfer.Seconds()
+}
+
+// Speed measured with pre-config must be at least this many times higher than
+// throttled speed without winws, so speed just above minimal because of noise
+// isn't taken for fix
+const throughputRecoveryRatio = 2
+
+// Recovered reports whether download speed reached minimal acceptable speed.
+// When speed without winws was below minimal, it must also be well above that
+// baseline. Baseline of 0 means it wasn't measured.
+func (t ThroughputResult) Recovered(minSpeed, baseline float64) bool {
+	if t.Bytes == 0 || t.Speed() < minSpeed {
+		return false
+	}
+	return baseline == 0 || baseline >= minSpeed || t.Speed() >= baseline*throughputRecoveryRatio
+}
+
+// probeThroughput downloads up to limit bytes from url. Throttled connection
+// usually doesn't fail but crawls, so reaching timeout after some bytes were
+// received is not an error: speed is calculated from what was received.
+func probeThroughput(url string, limit int64, timeout time.Duration) ThroughputResult {
+	result := ThroughputResult{URL: url}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var start, firstByte time.Time
+	trace := &httptrace.ClientTrace{
+		GotFirstResponseByte: func() {
+			firstByte = time.Now()
+		},
+	}
+
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), "GET", url, nil)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	req.Header.Set("User-Agent", "zapret-discord-youtube-tester")
+	req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", limit-1))
+
+	// Don't reuse connections, so every pre-config is measured from scratch
+	client := &http.Client{Transport: &http.Transport{
+		DisableKeepAlives: true,
+		Proxy:             http.ProxyFromEnvironment,
+		TLSClientConfig:   &tls.Config{RootCAs: rootCAs},
+	}}
+
+	start = time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Err = fmt.Errorf("download failed: %v", err)
+		result.Duration = time.Since(start)
+		return result
+	}
+	defer resp.Body.Close()
+
+	if !firstByte.IsZero() {
+		result.TTFB = firstByte.Sub(start)
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		result.Err = fmt.Errorf("download failed with status: %s", resp.Status)
+		result.Duration = time.Since(start)
+		return result
+	}
+
+	result.Bytes, err = io.CopyN(io.Discard, resp.Body, limit)
+	result.Duration = time.Since(start)
+	if err != nil && err != io.EOF && result.Bytes == 0 {
+		result.Err = fmt.Errorf("download failed: %v", err)
+	}
+	return result
+}
+
+func formatSpeed(bytesPerSecond float64) string {
+	switch {
+	case bytesPerSecond >= 1024*1024:
+		return fmt.Sprintf("%.1f MB/s", bytesPerSecond/1024/1024)
+	case bytesPerSecond >= 1024:
+		return fmt.Sprintf("%.0f KB/s", bytesPerSecond/1024)
+	default:
+		return fmt.Sprintf("%.0f B/s", bytesPerSecond)
+	}
+}
+
+func printThroughput(t ThroughputResult, baseline float64, minSpeed float64) {
+	if t.Err != nil {
+		fmt.Printf("%s[FAIL] Throughput probe failed: %v%s\n", colorRed, t.Err, colorReset)
+		return
+	}
+
+	color := colorRed
+	status := "FAIL"
+	if t.Recovered(minSpeed, baseline) {
+		color = colorGreen
+		status = "OK"
+	}
+
+	comparison := ""
+	if baseline > 0 {
+		comparison = fmt.Sprintf(", %.1fx of speed without winws", t.Speed()/baseline)
+	}
+	fmt.Printf("%s[%s] Throughput %s, TTFB %s%s%s\n", color, status, formatSpeed(t.Speed()),
+		t.TTFB.Round(time.Millisecond), comparison, colorReset)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/ankddev/zapret-discord-youtube/internal/winws"
+)
+
+// TestRunFakeWinws runs pre-configs with fake winws in place of winws.exe
+func TestRunFakeWinws(t *testing.T) {
+	bin := t.TempDir()
+	path := filepath.Join(bin, "fake_winws")
+	if runtime.GOOS == "windows" {
+		path += ".exe"
+	}
+	build := exec.Command("go", "build", "-o", path, "github.com/ankddev/zapret-discord-youtube/cmd/fake_winws")
+	if output, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("building fake_winws failed: %v\n%s", err, output)
+	}
+	t.Setenv(winws.EnvPath, path)
+
+	root := t.TempDir()
+	t.Chdir(root)
+	if err := os.MkdirAll(filepath.Join(root, "lists"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "lists", "list-general.txt"), []byte("discord.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		options string
+		// err is error fake winws reports, empty if it started
+		err string
+	}{
+		// Fake winws never exits by itself, crash stops it once it's started
+		{name: "started", options: `--wf-tcp=443 --filter-tcp=443 --hostlist="%~dp0..\lists\list-general.txt" --dpi-desync=fake`},
+		{name: "missing list", options: `--wf-tcp=443 --hostlist="%~dp0..\lists\list-missing.txt" --dpi-desync=fake`, err: "could not read"},
+		{name: "no filter", options: "--dpi-desync=fake", err: "windivert filter isn't set"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logFile := filepath.Join(t.TempDir(), "winws.jsonl")
+			t.Setenv("FAKE_WINWS_LOG", logFile)
+			t.Setenv("FAKE_WINWS_MODE", "crash:100ms")
+
+			name := test.name + ".bat"
+			content := "@echo off\r\ncd /d \"%~dp0..\\\"\r\nset BIN=%~dp0..\\bin\\\r\nstart \"zapret\" /min \"%BIN%winws.exe\" " + test.options + "\r\n"
+			if err := os.MkdirAll(filepath.Join(root, "pre-configs"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(root, "pre-configs", name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			options, _ := getOptions()
+			if !strings.Contains(strings.Join(options, "\n"), name) {
+				t.Fatalf("%s isn't offered in %v", name, options)
+			}
+			// Crashed winws makes run fail either way
+			if err := handleSelection(name); err == nil {
+				t.Fatal("run of crashed winws didn't fail")
+			}
+
+			record, err := os.ReadFile(logFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var run struct {
+				Dir   string `json:"dir"`
+				Mode  string `json:"mode"`
+				Error string `json:"error"`
+			}
+			if err := json.Unmarshal(record, &run); err != nil {
+				t.Fatal(err)
+			}
+			if mustEvalSymlinks(t, run.Dir) != mustEvalSymlinks(t, root) {
+				t.Errorf("winws ran in %s, want root folder %s", run.Dir, root)
+			}
+			if test.err == "" {
+				if run.Error != "" || run.Mode != "crash:100ms" {
+					t.Errorf("winws didn't start: %+v", run)
+				}
+			} else if !strings.Contains(run.Error, test.err) {
+				t.Errorf("winws error = %q, want %q", run.Error, test.err)
+			}
+		})
+	}
+}
+
+func mustEvalSymlinks(t *testing.T, path string) string {
+	t.Helper()
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resolved
+}
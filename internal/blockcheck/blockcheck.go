@@ -0,0 +1,373 @@
+// Package blockcheck parses blockcheck.log written by blockcheck.sh: results
+// of every tested domain and protocol from SUMMARY and all strategies that
+// were marked as available while testing.
+package blockcheck
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Protocol is kind of connection blockcheck tests
+type Protocol string
+
+const (
+	HTTP  Protocol = "http"
+	TLS12 Protocol = "tls12"
+	TLS13 Protocol = "tls13"
+	QUIC  Protocol = "quic"
+)
+
+// Names of blockcheck test functions for every protocol
+var testFunctions = map[string]Protocol{
+	"curl_test_http":        HTTP,
+	"curl_test_https_tls12": TLS12,
+	"curl_test_https_tls13": TLS13,
+	"curl_test_http3":       QUIC,
+}
+
+func (p Protocol) String() string {
+	switch p {
+	case HTTP:
+		return "HTTP"
+	case TLS12:
+		return "TLS 1.2"
+	case TLS13:
+		return "TLS 1.3"
+	case QUIC:
+		return "QUIC"
+	default:
+		return string(p)
+	}
+}
+
+// Status is verdict of blockcheck for domain and protocol
+type Status string
+
+const (
+	// Working means strategy that bypasses DPI was found
+	Working Status = "working"
+	// NotWorking means no tested strategy bypasses DPI
+	NotWorking Status = "not working"
+	// NoBypassNeeded means domain is available without DPI bypass
+	NoBypassNeeded Status = "working without bypass"
+	// Aborted means test was stopped, for example domain doesn't resolve
+	Aborted Status = "aborted"
+)
+
+// Strategy is set of arguments to DPI bypass program
+type Strategy struct {
+	// Daemon is bypass program, "winws" on Windows
+	Daemon string
+	Args   []string
+}
+
+func (s Strategy) String() string {
+	return strings.TrimSpace(s.Daemon + " " + strings.Join(s.Args, " "))
+}
+
+// Get returns value of first argument with given name, like "dpi-desync"
+func (s Strategy) Get(name string) (string, bool) {
+	for _, arg := range s.Args {
+		key, value, _ := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if key == name {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// Result is outcome of testing single domain with single protocol
+type Result struct {
+	IPVersion int
+	Domain    string
+	Protocol  Protocol
+	Status    Status
+	// Strategy is one blockcheck reported in SUMMARY, set when status is Working
+	Strategy Strategy
+	// Available holds every strategy marked as AVAILABLE while testing, in
+	// order they were tested
+	Available []Strategy
+	// Reason explains aborted test
+	Reason string
+}
+
+// Log is parsed blockcheck.log
+type Log struct {
+	Results []Result
+	// HasSummary is false when blockcheck was interrupted before SUMMARY
+	HasSummary bool
+}
+
+// Working returns results of protocols that have working strategy
+func (l *Log) Working() []Result {
+	var working []Result
+	for _, r := range l.Results {
+		if r.Status == Working {
+			working = append(working, r)
+		}
+	}
+	return working
+}
+
+// Load parses blockcheck log from file
+func Load(path string) (*Log, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Parse(file)
+}
+
+type resultKey struct {
+	ipv      int
+	domain   string
+	protocol Protocol
+}
+
+// Parse reads blockcheck log. Available strategies are collected from test
+// sections, verdicts from SUMMARY. Results of log without SUMMARY are built
+// from available strategies only.
+func Parse(r io.Reader) (*Log, error) {
+	log := &Log{}
+	index := make(map[resultKey]int)
+	result := func(key resultKey) *Result {
+		i, ok := index[key]
+		if !ok {
+			i = len(log.Results)
+			index[key] = i
+			log.Results = append(log.Results, Result{IPVersion: key.ipv, Domain: key.domain, Protocol: key.protocol})
+		}
+		return &log.Results[i]
+	}
+
+	var section *resultKey
+	var checking *Strategy
+	inSummary := false
+	summaryLines := 0
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimRight(scanner.Text(), "\r"))
+
+		if inSummary {
+			switch {
+			case line == "":
+				// Empty line after results ends SUMMARY, notes follow it
+				if summaryLines > 0 {
+					inSummary = false
+				}
+				continue
+			case !strings.Contains(line, " : "):
+				// SUMMARY without results is followed by notes right away
+				inSummary = false
+			default:
+				if err := parseSummaryLine(line, result); err != nil {
+					return nil, err
+				}
+				summaryLines++
+				continue
+			}
+		}
+
+		switch {
+		case line == "* SUMMARY":
+			inSummary, log.HasSummary = true, true
+			// Verdicts are taken from SUMMARY only
+			for i := range log.Results {
+				log.Results[i].Status = ""
+			}
+		case strings.HasPrefix(line, "* curl_test_"):
+			// "* curl_test_https_tls12 ipv4 rutracker.org"
+			fields := strings.Fields(line[2:])
+			protocol, ok := testFunctions[fields[0]]
+			if len(fields) != 3 || !ok {
+				section = nil
+				continue
+			}
+			ipv, err := parseIPVersion(fields[1])
+			if err != nil {
+				return nil, err
+			}
+			section = &resultKey{ipv, fields[2], protocol}
+			result(*section)
+			checking = nil
+		case strings.HasPrefix(line, "- checking "):
+			// "- checking winws --wf-l3=ipv4 --wf-tcp=443 --dpi-desync=fake"
+			fields := strings.Fields(strings.TrimPrefix(line, "- checking "))
+			if len(fields) == 0 || strings.HasPrefix(fields[0], "-") || fields[0] == "without" {
+				checking = nil
+				continue
+			}
+			checking = &Strategy{Daemon: fields[0], Args: fields[1:]}
+		case line == "!!!!! AVAILABLE !!!!!":
+			if section != nil && checking != nil {
+				res := result(*section)
+				res.Available = append(res.Available, *checking)
+				res.Status = Working
+			}
+			checking = nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if !log.HasSummary {
+		for i := range log.Results {
+			if log.Results[i].Status == "" {
+				log.Results[i].Status = NotWorking
+			} else if len(log.Results[i].Available) > 0 {
+				log.Results[i].Strategy = log.Results[i].Available[0]
+			}
+		}
+	}
+	return log, nil
+}
+
+// parseSummaryLine reads line like
+// "ipv4 rutracker.org curl_test_https_tls12 : winws --wf-l3=ipv4 --wf-tcp=443 --dpi-desync=fake"
+func parseSummaryLine(line string, result func(resultKey) *Result) error {
+	head, verdict, ok := strings.Cut(line, " : ")
+	fields := strings.Fields(head)
+	if !ok || len(fields) != 3 {
+		return fmt.Errorf("invalid SUMMARY line: %s", line)
+	}
+	protocol, ok := testFunctions[fields[2]]
+	if !ok {
+		return fmt.Errorf("unknown test in SUMMARY line: %s", line)
+	}
+	ipv, err := parseIPVersion(fields[0])
+	if err != nil {
+		return err
+	}
+
+	res := result(resultKey{ipv, fields[1], protocol})
+	verdict = strings.TrimSpace(verdict)
+	switch {
+	case verdict == string(NoBypassNeeded):
+		res.Status = NoBypassNeeded
+	case strings.HasPrefix(verdict, "test aborted"):
+		res.Status = Aborted
+		res.Reason = verdict
+	case strings.HasSuffix(verdict, " not working"):
+		// tpws and winws are reported separately, strategy of one of them
+		// may already be found
+		if res.Status != Working {
+			res.Status = NotWorking
+		}
+	default:
+		fields := strings.Fields(verdict)
+		res.Status = Working
+		res.Strategy = Strategy{Daemon: fields[0], Args: fields[1:]}
+	}
+	return nil
+}
+
+func parseIPVersion(s string) (int, error) {
+	switch s {
+	case "ipv4":
+		return 4, nil
+	case "ipv6":
+		return 6, nil
+	default:
+		return 0, fmt.Errorf("unknown IP version: %s", s)
+	}
+}
+
+// Choice is strategy chosen for group of protocols
+type Choice struct {
+	Strategy Strategy
+	// Domains holds domains strategy works for
+	Domains []string
+}
+
+// Choose picks strategy that works for most domains across given protocols.
+// Ties are resolved in favor of strategies blockcheck reported in SUMMARY,
+// then of strategies tested earlier, as blockcheck tests simpler ones first.
+// Arguments of WinDivert filter (--wf-*) are dropped: they depend on how
+// blockcheck ran test, not on strategy.
+func (l *Log) Choose(protocols ...Protocol) (Choice, bool) {
+	type candidate struct {
+		strategy  Strategy
+		domains   []string
+		summary   bool
+		firstSeen int
+	}
+	candidates := make(map[string]*candidate)
+	var order []string
+
+	add := func(s Strategy, domain string, summary bool) {
+		s = s.withoutFilter()
+		key := s.String()
+		c, ok := candidates[key]
+		if !ok {
+			c = &candidate{strategy: s, firstSeen: len(order)}
+			candidates[key] = c
+			order = append(order, key)
+		}
+		c.summary = c.summary || summary
+		for _, d := range c.domains {
+			if d == domain {
+				return
+			}
+		}
+		c.domains = append(c.domains, domain)
+	}
+
+	for _, r := range l.Results {
+		if r.Status != Working || !containsProtocol(protocols, r.Protocol) {
+			continue
+		}
+		if len(r.Strategy.Args) > 0 {
+			add(r.Strategy, r.Domain, true)
+		}
+		for _, s := range r.Available {
+			add(s, r.Domain, false)
+		}
+	}
+	if len(order) == 0 {
+		return Choice{}, false
+	}
+
+	best := candidates[order[0]]
+	for _, key := range order[1:] {
+		c := candidates[key]
+		switch {
+		case len(c.domains) != len(best.domains):
+			if len(c.domains) > len(best.domains) {
+				best = c
+			}
+		case c.summary != best.summary:
+			if c.summary {
+				best = c
+			}
+		}
+	}
+	return Choice{Strategy: best.strategy, Domains: best.domains}, true
+}
+
+// withoutFilter returns strategy without WinDivert filter arguments
+func (s Strategy) withoutFilter() Strategy {
+	result := Strategy{Daemon: s.Daemon}
+	for _, arg := range s.Args {
+		if !strings.HasPrefix(arg, "--wf-") {
+			result.Args = append(result.Args, arg)
+		}
+	}
+	return result
+}
+
+func containsProtocol(protocols []Protocol, protocol Protocol) bool {
+	for _, p := range protocols {
+		if p == protocol {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+package blockcheck
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// want is expected result of single domain and protocol
+type want struct {
+	ipv       int
+	domain    string
+	protocol  Protocol
+	status    Status
+	strategy  string
+	available int
+	reason    string
+}
+
+func load(t *testing.T, name string) *Log {
+	t.Helper()
+	log, err := Load(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("Load(%s): %v", name, err)
+	}
+	return log
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		file       string
+		hasSummary bool
+		results    []want
+	}{
+		{
+			file:       "complete.log",
+			hasSummary: true,
+			results: []want{
+				{4, "rutracker.org", HTTP, Working, "winws --wf-l3=ipv4 --wf-tcp=80 --dpi-desync=split2", 2, ""},
+				{4, "rutracker.org", TLS12, Working, "winws --wf-l3=ipv4 --wf-tcp=443 --dpi-desync=fake,split2 --dpi-desync-autottl=2 --dpi-desync-fooling=md5sig", 1, ""},
+				// "tpws not working" after winws strategy doesn't override it
+				{4, "rutracker.org", TLS13, Working, "winws --wf-l3=ipv4 --wf-tcp=443 --dpi-desync=fake,split2 --dpi-desync-autottl=2 --dpi-desync-fooling=md5sig", 1, ""},
+				{4, "rutracker.org", QUIC, Working, "winws --wf-l3=ipv4 --wf-udp=443 --dpi-desync=fake", 1, ""},
+				{4, "discord.com", TLS12, Working, "winws --wf-l3=ipv4 --wf-tcp=443 --dpi-desync=split2 --dpi-desync-split-pos=1", 2, ""},
+				// AVAILABLE of check without bypass isn't strategy
+				{6, "rutracker.org", HTTP, NoBypassNeeded, "", 0, ""},
+				{6, "rutracker.org", TLS12, Aborted, "", 0, "test aborted, no reason to continue. curl code 7 (failed to connect)"},
+				{6, "rutracker.org", TLS13, NotWorking, "", 0, ""},
+				{6, "rutracker.org", QUIC, Working, "winws --wf-l3=ipv6 --wf-udp=443 --dpi-desync=fake --dpi-desync-repeats=6", 1, ""},
+			},
+		},
+		{
+			// Without SUMMARY first available strategy is chosen and
+			// sections without any are not working
+			file: "interrupted.log",
+			results: []want{
+				{4, "rutracker.org", HTTP, Working, "winws --wf-l3=ipv4 --wf-tcp=80 --dpi-desync=split2", 2, ""},
+				{4, "rutracker.org", TLS12, NotWorking, "", 0, ""},
+			},
+		},
+		{
+			// Notes follow SUMMARY without results
+			file:       "empty_summary.log",
+			hasSummary: true,
+		},
+		{
+			file:       "crlf.log",
+			hasSummary: true,
+			results: []want{
+				{4, "rutracker.org", HTTP, Working, "winws --wf-l3=ipv4 --wf-tcp=80 --dpi-desync=split2", 0, ""},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			log := load(t, test.file)
+			if log.HasSummary != test.hasSummary {
+				t.Errorf("HasSummary = %v, want %v", log.HasSummary, test.hasSummary)
+			}
+			var got []want
+			for _, r := range log.Results {
+				got = append(got, want{r.IPVersion, r.Domain, r.Protocol, r.Status, r.Strategy.String(), len(r.Available), r.Reason})
+			}
+			if !reflect.DeepEqual(got, test.results) {
+				t.Errorf("results differ\ngot:  %+v\nwant: %+v", got, test.results)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"short summary line": "* SUMMARY\nrutracker.org curl_test_http : winws --dpi-desync=split2\n",
+		"unknown test":       "* SUMMARY\nipv4 rutracker.org curl_test_ftp : winws --dpi-desync=split2\n",
+		"unknown IP version": "* curl_test_http ipv5 rutracker.org\n",
+	}
+	for name, log := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Parse(strings.NewReader(log)); err == nil {
+				t.Error("Parse succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestChoose(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		protocols []Protocol
+		ok        bool
+		strategy  string
+		domains   []string
+	}{
+		{
+			// Strategy working for both domains wins over one from SUMMARY
+			// of discord.com
+			name:      "most domains",
+			file:      "complete.log",
+			protocols: []Protocol{TLS12, TLS13},
+			ok:        true,
+			strategy:  "winws --dpi-desync=fake,split2 --dpi-desync-autottl=2 --dpi-desync-fooling=md5sig",
+			domains:   []string{"rutracker.org", "discord.com"},
+		},
+		{
+			// Both work for one domain, strategy from SUMMARY wins
+			name:      "summary",
+			file:      "complete.log",
+			protocols: []Protocol{HTTP},
+			ok:        true,
+			strategy:  "winws --dpi-desync=split2",
+			domains:   []string{"rutracker.org"},
+		},
+		{
+			// Both are in SUMMARY, strategy tested first wins. Results of
+			// IPv4 and IPv6 count as same domain.
+			name:      "first seen",
+			file:      "complete.log",
+			protocols: []Protocol{QUIC},
+			ok:        true,
+			strategy:  "winws --dpi-desync=fake",
+			domains:   []string{"rutracker.org"},
+		},
+		{
+			name:      "interrupted",
+			file:      "interrupted.log",
+			protocols: []Protocol{HTTP},
+			ok:        true,
+			strategy:  "winws --dpi-desync=split2",
+			domains:   []string{"rutracker.org"},
+		},
+		{
+			name:      "nothing works",
+			file:      "interrupted.log",
+			protocols: []Protocol{TLS12, TLS13},
+		},
+		{
+			name:      "empty summary",
+			file:      "empty_summary.log",
+			protocols: []Protocol{HTTP, TLS12, TLS13, QUIC},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			choice, ok := load(t, test.file).Choose(test.protocols...)
+			if ok != test.ok {
+				t.Fatalf("Choose ok = %v, want %v", ok, test.ok)
+			}
+			if !ok {
+				return
+			}
+			if got := choice.Strategy.String(); got != test.strategy {
+				t.Errorf("strategy = %q, want %q", got, test.strategy)
+			}
+			if !reflect.DeepEqual(choice.Domains, test.domains) {
+				t.Errorf("domains = %v, want %v", choice.Domains, test.domains)
+			}
+		})
+	}
+}
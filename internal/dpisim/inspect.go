@@ -0,0 +1,94 @@
+package dpisim
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ankddev/zapret-discord-youtube/internal/fake"
+)
+
+// DPI gives up on requests larger than this
+const maxInspectSize = 16 * 1024
+
+// readRequest reads first data of client: whole TLS record or HTTP headers.
+// Without reassembly only first read is inspected, like stateless DPI that
+// looks at single packet, so split requests pass it.
+func readRequest(conn net.Conn, reassemble bool, timeout time.Duration) []byte {
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	buffer := make([]byte, maxInspectSize)
+	var data []byte
+	for len(data) < maxInspectSize {
+		n, err := conn.Read(buffer[:maxInspectSize-len(data)])
+		data = append(data, buffer[:n]...)
+		if err != nil || !reassemble || requestComplete(data) {
+			break
+		}
+	}
+	return data
+}
+
+// requestComplete reports whether data holds whole TLS record or HTTP headers,
+// or can't be any of them
+func requestComplete(data []byte) bool {
+	if len(data) > 0 && data[0] == 0x16 {
+		if len(data) < 5 {
+			return false
+		}
+		return len(data) >= 5+int(binary.BigEndian.Uint16(data[3:]))
+	}
+	if fake.Detect(data) == fake.KindHTTP || isHTTPPrefix(data) {
+		return bytes.Contains(data, []byte("\r\n\r\n"))
+	}
+	return true
+}
+
+// isHTTPPrefix reports whether data may be start of HTTP request too short
+// to detect
+func isHTTPPrefix(data []byte) bool {
+	for _, method := range []string{"GET ", "POST ", "HEAD ", "PUT ", "OPTIONS "} {
+		if len(data) < len(method) && strings.HasPrefix(method, string(data)) {
+			return true
+		}
+	}
+	return false
+}
+
+// identify returns protocol and host of first data of TCP client
+func identify(data []byte) (proto, host string) {
+	switch {
+	case fake.IsTLSClientHello(data):
+		if hello, err := fake.ParseClientHello(data); err == nil && len(hello.SNI) > 0 {
+			return ProtoTLS, hello.SNI[0]
+		}
+		return ProtoTLS, ""
+	case fake.Detect(data) == fake.KindHTTP:
+		if request, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(data))); err == nil {
+			host, _, err := net.SplitHostPort(request.Host)
+			if err != nil {
+				return ProtoHTTP, request.Host
+			}
+			return ProtoHTTP, host
+		}
+		return ProtoHTTP, ""
+	}
+	return ProtoAny, ""
+}
+
+// identifyQUIC returns SNI of QUIC Initial datagram
+func identifyQUIC(data []byte) (string, bool) {
+	if !fake.IsQUICInitial(data) {
+		return "", false
+	}
+	packet, err := fake.ParseQUICInitial(data)
+	if err != nil || packet.ClientHello == nil || len(packet.ClientHello.SNI) == 0 {
+		return "", true
+	}
+	return packet.ClientHello.SNI[0], true
+}
@@ -0,0 +1,74 @@
+package dpisim
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+)
+
+// Socket options of netfilter, not exported by syscall
+const (
+	soOriginalDst     = 80
+	ip6tSoOriginalDst = 80
+)
+
+// originalDestination returns address client connected to before iptables
+// REDIRECT sent connection to simulator
+func originalDestination(conn *net.TCPConn) (netip.AddrPort, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+
+	var destination netip.AddrPort
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		local := conn.LocalAddr().(*net.TCPAddr)
+		if local.IP.To4() != nil {
+			// sockaddr_in fits into ipv6_mreq, so its getter is used
+			mreq, err := syscall.GetsockoptIPv6Mreq(int(fd), syscall.SOL_IP, soOriginalDst)
+			if err != nil {
+				sockErr = err
+				return
+			}
+			port := binary.BigEndian.Uint16(mreq.Multiaddr[2:4])
+			destination = netip.AddrPortFrom(netip.AddrFrom4([4]byte(mreq.Multiaddr[4:8])), port)
+			return
+		}
+		info, err := syscall.GetsockoptIPv6MTUInfo(int(fd), syscall.SOL_IPV6, ip6tSoOriginalDst)
+		if err != nil {
+			sockErr = err
+			return
+		}
+		// Port is kept in network byte order
+		port := binary.BigEndian.Uint16(binary.NativeEndian.AppendUint16(nil, info.Addr.Port))
+		destination = netip.AddrPortFrom(netip.AddrFrom16(info.Addr.Addr), port)
+	})
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	if sockErr != nil {
+		return netip.AddrPort{}, fmt.Errorf("connection wasn't redirected by iptables: %v", sockErr)
+	}
+	return destination, nil
+}
+
+// markControl sets fwmark on upstream sockets, so iptables rule can skip them
+// instead of redirecting back to simulator
+func markControl(mark int) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		var sockErr error
+		err := c.Control(func(fd uintptr) {
+			sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_MARK, mark)
+		})
+		if err != nil {
+			return err
+		}
+		if sockErr != nil {
+			return fmt.Errorf("error setting mark, CAP_NET_ADMIN is required: %v", sockErr)
+		}
+		return nil
+	}
+}
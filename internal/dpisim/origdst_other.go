@@ -0,0 +1,22 @@
+//go:build !linux
+
+package dpisim
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+)
+
+// originalDestination needs netfilter, on other systems upstream is taken
+// from -upstream flag or from SNI and Host
+func originalDestination(conn *net.TCPConn) (netip.AddrPort, error) {
+	return netip.AddrPort{}, fmt.Errorf("original destination is available only on Linux")
+}
+
+func markControl(mark int) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		return fmt.Errorf("marks are available only on Linux")
+	}
+}
@@ -0,0 +1,202 @@
+package dpisim
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Action is what simulator does with matched connection
+type Action string
+
+const (
+	// ActionPass forwards connection untouched
+	ActionPass Action = "pass"
+	// ActionRST resets connection to client, like DPI injecting RST
+	ActionRST Action = "rst"
+	// ActionDrop silently stops forwarding, connection hangs until timeout
+	ActionDrop Action = "drop"
+	// ActionDelay holds first data of client for given time
+	ActionDelay Action = "delay"
+	// ActionThrottle limits speed of server answer
+	ActionThrottle Action = "throttle"
+	// ActionStub answers with redirect to stub page instead of server
+	ActionStub Action = "stub"
+)
+
+// Protocols rule can be limited to
+const (
+	ProtoAny  = ""
+	ProtoTLS  = "tls"
+	ProtoHTTP = "http"
+	ProtoQUIC = "quic"
+)
+
+// Rule applies action to connections to host and its subdomains
+type Rule struct {
+	// Proto limits rule to one protocol, empty matches all
+	Proto string
+	// Host is domain, "*" matches any connection including unidentified
+	Host   string
+	Action Action
+	// After is number of server bytes passed before rst or drop, 0 acts
+	// right after request
+	After int64
+	// Delay for delay action
+	Delay time.Duration
+	// Rate in bytes per second for throttle action
+	Rate int64
+	// URL stub redirects to
+	URL string
+	// Line of rules file, for logs
+	Line int
+}
+
+func (r Rule) String() string {
+	host := r.Host
+	if r.Proto != ProtoAny {
+		host = r.Proto + ":" + host
+	}
+	switch r.Action {
+	case ActionRST, ActionDrop:
+		if r.After > 0 {
+			return fmt.Sprintf("%s %s after %d bytes", host, r.Action, r.After)
+		}
+	case ActionDelay:
+		return fmt.Sprintf("%s %s %s", host, r.Action, r.Delay)
+	case ActionThrottle:
+		return fmt.Sprintf("%s %s %d bytes/s", host, r.Action, r.Rate)
+	case ActionStub:
+		return fmt.Sprintf("%s %s %s", host, r.Action, r.URL)
+	}
+	return fmt.Sprintf("%s %s", host, r.Action)
+}
+
+// Matches reports whether rule applies to connection of proto to host
+func (r Rule) Matches(proto, host string) bool {
+	if r.Proto != ProtoAny && r.Proto != proto {
+		return false
+	}
+	if r.Host == "*" {
+		return true
+	}
+	host = strings.ToLower(host)
+	return host == r.Host || strings.HasSuffix(host, "."+r.Host)
+}
+
+// LoadRules reads rules file. Every line is "[proto:]host action [argument]",
+// first matching rule wins, connections without matching rule pass.
+func LoadRules(path, defaultStub string) ([]Rule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening rules file: %v", err)
+	}
+	defer file.Close()
+
+	var rules []Rule
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule, err := parseRule(strings.Fields(line), defaultStub)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule at line %d: %v", lineNumber, err)
+		}
+		rule.Line = lineNumber
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading rules file: %v", err)
+	}
+	return rules, nil
+}
+
+func parseRule(fields []string, defaultStub string) (Rule, error) {
+	var rule Rule
+	if len(fields) < 2 || len(fields) > 3 {
+		return rule, fmt.Errorf("expected host, action and optional argument")
+	}
+	rule.Host = strings.ToLower(fields[0])
+	if proto, host, ok := strings.Cut(rule.Host, ":"); ok {
+		switch proto {
+		case ProtoTLS, ProtoHTTP, ProtoQUIC:
+		default:
+			return rule, fmt.Errorf("unknown protocol '%s'", proto)
+		}
+		rule.Proto, rule.Host = proto, host
+	}
+	rule.Action = Action(fields[1])
+	argument := ""
+	if len(fields) == 3 {
+		argument = fields[2]
+	}
+
+	var err error
+	switch rule.Action {
+	case ActionPass:
+		if argument != "" {
+			return rule, fmt.Errorf("pass takes no argument")
+		}
+	case ActionRST, ActionDrop:
+		if argument != "" {
+			rule.After, err = parseSize(argument)
+		}
+	case ActionDelay:
+		rule.Delay, err = time.ParseDuration(argument)
+		if err == nil && rule.Delay <= 0 {
+			err = fmt.Errorf("delay must be positive")
+		}
+	case ActionThrottle:
+		rule.Rate, err = parseSize(argument)
+		if err == nil && rule.Rate <= 0 {
+			err = fmt.Errorf("rate must be positive")
+		}
+	case ActionStub:
+		rule.URL = defaultStub
+		if argument != "" {
+			rule.URL = argument
+		}
+	default:
+		return rule, fmt.Errorf("unknown action '%s'", rule.Action)
+	}
+	return rule, err
+}
+
+// parseSize parses number of bytes with optional k or m suffix
+func parseSize(value string) (int64, error) {
+	if value == "" {
+		return 0, fmt.Errorf("size is required")
+	}
+	multiplier := int64(1)
+	switch strings.ToLower(value[len(value)-1:]) {
+	case "k":
+		multiplier = 1024
+	case "m":
+		multiplier = 1024 * 1024
+	}
+	if multiplier > 1 {
+		value = value[:len(value)-1]
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid size '%s'", value)
+	}
+	return size * multiplier, nil
+}
+
+// match returns first rule for connection, pass rule if none matches
+func match(rules []Rule, proto, host string) Rule {
+	for _, rule := range rules {
+		if rule.Matches(proto, host) {
+			return rule
+		}
+	}
+	return Rule{Host: "*", Action: ActionPass}
+}
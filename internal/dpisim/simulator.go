@@ -0,0 +1,91 @@
+// Package dpisim is proxy that blocks connections like DPI of ISP: it reads
+// SNI of ClientHello, Host of HTTP request and SNI of QUIC Initial and
+// applies rules to them. It lets probes of tester be checked without
+// censored ISP.
+package dpisim
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Config of simulator
+type Config struct {
+	Rules []Rule
+	// Upstream replaces destination of every connection when set
+	Upstream string
+	// DNS is server hosts are resolved with, system resolver when empty
+	DNS string
+	// Mark is fwmark of upstream connections, 0 leaves them unmarked
+	Mark int
+	// Reassemble makes simulator wait for whole request, otherwise only
+	// first segment is inspected
+	Reassemble bool
+	// Timeout to wait for request of client
+	Timeout time.Duration
+	// Stub is URL stub redirects to by default, its host gets certificate
+	Stub    string
+	Verbose bool
+}
+
+// Simulator applies rules to connections it proxies
+type Simulator struct {
+	rules []Rule
+	// upstream replaces destination of every connection when set
+	upstream string
+	resolver *net.Resolver
+	dialer   *net.Dialer
+	// reassemble makes simulator wait for whole request, otherwise only
+	// first segment is inspected
+	reassemble     bool
+	inspectTimeout time.Duration
+	// certificate is presented by stub instead of certificate of site
+	certificate tls.Certificate
+	verbose     bool
+}
+
+// New creates simulator. Rules are applied in order, first matching wins.
+func New(config Config) (*Simulator, error) {
+	certificate, err := stubCertificate(config.Stub)
+	if err != nil {
+		return nil, fmt.Errorf("error creating stub certificate: %v", err)
+	}
+
+	s := &Simulator{
+		rules:          config.Rules,
+		upstream:       config.Upstream,
+		resolver:       net.DefaultResolver,
+		dialer:         &net.Dialer{Timeout: 10 * time.Second},
+		reassemble:     config.Reassemble,
+		inspectTimeout: config.Timeout,
+		certificate:    certificate,
+		verbose:        config.Verbose,
+	}
+	if config.Mark != 0 {
+		s.dialer.Control = markControl(config.Mark)
+	}
+	if config.DNS != "" {
+		// Resolver of system may point blocked hosts at simulator itself
+		s.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				return s.dialer.DialContext(ctx, network, config.DNS)
+			},
+		}
+	}
+	return s, nil
+}
+
+// Certificate returns self-signed certificate stub presents for HTTPS, tests
+// trust it to see it as certificate of other host
+func (s *Simulator) Certificate() tls.Certificate {
+	return s.certificate
+}
+
+// logf prints event with time, events of connections are interleaved
+func (s *Simulator) logf(format string, args ...any) {
+	fmt.Printf("%s %s\n", time.Now().Format("15:04:05.000"), fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,93 @@
+package dpisim
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// stubCertificate creates self-signed certificate for host of stub page. DPI
+// answering instead of HTTPS site can't present certificate of site, so client
+// sees certificate of other host.
+func stubCertificate(stubURL string) (tls.Certificate, error) {
+	host := "stub.invalid"
+	if parsed, err := url.Parse(stubURL); err == nil && parsed.Hostname() != "" {
+		host = parsed.Hostname()
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{certificate}, PrivateKey: key}, nil
+}
+
+// stubResponse is redirect ISP stub page answers with
+func stubResponse(location string) []byte {
+	body := fmt.Sprintf("<html><body>Access to this resource is restricted. <a href=\"%s\">Details</a></body></html>", location)
+	return []byte(fmt.Sprintf("HTTP/1.1 302 Found\r\nLocation: %s\r\nContent-Type: text/html\r\nContent-Length: %d\r\nConnection: close\r\n\r\n%s",
+		location, len(body), body))
+}
+
+// serveStub answers client with stub page. For TLS handshake is completed with
+// stub certificate, request is read from already received ClientHello and
+// rest of connection.
+func serveStub(conn net.Conn, proto string, request []byte, rule Rule, certificate tls.Certificate) error {
+	response := stubResponse(rule.URL)
+	switch proto {
+	case ProtoHTTP:
+		_, err := conn.Write(response)
+		return err
+	case ProtoTLS:
+		server := tls.Server(&prefixConn{Conn: conn, prefix: request}, &tls.Config{Certificates: []tls.Certificate{certificate}})
+		server.SetDeadline(time.Now().Add(10 * time.Second))
+		if err := server.Handshake(); err != nil {
+			return err
+		}
+		if _, err := http.ReadRequest(bufio.NewReader(server)); err != nil {
+			return err
+		}
+		_, err := server.Write(response)
+		server.Close()
+		return err
+	}
+	return fmt.Errorf("stub isn't supported for this protocol")
+}
+
+// prefixConn returns already read data before reading from connection
+type prefixConn struct {
+	net.Conn
+	prefix []byte
+}
+
+func (c *prefixConn) Read(p []byte) (int, error) {
+	if len(c.prefix) > 0 {
+		n := copy(p, c.prefix)
+		c.prefix = c.prefix[n:]
+		return n, nil
+	}
+	return c.Conn.Read(p)
+}
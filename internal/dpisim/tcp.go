@@ -0,0 +1,237 @@
+package dpisim
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ServeTCP accepts connections until listener is closed
+func (s *Simulator) ServeTCP(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handleTCP(conn.(*net.TCPConn))
+	}
+}
+
+func (s *Simulator) handleTCP(conn *net.TCPConn) {
+	defer conn.Close()
+	client := conn.RemoteAddr().String()
+
+	request := readRequest(conn, s.reassemble, s.inspectTimeout)
+	proto, host := identify(request)
+	rule := match(s.rules, proto, host)
+	name := describe(proto, host)
+	s.logf("tcp %s -> %s: %s", client, name, rule)
+
+	switch {
+	case rule.Action == ActionRST && rule.After == 0:
+		reset(conn)
+		return
+	case rule.Action == ActionDrop && rule.After == 0:
+		// Request never reaches server, client waits for answer till timeout
+		io.Copy(io.Discard, conn)
+		return
+	case rule.Action == ActionStub:
+		if err := serveStub(conn, proto, request, rule, s.certificate); err != nil {
+			s.logf("tcp %s -> %s: stub failed: %v", client, name, err)
+			reset(conn)
+		}
+		return
+	case rule.Action == ActionDelay:
+		time.Sleep(rule.Delay)
+	}
+
+	destination, err := s.destination(conn, proto, host)
+	if err != nil {
+		s.logf("tcp %s -> %s: %v", client, name, err)
+		reset(conn)
+		return
+	}
+	upstream, err := s.dialer.Dial("tcp", destination)
+	if err != nil {
+		s.logf("tcp %s -> %s: error connecting to %s: %v", client, name, destination, err)
+		reset(conn)
+		return
+	}
+	defer upstream.Close()
+	if _, err := upstream.Write(request); err != nil {
+		reset(conn)
+		return
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		io.Copy(upstream, conn)
+		upstream.(*net.TCPConn).CloseWrite()
+	}()
+	received, err := copyAnswer(conn, upstream, rule)
+	if s.verbose {
+		s.logf("tcp %s -> %s: server sent %d bytes", client, name, received)
+	}
+	switch {
+	case err == errReset:
+		s.logf("tcp %s -> %s: reset after %d bytes", client, name, received)
+		reset(conn)
+		upstream.Close()
+	case err == errDrop:
+		s.logf("tcp %s -> %s: dropping after %d bytes", client, name, received)
+		// Answer of server is thrown away, client waits for it till timeout
+		go io.Copy(io.Discard, upstream)
+	default:
+		conn.CloseWrite()
+	}
+	wg.Wait()
+}
+
+var (
+	errReset = fmt.Errorf("reset by rule")
+	errDrop  = fmt.Errorf("dropped by rule")
+)
+
+// copyAnswer forwards answer of server to client, applying throttle, and
+// rst or drop after given number of bytes
+func copyAnswer(client, server net.Conn, rule Rule) (int64, error) {
+	var limiter *limiter
+	if rule.Action == ActionThrottle {
+		limiter = newLimiter(rule.Rate)
+	}
+	limit := int64(-1)
+	if (rule.Action == ActionRST || rule.Action == ActionDrop) && rule.After > 0 {
+		limit = rule.After
+	}
+
+	buffer := make([]byte, 32*1024)
+	var sent int64
+	for {
+		chunk := buffer
+		if limiter != nil {
+			chunk = buffer[:limiter.chunk(len(buffer))]
+		}
+		if limit >= 0 && int64(len(chunk)) > limit-sent {
+			chunk = chunk[:limit-sent]
+		}
+		if len(chunk) == 0 {
+			if rule.Action == ActionRST {
+				return sent, errReset
+			}
+			return sent, errDrop
+		}
+
+		n, err := server.Read(chunk)
+		if n > 0 {
+			if limiter != nil {
+				limiter.wait(n)
+			}
+			if _, err := client.Write(chunk[:n]); err != nil {
+				return sent, err
+			}
+			sent += int64(n)
+		}
+		if err != nil {
+			if err == io.EOF {
+				return sent, nil
+			}
+			return sent, err
+		}
+	}
+}
+
+// destination returns address to connect to: -upstream, address client
+// connected to before iptables redirect or address of host from request
+func (s *Simulator) destination(conn *net.TCPConn, proto, host string) (string, error) {
+	if s.upstream != "" {
+		return s.upstream, nil
+	}
+	if original, err := originalDestination(conn); err == nil && !isLocal(original, conn) {
+		return original.String(), nil
+	}
+	port := 443
+	if proto == ProtoHTTP {
+		port = 80
+	}
+	return s.resolve(host, port)
+}
+
+// resolve returns address of host, used when destination can't be known
+// other way
+func (s *Simulator) resolve(host string, port int) (string, error) {
+	if host == "" {
+		return "", fmt.Errorf("unknown destination: no redirect, no -upstream and no host in request")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.inspectTimeout)
+	defer cancel()
+	addrs, err := s.resolver.LookupHost(ctx, host)
+	if err != nil {
+		return "", fmt.Errorf("error resolving %s: %v", host, err)
+	}
+	return net.JoinHostPort(addrs[0], strconv.Itoa(port)), nil
+}
+
+// isLocal reports whether destination is simulator itself, which means
+// connection wasn't redirected
+func isLocal(destination netip.AddrPort, conn net.Conn) bool {
+	local, err := netip.ParseAddrPort(conn.LocalAddr().String())
+	return err == nil && local.Port() == destination.Port() && local.Addr().Unmap() == destination.Addr().Unmap()
+}
+
+// reset closes connection with RST instead of FIN
+func reset(conn net.Conn) {
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
+
+func describe(proto, host string) string {
+	if proto == ProtoAny {
+		proto = "unknown"
+	}
+	if host == "" {
+		host = "no host"
+	}
+	return fmt.Sprintf("%s %s", proto, host)
+}
+
+// limiter spreads data evenly at given rate
+type limiter struct {
+	rate  int64
+	start time.Time
+	sent  int64
+}
+
+func newLimiter(rate int64) *limiter {
+	return &limiter{rate: rate, start: time.Now()}
+}
+
+// chunk returns size of read so throttled data flows in small steps, not in
+// bursts of whole buffer every few seconds
+func (l *limiter) chunk(max int) int {
+	size := int(l.rate / 10)
+	if size < 1 {
+		size = 1
+	}
+	if size > max {
+		size = max
+	}
+	return size
+}
+
+// wait sleeps until n more bytes fit into rate
+func (l *limiter) wait(n int) {
+	l.sent += int64(n)
+	due := l.start.Add(time.Duration(float64(l.sent) / float64(l.rate) * float64(time.Second)))
+	if delay := time.Until(due); delay > 0 {
+		time.Sleep(delay)
+	}
+}
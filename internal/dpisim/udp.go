@@ -0,0 +1,155 @@
+package dpisim
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// UDP sessions without datagrams for this long are forgotten
+const udpIdleTimeout = time.Minute
+
+// udpSession is exchange of client with server through simulator
+type udpSession struct {
+	client   *net.UDPAddr
+	rule     Rule
+	name     string
+	upstream *net.UDPConn
+	// ready is time client datagrams are forwarded from, for delay
+	ready time.Time
+
+	mu       sync.Mutex
+	received int64
+	dropped  bool
+}
+
+// blackholed reports whether session doesn't pass datagrams anymore
+func (u *udpSession) blackholed() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.dropped
+}
+
+// ServeUDP relays datagrams until connection is closed. QUIC has no RST, so
+// rst and stub act like drop for it.
+func (s *Simulator) ServeUDP(conn *net.UDPConn) error {
+	var mu sync.Mutex
+	sessions := make(map[string]*udpSession)
+	buffer := make([]byte, 64*1024)
+
+	for {
+		n, client, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			return err
+		}
+		data := append([]byte(nil), buffer[:n]...)
+
+		mu.Lock()
+		session, ok := sessions[client.String()]
+		if !ok {
+			session = s.newUDPSession(client, data)
+			sessions[client.String()] = session
+			go func() {
+				s.relayAnswers(conn, session)
+				mu.Lock()
+				delete(sessions, client.String())
+				mu.Unlock()
+			}()
+		}
+		mu.Unlock()
+
+		if session.blackholed() || session.upstream == nil {
+			continue
+		}
+		if delay := time.Until(session.ready); delay > 0 {
+			time.AfterFunc(delay, func() { session.upstream.Write(data) })
+			continue
+		}
+		session.upstream.Write(data)
+	}
+}
+
+// newUDPSession matches first datagram of client against rules and connects
+// to server unless datagrams are to be dropped
+func (s *Simulator) newUDPSession(client *net.UDPAddr, first []byte) *udpSession {
+	proto := ProtoAny
+	host, isQUIC := identifyQUIC(first)
+	if isQUIC {
+		proto = ProtoQUIC
+	}
+	session := &udpSession{client: client, name: describe(proto, host)}
+	session.rule = match(s.rules, proto, host)
+	s.logf("udp %s -> %s: %s", client, session.name, session.rule)
+
+	switch session.rule.Action {
+	case ActionRST, ActionDrop:
+		session.dropped = session.rule.After == 0
+	case ActionStub:
+		session.dropped = true
+	case ActionDelay:
+		session.ready = time.Now().Add(session.rule.Delay)
+	}
+	if session.dropped {
+		return session
+	}
+
+	destination := s.upstream
+	if destination == "" {
+		var err error
+		if destination, err = s.resolve(host, 443); err != nil {
+			s.logf("udp %s -> %s: %v", client, session.name, err)
+			return session
+		}
+	}
+	upstream, err := s.dialer.Dial("udp", destination)
+	if err != nil {
+		s.logf("udp %s -> %s: error connecting to %s: %v", client, session.name, destination, err)
+		return session
+	}
+	session.upstream = upstream.(*net.UDPConn)
+	return session
+}
+
+// relayAnswers forwards datagrams of server to client until session is idle
+func (s *Simulator) relayAnswers(conn *net.UDPConn, session *udpSession) {
+	if session.upstream == nil {
+		// Dropped session is forgotten after timeout, so rules are applied
+		// to client again
+		time.Sleep(udpIdleTimeout)
+		return
+	}
+	defer session.upstream.Close()
+
+	var limiter *limiter
+	if session.rule.Action == ActionThrottle {
+		limiter = newLimiter(session.rule.Rate)
+	}
+	buffer := make([]byte, 64*1024)
+	for {
+		session.upstream.SetReadDeadline(time.Now().Add(udpIdleTimeout))
+		n, err := session.upstream.Read(buffer)
+		if err != nil {
+			return
+		}
+		if session.blackholed() {
+			continue
+		}
+
+		session.mu.Lock()
+		session.received += int64(n)
+		if after := session.rule.After; after > 0 && session.received > after {
+			session.dropped = true
+			s.logf("udp %s -> %s: dropping after %d bytes", session.client, session.name, session.received-int64(n))
+		}
+		dropped := session.dropped
+		session.mu.Unlock()
+		if dropped {
+			continue
+		}
+
+		if limiter != nil {
+			limiter.wait(n)
+		}
+		conn.WriteToUDP(buffer[:n], session.client)
+	}
+}
@@ -0,0 +1,334 @@
+package fake
+
+import (
+	"crypto/aes"
+	"crypto/ecdh"
+	"crypto/mlkem"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Minimal size of UDP datagram with QUIC Initial, RFC 9000 14.1
+const MinQUICInitialSize = 1200
+
+// HelloSpec describes ClientHello to build
+type HelloSpec struct {
+	SNI  string
+	ALPN []string
+	// PostQuantum adds X25519MLKEM768 key share like current browsers do,
+	// Kyber adds older X25519Kyber768Draft00 instead
+	PostQuantum bool
+	Kyber       bool
+	// Size is target size of ClientHello with record header, reached with
+	// padding extension. 0 means no padding.
+	Size int
+	// QUIC builds ClientHello for QUIC: only TLS 1.3 and transport parameters
+	QUIC bool
+}
+
+// Extensions of Chrome ClientHello, values not used by builder itself are
+// written as captured from browser
+var (
+	signatureAlgorithms = []uint16{0x0403, 0x0804, 0x0401, 0x0503, 0x0805, 0x0501, 0x0806, 0x0601}
+	tlsCipherSuites     = []uint16{
+		0x1301, 0x1302, 0x1303, 0xc02b, 0xc02f, 0xc02c, 0xc030, 0xcca9,
+		0xcca8, 0xc013, 0xc014, 0x009c, 0x009d, 0x002f, 0x0035,
+	}
+	quicCipherSuites = []uint16{0x1301, 0x1302, 0x1303}
+)
+
+// grease returns random GREASE value of RFC 8701
+func grease() uint16 {
+	var b [1]byte
+	rand.Read(b[:])
+	v := uint16(b[0]&0xf0 | 0x0a)
+	return v<<8 | v
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	rand.Read(b)
+	return b
+}
+
+// builder appends big endian values to byte slice
+type builder []byte
+
+func (b *builder) uint8(v uint8)   { *b = append(*b, v) }
+func (b *builder) uint16(v uint16) { *b = binary.BigEndian.AppendUint16(*b, v) }
+func (b *builder) bytes(v []byte)  { *b = append(*b, v...) }
+
+func (b *builder) vector8(v []byte) {
+	b.uint8(uint8(len(v)))
+	b.bytes(v)
+}
+
+func (b *builder) vector16(v []byte) {
+	b.uint16(uint16(len(v)))
+	b.bytes(v)
+}
+
+func (b *builder) extension(typ uint16, data []byte) {
+	b.uint16(typ)
+	b.vector16(data)
+}
+
+func (b *builder) varint(v uint64) {
+	switch {
+	case v < 1<<6:
+		b.uint8(uint8(v))
+	case v < 1<<14:
+		b.uint16(uint16(v) | 0x4000)
+	default:
+		*b = binary.BigEndian.AppendUint32(*b, uint32(v)|0x80000000)
+	}
+}
+
+func uint16List(values ...uint16) []byte {
+	var b builder
+	for _, v := range values {
+		b.uint16(v)
+	}
+	return b
+}
+
+// keyShares returns key_share extension data and supported groups. Keys are
+// generated, so fake can't be told apart from real ClientHello by them.
+func keyShares(spec HelloSpec, greaseGroup uint16) ([]byte, []uint16, error) {
+	x25519, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	x25519Key := x25519.PublicKey().Bytes()
+
+	var shares builder
+	shares.uint16(greaseGroup)
+	shares.vector16([]byte{0})
+	groups := []uint16{greaseGroup}
+
+	if spec.PostQuantum || spec.Kyber {
+		mlkemKey, err := mlkem.GenerateKey768()
+		if err != nil {
+			return nil, nil, err
+		}
+		encapsulation := mlkemKey.EncapsulationKey().Bytes()
+		// X25519MLKEM768 puts ML-KEM key first, draft Kyber hybrid puts
+		// X25519 key first
+		if spec.PostQuantum {
+			shares.uint16(GroupX25519MLKEM768)
+			shares.vector16(append(encapsulation, x25519Key...))
+			groups = append(groups, GroupX25519MLKEM768)
+		} else {
+			shares.uint16(GroupX25519Kyber768Draft00)
+			shares.vector16(append(append([]byte{}, x25519Key...), encapsulation...))
+			groups = append(groups, GroupX25519Kyber768Draft00)
+		}
+	}
+	shares.uint16(29)
+	shares.vector16(x25519Key)
+	groups = append(groups, 29, 23, 24)
+
+	var data builder
+	data.vector16(shares)
+	return data, groups, nil
+}
+
+// quicTransportParameters returns parameters of Chrome: idle timeout, flow
+// control limits and initial source connection ID, which is empty
+func quicTransportParameters() []byte {
+	var b builder
+	param := func(id uint64, value uint64) {
+		var v builder
+		v.varint(value)
+		b.varint(id)
+		b.varint(uint64(len(v)))
+		b.bytes(v)
+	}
+	param(0x01, 30000)    // max_idle_timeout
+	param(0x04, 15728640) // initial_max_data
+	param(0x05, 6291456)  // initial_max_stream_data_bidi_local
+	param(0x06, 6291456)  // initial_max_stream_data_bidi_remote
+	param(0x07, 6291456)  // initial_max_stream_data_uni
+	param(0x08, 100)      // initial_max_streams_bidi
+	param(0x09, 103)      // initial_max_streams_uni
+	b.varint(0x0f)        // initial_source_connection_id
+	b.varint(0)
+	return b
+}
+
+// BuildClientHello returns ClientHello handshake message without record
+// header, shaped like ClientHello of Chrome
+func BuildClientHello(spec HelloSpec) ([]byte, error) {
+	if spec.SNI == "" {
+		return nil, errors.New("SNI is required")
+	}
+
+	var ext builder
+	ext.extension(grease(), nil)
+
+	var sni, names builder
+	names.uint8(0)
+	names.vector16([]byte(spec.SNI))
+	sni.vector16(names)
+	ext.extension(extServerName, sni)
+
+	ext.extension(23, nil)          // extended_master_secret
+	ext.extension(65281, []byte{0}) // renegotiation_info
+
+	shares, groups, err := keyShares(spec, grease())
+	if err != nil {
+		return nil, err
+	}
+	var supportedGroups builder
+	supportedGroups.vector16(uint16List(groups...))
+	ext.extension(extSupportedGroups, supportedGroups)
+	ext.extension(11, []byte{1, 0}) // ec_point_formats: uncompressed
+
+	if !spec.QUIC {
+		ext.extension(35, nil) // session_ticket
+	}
+	if len(spec.ALPN) > 0 {
+		var alpn, list builder
+		for _, protocol := range spec.ALPN {
+			if len(protocol) == 0 || len(protocol) > 255 {
+				return nil, fmt.Errorf("invalid ALPN protocol: %q", protocol)
+			}
+			list.vector8([]byte(protocol))
+		}
+		alpn.vector16(list)
+		ext.extension(extALPN, alpn)
+	}
+	ext.extension(5, []byte{1, 0, 0, 0, 0}) // status_request: OCSP
+
+	var signatures builder
+	signatures.vector16(uint16List(signatureAlgorithms...))
+	ext.extension(13, signatures)
+	ext.extension(18, nil) // signed_certificate_timestamp
+	ext.extension(extKeyShare, shares)
+	ext.extension(45, []byte{1, 1}) // psk_key_exchange_modes: psk_dhe_ke
+
+	versions := []uint16{grease(), 0x0304}
+	if !spec.QUIC {
+		versions = append(versions, 0x0303)
+	}
+	var supportedVersions builder
+	supportedVersions.vector8(uint16List(versions...))
+	ext.extension(extSupportedVersions, supportedVersions)
+	ext.extension(27, []byte{2, 0, 2}) // compress_certificate: brotli
+	if spec.QUIC {
+		ext.extension(57, quicTransportParameters())
+	}
+	ext.extension(grease(), []byte{0})
+
+	suites := tlsCipherSuites
+	sessionID := randomBytes(32)
+	if spec.QUIC {
+		// TLS 1.3 over QUIC has no middlebox compatibility mode
+		suites = quicCipherSuites
+		sessionID = nil
+	}
+
+	hello := func(ext []byte) []byte {
+		var body builder
+		body.uint16(0x0303)
+		body.bytes(randomBytes(32))
+		body.vector8(sessionID)
+		body.vector16(uint16List(append([]uint16{grease()}, suites...)...))
+		body.vector8([]byte{0})
+		body.vector16(ext)
+
+		message := builder{typeClientHello, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}
+		return append(message, body...)
+	}
+
+	message := hello(ext)
+	if spec.Size > 0 {
+		size := len(message)
+		if !spec.QUIC {
+			size += recordHeaderSz
+		}
+		// Padding extension takes 4 bytes even when empty
+		if padding := spec.Size - size - 4; padding >= 0 {
+			ext.extension(extPadding, make([]byte, padding))
+			message = hello(ext)
+		} else {
+			return nil, fmt.Errorf("ClientHello takes %d bytes, more than target size %d", size, spec.Size)
+		}
+	}
+	return message, nil
+}
+
+// TLSRecord wraps handshake message into TLS record. Record version is TLS
+// 1.0, as in ClientHello of browsers.
+func TLSRecord(message []byte) []byte {
+	record := builder{recordHandshake, 3, 1}
+	record.vector16(message)
+	return record
+}
+
+// BuildQUICInitial returns QUIC v1 Initial packet of given size carrying
+// ClientHello in CRYPTO frame, encrypted and with header protection as in
+// RFC 9001 5. Size below 1200 bytes is raised to it. ClientHello that
+// doesn't fit is an error: cut one would lose key share and other
+// extensions at its end.
+func BuildQUICInitial(hello []byte, size int) ([]byte, error) {
+	if size < MinQUICInitialSize {
+		size = MinQUICInitialSize
+	}
+	dcid := randomBytes(8)
+	keys, err := deriveInitialKeys(QUICv1, dcid)
+	if err != nil {
+		return nil, err
+	}
+
+	const pnLength = 1
+	var header builder
+	header.uint8(0xc0 | (pnLength - 1))
+	header = binary.BigEndian.AppendUint32(header, QUICv1)
+	header.vector8(dcid)
+	header.vector8(nil) // source connection ID
+	header.varint(0)    // token length
+	// Length is always written with 2 bytes, so header size is known
+	// before payload
+	lengthOffset := len(header)
+	header.uint16(0)
+	header.uint8(0) // packet number
+
+	overhead := 16 // AEAD tag
+	payloadSize := size - len(header) - overhead
+	if payloadSize >= 1<<14 {
+		return nil, fmt.Errorf("packet size %d is too large", size)
+	}
+
+	// CRYPTO frame header: type, offset 0 and 2 byte length
+	const cryptoHeader = 4
+	if len(hello) > payloadSize-cryptoHeader {
+		return nil, fmt.Errorf("ClientHello takes %d bytes and doesn't fit into packet of %d bytes, size of at least %d is needed",
+			len(hello), size, size-payloadSize+cryptoHeader+len(hello))
+	}
+	var payload builder
+	payload.uint8(frameCrypto)
+	payload.varint(0)
+	payload.uint16(uint16(len(hello)) | 0x4000)
+	payload.bytes(hello)
+	payload.bytes(make([]byte, payloadSize-len(payload)))
+
+	binary.BigEndian.PutUint16(header[lengthOffset:], uint16(pnLength+len(payload)+overhead)|0x4000)
+
+	aead, err := keys.aead()
+	if err != nil {
+		return nil, err
+	}
+	packet := aead.Seal(append([]byte{}, header...), keys.nonce(0), payload, header)
+
+	pnOffset := len(header) - pnLength
+	mask, err := keys.headerMask(packet[pnOffset+4 : pnOffset+4+aes.BlockSize])
+	if err != nil {
+		return nil, err
+	}
+	packet[0] ^= mask[0] & 0x0f
+	packet[pnOffset] ^= mask[1]
+	return packet, nil
+}
@@ -0,0 +1,161 @@
+package fake
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildClientHello(t *testing.T) {
+	tests := []struct {
+		name string
+		spec HelloSpec
+		// group is post-quantum key share expected, 0 for none
+		group uint16
+	}{
+		{name: "plain", spec: HelloSpec{SNI: "www.google.com", ALPN: []string{"h2", "http/1.1"}}},
+		{name: "padded", spec: HelloSpec{SNI: "ya.ru", Size: 652}},
+		{name: "ml-kem", spec: HelloSpec{SNI: "vk.com", PostQuantum: true}, group: GroupX25519MLKEM768},
+		{name: "kyber", spec: HelloSpec{SNI: "vk.com", Kyber: true}, group: GroupX25519Kyber768Draft00},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			message, err := BuildClientHello(test.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			record := TLSRecord(message)
+			if Detect(record) != KindTLS {
+				t.Fatalf("record is detected as %s", Detect(record))
+			}
+			hello, err := ParseClientHello(record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkHello(t, hello, test.spec, test.group)
+			if test.spec.Size > 0 && hello.Size != test.spec.Size {
+				t.Errorf("size = %d, want %d", hello.Size, test.spec.Size)
+			}
+			if hello.Truncated || hello.Trailing != 0 {
+				t.Errorf("truncated %v, trailing %d", hello.Truncated, hello.Trailing)
+			}
+		})
+	}
+
+	if _, err := BuildClientHello(HelloSpec{SNI: "ya.ru", Size: 100}); err == nil {
+		t.Error("ClientHello larger than target size is built")
+	}
+}
+
+func TestBuildQUICInitial(t *testing.T) {
+	tests := []struct {
+		name  string
+		spec  HelloSpec
+		size  int
+		group uint16
+		// err is part of expected error, empty if packet is built
+		err string
+	}{
+		{name: "minimal size", spec: HelloSpec{SNI: "www.google.com", ALPN: []string{"h3"}}, size: 0},
+		{name: "larger", spec: HelloSpec{SNI: "www.google.com", ALPN: []string{"h3"}}, size: 1350},
+		{name: "ml-kem", spec: HelloSpec{SNI: "vk.com", ALPN: []string{"h3"}, PostQuantum: true}, size: 1600, group: GroupX25519MLKEM768},
+		{name: "kyber", spec: HelloSpec{SNI: "vk.com", ALPN: []string{"h3"}, Kyber: true}, size: 1600, group: GroupX25519Kyber768Draft00},
+		// Cut ClientHello would lose key share, so it isn't built
+		{name: "ml-kem doesn't fit", spec: HelloSpec{SNI: "vk.com", ALPN: []string{"h3"}, PostQuantum: true}, err: "doesn't fit"},
+		{name: "kyber doesn't fit", spec: HelloSpec{SNI: "vk.com", ALPN: []string{"h3"}, Kyber: true}, size: 1300, err: "doesn't fit"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.spec.QUIC = true
+			message, err := BuildClientHello(test.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			packet, err := BuildQUICInitial(message, test.size)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := max(test.size, MinQUICInitialSize); len(packet) != want {
+				t.Errorf("packet takes %d bytes, want %d", len(packet), want)
+			}
+			if Detect(packet) != KindQUIC {
+				t.Fatalf("packet is detected as %s", Detect(packet))
+			}
+
+			initial, err := ParseQUICInitial(packet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if initial.Version != QUICv1 || initial.Size != len(packet) || initial.Trailing != 0 {
+				t.Errorf("version %x, size %d, trailing %d", initial.Version, initial.Size, initial.Trailing)
+			}
+			if initial.CryptoOffset != 0 || initial.Frames["CRYPTO"] != 1 {
+				t.Errorf("CRYPTO offset %d, frames %v", initial.CryptoOffset, initial.Frames)
+			}
+			if initial.ClientHello == nil {
+				t.Fatal("ClientHello isn't decoded")
+			}
+			checkHello(t, initial.ClientHello, test.spec, test.group)
+			if initial.ClientHello.Truncated {
+				t.Error("ClientHello is truncated")
+			}
+			if _, ok := initial.ClientHello.Extension(57); !ok {
+				t.Error("quic_transport_parameters is missing")
+			}
+		})
+	}
+}
+
+// checkHello compares decoded ClientHello with spec it was built from
+func checkHello(t *testing.T, hello *ClientHello, spec HelloSpec, group uint16) {
+	t.Helper()
+	if !reflect.DeepEqual(hello.SNI, []string{spec.SNI}) {
+		t.Errorf("SNI = %v, want %s", hello.SNI, spec.SNI)
+	}
+	if len(spec.ALPN) > 0 && !reflect.DeepEqual(hello.ALPN, spec.ALPN) {
+		t.Errorf("ALPN = %v, want %v", hello.ALPN, spec.ALPN)
+	}
+	if !contains(hello.SupportedVersions, 0x0304) {
+		t.Errorf("supported versions %x don't include TLS 1.3", hello.SupportedVersions)
+	}
+	if contains(hello.SupportedVersions, 0x0303) == spec.QUIC {
+		t.Errorf("supported versions %x, QUIC %v", hello.SupportedVersions, spec.QUIC)
+	}
+
+	var groups []uint16
+	for _, share := range hello.KeyShares {
+		if !IsGREASE(share.Group) {
+			groups = append(groups, share.Group)
+		}
+	}
+	want := []uint16{29}
+	if group != 0 {
+		want = []uint16{group, 29}
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("key shares = %x, want %x", groups, want)
+	}
+	if hello.HasPostQuantum() != (group != 0) {
+		t.Errorf("HasPostQuantum = %v", hello.HasPostQuantum())
+	}
+	for _, g := range want {
+		if !contains(hello.SupportedGroups, g) {
+			t.Errorf("supported groups %x don't include %x", hello.SupportedGroups, g)
+		}
+	}
+}
+
+func contains(values []uint16, value uint16) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
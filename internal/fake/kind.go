@@ -0,0 +1,73 @@
+package fake
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Kind is type of fake payload
+type Kind string
+
+const (
+	KindTLS     Kind = "TLS ClientHello"
+	KindQUIC    Kind = "QUIC Initial"
+	KindQUICAny Kind = "QUIC packet"
+	KindDTLS    Kind = "DTLS ClientHello"
+	KindHTTP    Kind = "HTTP request"
+	KindWG      Kind = "WireGuard message"
+	KindDHT     Kind = "BitTorrent DHT message"
+	KindZero    Kind = "zeros"
+	KindUnknown Kind = "unknown"
+)
+
+var httpMethods = [][]byte{
+	[]byte("GET "), []byte("POST "), []byte("HEAD "), []byte("PUT "),
+	[]byte("OPTIONS "), []byte("DELETE "), []byte("CONNECT "), []byte("PATCH "),
+}
+
+// Sizes of WireGuard handshake initiation, response and cookie reply
+var wireGuardSizes = map[byte]int{1: 148, 2: 92, 3: 64}
+
+// Detect guesses type of fake payload by its first bytes
+func Detect(data []byte) Kind {
+	switch {
+	case IsTLSClientHello(data):
+		return KindTLS
+	case len(data) > 13 && data[0] == recordHandshake && data[1] == 0xfe && data[13] == typeClientHello:
+		return KindDTLS
+	case IsQUICInitial(data):
+		return KindQUIC
+	case len(data) >= 5 && data[0]&0xc0 == 0xc0 && binary.BigEndian.Uint32(data[1:5]) != 0:
+		// Long header of other packet type or unknown version
+		return KindQUICAny
+	case len(data) > 0 && len(bytes.Trim(data, "\x00")) == 0:
+		return KindZero
+	case isWireGuard(data):
+		return KindWG
+	case bytes.HasPrefix(data, []byte("d1:")) && bytes.HasSuffix(data, []byte("e")):
+		// Bencoded dictionary of KRPC message
+		return KindDHT
+	}
+	for _, method := range httpMethods {
+		if bytes.HasPrefix(data, method) {
+			return KindHTTP
+		}
+	}
+	// Short header has fixed bit set and no version, so it's recognized
+	// only after everything else
+	if len(data) >= 21 && data[0]&0xc0 == 0x40 {
+		return KindQUICAny
+	}
+	return KindUnknown
+}
+
+func isWireGuard(data []byte) bool {
+	if len(data) < 32 || !bytes.Equal(data[1:4], []byte{0, 0, 0}) {
+		return false
+	}
+	if size, ok := wireGuardSizes[data[0]]; ok {
+		return len(data) == size
+	}
+	// Transport data is padded to 16 bytes
+	return data[0] == 4 && len(data)%16 == 0
+}
@@ -0,0 +1,86 @@
+package fake
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestDetectShipped classifies payloads shipped in bin folder. New payload
+// must be added here with its kind.
+func TestDetectShipped(t *testing.T) {
+	kinds := map[string]Kind{
+		"quic_initial_www_google_com.bin":    KindQUIC,
+		"tls_clienthello_www_google_com.bin": KindTLS,
+	}
+
+	files, err := filepath.Glob(filepath.Join("..", "..", "bin", "*.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != len(kinds) {
+		t.Errorf("found %d payloads in bin, want %d", len(files), len(kinds))
+	}
+	for _, file := range files {
+		want, ok := kinds[filepath.Base(file)]
+		if !ok {
+			t.Errorf("%s isn't known to test", filepath.Base(file))
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kind := Detect(data); kind != want {
+			t.Errorf("%s is detected as %s, want %s", filepath.Base(file), kind, want)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	hello, err := BuildClientHello(HelloSpec{SNI: "example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	quicHello, err := BuildClientHello(HelloSpec{SNI: "example.com", QUIC: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	initial, err := BuildQUICInitial(quicHello, MinQUICInitialSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Handshake packet of QUIC v1 is long header packet, but not Initial
+	handshake := append([]byte{0xe0, 0, 0, 0, 1}, make([]byte, 40)...)
+	dtls := append([]byte{recordHandshake, 0xfe, 0xfd}, make([]byte, 10)...)
+	dtls = append(dtls, typeClientHello, 0, 0, 0)
+	wireGuard := func(typ byte, size int) []byte {
+		return append([]byte{typ, 0, 0, 0}, bytes.Repeat([]byte{0xaa}, size-4)...)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want Kind
+	}{
+		{"TLS", TLSRecord(hello), KindTLS},
+		{"QUIC Initial", initial, KindQUIC},
+		{"QUIC Handshake", handshake, KindQUICAny},
+		{"QUIC short header", append([]byte{0x43}, bytes.Repeat([]byte{0x55}, 30)...), KindQUICAny},
+		{"DTLS", dtls, KindDTLS},
+		{"HTTP", []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"), KindHTTP},
+		{"WireGuard initiation", wireGuard(1, 148), KindWG},
+		{"WireGuard data", wireGuard(4, 64), KindWG},
+		{"WireGuard of wrong size", wireGuard(1, 100), KindUnknown},
+		{"DHT", []byte("d1:ad2:id20:abcdefghij0123456789e1:q4:ping1:t2:aa1:y1:qe"), KindDHT},
+		{"zeros", make([]byte, 64), KindZero},
+		{"empty", nil, KindUnknown},
+		{"text", []byte("hello, world"), KindUnknown},
+	}
+	for _, test := range tests {
+		if kind := Detect(test.data); kind != test.want {
+			t.Errorf("%s is detected as %s, want %s", test.name, kind, test.want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package fake
+
+import "fmt"
+
+// Named groups of post-quantum hybrid key exchange
+const (
+	GroupX25519Kyber768Draft00 uint16 = 0x6399
+	GroupSecP256r1MLKEM768     uint16 = 0x11eb
+	GroupX25519MLKEM768        uint16 = 0x11ec
+	GroupSecP384r1MLKEM1024    uint16 = 0x11ed
+)
+
+// Names from IANA TLS registries for values browsers send
+var cipherSuiteNames = map[uint16]string{
+	0x1301: "TLS_AES_128_GCM_SHA256",
+	0x1302: "TLS_AES_256_GCM_SHA384",
+	0x1303: "TLS_CHACHA20_POLY1305_SHA256",
+	0xc02b: "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+	0xc02f: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+	0xc02c: "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+	0xc030: "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+	0xcca9: "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256",
+	0xcca8: "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256",
+	0xc009: "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA",
+	0xc013: "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA",
+	0xc00a: "TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA",
+	0xc014: "TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA",
+	0x009c: "TLS_RSA_WITH_AES_128_GCM_SHA256",
+	0x009d: "TLS_RSA_WITH_AES_256_GCM_SHA384",
+	0x002f: "TLS_RSA_WITH_AES_128_CBC_SHA",
+	0x0035: "TLS_RSA_WITH_AES_256_CBC_SHA",
+	0x000a: "TLS_RSA_WITH_3DES_EDE_CBC_SHA",
+	0x00ff: "TLS_EMPTY_RENEGOTIATION_INFO_SCSV",
+}
+
+var extensionNames = map[uint16]string{
+	0:     "server_name",
+	5:     "status_request",
+	10:    "supported_groups",
+	11:    "ec_point_formats",
+	13:    "signature_algorithms",
+	16:    "application_layer_protocol_negotiation",
+	18:    "signed_certificate_timestamp",
+	21:    "padding",
+	22:    "encrypt_then_mac",
+	23:    "extended_master_secret",
+	27:    "compress_certificate",
+	28:    "record_size_limit",
+	34:    "delegated_credentials",
+	35:    "session_ticket",
+	41:    "pre_shared_key",
+	42:    "early_data",
+	43:    "supported_versions",
+	45:    "psk_key_exchange_modes",
+	49:    "post_handshake_auth",
+	50:    "signature_algorithms_cert",
+	51:    "key_share",
+	57:    "quic_transport_parameters",
+	17513: "application_settings",
+	17613: "application_settings",
+	65037: "encrypted_client_hello",
+	65281: "renegotiation_info",
+}
+
+var groupNames = map[uint16]string{
+	23:                         "secp256r1",
+	24:                         "secp384r1",
+	25:                         "secp521r1",
+	29:                         "x25519",
+	30:                         "x448",
+	256:                        "ffdhe2048",
+	257:                        "ffdhe3072",
+	258:                        "ffdhe4096",
+	259:                        "ffdhe6144",
+	260:                        "ffdhe8192",
+	GroupX25519Kyber768Draft00: "X25519Kyber768Draft00",
+	GroupSecP256r1MLKEM768:     "SecP256r1MLKEM768",
+	GroupX25519MLKEM768:        "X25519MLKEM768",
+	GroupSecP384r1MLKEM1024:    "SecP384r1MLKEM1024",
+}
+
+var versionNames = map[uint16]string{
+	0x0300: "SSL 3.0",
+	0x0301: "TLS 1.0",
+	0x0302: "TLS 1.1",
+	0x0303: "TLS 1.2",
+	0x0304: "TLS 1.3",
+}
+
+// IsGREASE reports whether value is reserved GREASE value of RFC 8701, which
+// browsers add to lists to keep servers tolerant to unknown values
+func IsGREASE(value uint16) bool {
+	return value&0x0f0f == 0x0a0a && value>>8 == value&0xff
+}
+
+// IsPostQuantum reports whether group is Kyber or ML-KEM hybrid
+func IsPostQuantum(group uint16) bool {
+	switch group {
+	case GroupX25519Kyber768Draft00, GroupSecP256r1MLKEM768, GroupX25519MLKEM768, GroupSecP384r1MLKEM1024:
+		return true
+	}
+	return false
+}
+
+func lookup(names map[uint16]string, value uint16) string {
+	if IsGREASE(value) {
+		return fmt.Sprintf("GREASE (0x%04x)", value)
+	}
+	if name, ok := names[value]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown (0x%04x)", value)
+}
+
+// CipherSuiteName returns IANA name of cipher suite
+func CipherSuiteName(suite uint16) string {
+	return lookup(cipherSuiteNames, suite)
+}
+
+// ExtensionName returns IANA name of extension type
+func ExtensionName(typ uint16) string {
+	return lookup(extensionNames, typ)
+}
+
+// GroupName returns IANA name of named group
+func GroupName(group uint16) string {
+	return lookup(groupNames, group)
+}
+
+// VersionName returns name of TLS version
+func VersionName(version uint16) string {
+	return lookup(versionNames, version)
+}
+
+// QUICVersionName returns name of QUIC version
+func QUICVersionName(version uint32) string {
+	switch version {
+	case QUICv1:
+		return "QUIC v1"
+	case QUICv2:
+		return "QUIC v2"
+	case QUICDraft29:
+		return "QUIC draft-29"
+	default:
+		return fmt.Sprintf("unknown (0x%08x)", version)
+	}
+}
@@ -0,0 +1,307 @@
+package fake
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// QUIC versions with known initial salt
+const (
+	QUICv1      uint32 = 0x00000001
+	QUICv2      uint32 = 0x6b3343cf
+	QUICDraft29 uint32 = 0xff00001d
+)
+
+// Initial salts from RFC 9001 5.2, RFC 9369 3.3.1 and draft-ietf-quic-tls-29
+var initialSalts = map[uint32][]byte{
+	QUICv1:      {0x38, 0x76, 0x2c, 0xf7, 0xf5, 0x59, 0x34, 0xb3, 0x4d, 0x17, 0x9a, 0xe6, 0xa4, 0xc8, 0x0c, 0xad, 0xcc, 0xbb, 0x7f, 0x0a},
+	QUICv2:      {0x0d, 0xed, 0xe3, 0xde, 0xf7, 0x00, 0xa6, 0xdb, 0x81, 0x93, 0x81, 0xbe, 0x6e, 0x26, 0x9d, 0xcb, 0xf9, 0xbd, 0x2e, 0xd9},
+	QUICDraft29: {0xaf, 0xbf, 0xec, 0x28, 0x99, 0x93, 0xd2, 0x4d, 0x9e, 0x97, 0x86, 0xf1, 0x9c, 0x61, 0x11, 0xe0, 0x43, 0x90, 0xa8, 0x99},
+}
+
+// QUIC frame types found in Initial packets
+const (
+	framePadding = 0x00
+	framePing    = 0x01
+	frameAck     = 0x02
+	frameAckECN  = 0x03
+	frameCrypto  = 0x06
+	frameClose   = 0x1c
+)
+
+// QUICInitial is decrypted QUIC Initial packet
+type QUICInitial struct {
+	Version      uint32
+	DCID         []byte
+	SCID         []byte
+	Token        []byte
+	PacketNumber uint64
+	// Size is number of bytes packet takes, Trailing is number of bytes
+	// after it, usually padding or coalesced packets
+	Size     int
+	Trailing int
+	// Frames holds number of frames of every type
+	Frames map[string]int
+	// Crypto is reassembled data of CRYPTO frames
+	Crypto []byte
+	// CryptoOffset is offset of Crypto in handshake stream, not 0 when
+	// packet carries continuation of ClientHello
+	CryptoOffset uint64
+	// ClientHello is set when CRYPTO frames start with ClientHello
+	ClientHello *ClientHello
+}
+
+// IsQUICInitial reports whether data looks like QUIC long header Initial
+// packet of known version
+func IsQUICInitial(data []byte) bool {
+	if len(data) < 7 || data[0]&0xc0 != 0xc0 {
+		return false
+	}
+	version := binary.BigEndian.Uint32(data[1:5])
+	_, known := initialSalts[version]
+	return known && packetType(data[0], version) == 0
+}
+
+// packetType returns long header packet type, Initial is 0. QUIC v2 changed
+// packet type numbers.
+func packetType(first byte, version uint32) int {
+	typ := int(first>>4) & 3
+	if version == QUICv2 {
+		typ = (typ + 3) % 4
+	}
+	return typ
+}
+
+// initialKeys are client keys of Initial packets
+type initialKeys struct {
+	key, iv, hp []byte
+}
+
+// deriveInitialKeys derives client Initial keys from destination connection
+// ID as described in RFC 9001 5.2
+func deriveInitialKeys(version uint32, dcid []byte) (initialKeys, error) {
+	salt, ok := initialSalts[version]
+	if !ok {
+		return initialKeys{}, fmt.Errorf("unknown QUIC version 0x%08x", version)
+	}
+	prefix := "quic "
+	if version == QUICv2 {
+		prefix = "quicv2 "
+	}
+
+	initial := hkdfExtract(salt, dcid)
+	client := hkdfExpandLabel(initial, "client in", 32)
+	return initialKeys{
+		key: hkdfExpandLabel(client, prefix+"key", 16),
+		iv:  hkdfExpandLabel(client, prefix+"iv", 12),
+		hp:  hkdfExpandLabel(client, prefix+"hp", 16),
+	}, nil
+}
+
+func hkdfExtract(salt, secret []byte) []byte {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write(secret)
+	return mac.Sum(nil)
+}
+
+// hkdfExpandLabel is HKDF-Expand-Label of TLS 1.3 with empty context
+func hkdfExpandLabel(secret []byte, label string, length int) []byte {
+	label = "tls13 " + label
+	info := []byte{byte(length >> 8), byte(length), byte(len(label))}
+	info = append(info, label...)
+	info = append(info, 0)
+
+	var out, block []byte
+	for counter := byte(1); len(out) < length; counter++ {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(block)
+		mac.Write(info)
+		mac.Write([]byte{counter})
+		block = mac.Sum(nil)
+		out = append(out, block...)
+	}
+	return out[:length]
+}
+
+func (k initialKeys) headerMask(sample []byte) ([]byte, error) {
+	block, err := aes.NewCipher(k.hp)
+	if err != nil {
+		return nil, err
+	}
+	mask := make([]byte, aes.BlockSize)
+	block.Encrypt(mask, sample)
+	return mask, nil
+}
+
+func (k initialKeys) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(k.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (k initialKeys) nonce(pn uint64) []byte {
+	nonce := make([]byte, len(k.iv))
+	copy(nonce, k.iv)
+	for i := 0; i < 8; i++ {
+		nonce[len(nonce)-1-i] ^= byte(pn >> (8 * i))
+	}
+	return nonce
+}
+
+// ParseQUICInitial removes header protection, decrypts QUIC Initial packet
+// and decodes ClientHello in its CRYPTO frames
+func ParseQUICInitial(data []byte) (*QUICInitial, error) {
+	if len(data) < 7 || data[0]&0x80 == 0 {
+		return nil, errors.New("not QUIC long header packet")
+	}
+	r := reader(data[1:])
+	version, _ := r.uint32()
+	if _, ok := initialSalts[version]; !ok {
+		return nil, fmt.Errorf("unknown QUIC version 0x%08x", version)
+	}
+	if packetType(data[0], version) != 0 {
+		return nil, errors.New("not QUIC Initial packet")
+	}
+
+	packet := &QUICInitial{Version: version, Frames: make(map[string]int)}
+	var ok bool
+	if packet.DCID, ok = r.vector8(); !ok {
+		return nil, errShort
+	}
+	if packet.SCID, ok = r.vector8(); !ok {
+		return nil, errShort
+	}
+	tokenLength, ok := r.varint()
+	if !ok {
+		return nil, errShort
+	}
+	if packet.Token, ok = r.bytes(int(tokenLength)); !ok {
+		return nil, errShort
+	}
+	length, ok := r.varint()
+	if !ok {
+		return nil, errShort
+	}
+	pnOffset := len(data) - len(r)
+	end := pnOffset + int(length)
+	if end > len(data) || length < 20 {
+		return nil, fmt.Errorf("packet length %d exceeds data", length)
+	}
+	packet.Size = end
+	packet.Trailing = len(data) - end
+
+	keys, err := deriveInitialKeys(version, packet.DCID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sample starts 4 bytes after start of packet number, RFC 9001 5.4.2
+	header := make([]byte, pnOffset+4)
+	copy(header, data)
+	mask, err := keys.headerMask(data[pnOffset+4 : pnOffset+4+aes.BlockSize])
+	if err != nil {
+		return nil, err
+	}
+	header[0] ^= mask[0] & 0x0f
+	pnLength := int(header[0]&3) + 1
+	header = header[:pnOffset+pnLength]
+	for i := 0; i < pnLength; i++ {
+		header[pnOffset+i] ^= mask[1+i]
+		packet.PacketNumber = packet.PacketNumber<<8 | uint64(header[pnOffset+i])
+	}
+
+	aead, err := keys.aead()
+	if err != nil {
+		return nil, err
+	}
+	payload, err := aead.Open(nil, keys.nonce(packet.PacketNumber), data[pnOffset+pnLength:end], header)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting packet: %v", err)
+	}
+
+	if err := packet.parseFrames(payload); err != nil {
+		return nil, err
+	}
+	if len(packet.Crypto) > 0 && packet.CryptoOffset == 0 {
+		if packet.ClientHello, err = ParseHandshake(packet.Crypto); err != nil {
+			return nil, fmt.Errorf("invalid ClientHello in CRYPTO frames: %v", err)
+		}
+	}
+	return packet, nil
+}
+
+type cryptoFrame struct {
+	offset uint64
+	data   []byte
+}
+
+func (p *QUICInitial) parseFrames(payload []byte) error {
+	var frames []cryptoFrame
+	r := reader(payload)
+	for len(r) > 0 {
+		typ, _ := r.varint()
+		switch typ {
+		case framePadding:
+			p.Frames["PADDING"]++
+		case framePing:
+			p.Frames["PING"]++
+		case frameAck, frameAckECN:
+			p.Frames["ACK"]++
+			// Largest acknowledged, delay, range count and first range
+			var values [4]uint64
+			for i := range values {
+				v, ok := r.varint()
+				if !ok {
+					return errShort
+				}
+				values[i] = v
+			}
+			skip := 2 * values[2]
+			if typ == frameAckECN {
+				skip += 3
+			}
+			for ; skip > 0; skip-- {
+				if _, ok := r.varint(); !ok {
+					return errShort
+				}
+			}
+		case frameCrypto:
+			p.Frames["CRYPTO"]++
+			offset, ok1 := r.varint()
+			length, ok2 := r.varint()
+			data, ok3 := r.bytes(int(length))
+			if !ok1 || !ok2 || !ok3 {
+				return errShort
+			}
+			frames = append(frames, cryptoFrame{offset, data})
+		case frameClose:
+			p.Frames["CONNECTION_CLOSE"]++
+			r = nil
+		default:
+			return fmt.Errorf("unexpected frame type 0x%x in Initial packet", typ)
+		}
+	}
+
+	// Browsers shuffle CRYPTO frames, so data is reassembled by offsets
+	if len(frames) == 0 {
+		return nil
+	}
+	sort.Slice(frames, func(i, j int) bool { return frames[i].offset < frames[j].offset })
+	p.CryptoOffset = frames[0].offset
+	for _, f := range frames {
+		end := int(f.offset-p.CryptoOffset) + len(f.data)
+		if end > len(p.Crypto) {
+			p.Crypto = append(p.Crypto, make([]byte, end-len(p.Crypto))...)
+		}
+		copy(p.Crypto[f.offset-p.CryptoOffset:], f.data)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package fake
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// Connection ID of client Initial in test vectors of RFC 9001 Appendix A
+const vectorDCID = "8394c8f03e515708"
+
+func TestDeriveInitialKeys(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     uint32
+		key, iv, hp string
+	}{
+		// RFC 9001 A.1
+		{"v1", QUICv1, "1f369613dd76d5467730efcbe3b1a22d", "fa044b2f42a3fd3b46fb255c", "9f50449e04a0e810283a1e9933adedd2"},
+		// RFC 9369 A.1
+		{"v2", QUICv2, "8b1a0bc121284290a29e0971b5cd045d", "91f73e2351d8fa91660e909f", "45b95e15235d6f45a6b19cbcb0294ba9"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keys, err := deriveInitialKeys(test.version, unhex(t, vectorDCID))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(keys.key); got != test.key {
+				t.Errorf("key = %s, want %s", got, test.key)
+			}
+			if got := hex.EncodeToString(keys.iv); got != test.iv {
+				t.Errorf("iv = %s, want %s", got, test.iv)
+			}
+			if got := hex.EncodeToString(keys.hp); got != test.hp {
+				t.Errorf("hp = %s, want %s", got, test.hp)
+			}
+		})
+	}
+}
+
+// TestHeaderProtection protects header of client Initial of RFC 9001 A.2
+// with its sample
+func TestHeaderProtection(t *testing.T) {
+	keys, err := deriveInitialKeys(QUICv1, unhex(t, vectorDCID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mask, err := keys.headerMask(unhex(t, "d1b1c98dd7689fb8ec11d242b123dc9b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(mask[:5]); got != "437b9aec36" {
+		t.Fatalf("mask = %s, want 437b9aec36", got)
+	}
+
+	header := unhex(t, "c300000001088394c8f03e5157080000449e00000002")
+	header[0] ^= mask[0] & 0x0f
+	for i := 0; i < 4; i++ {
+		header[len(header)-4+i] ^= mask[1+i]
+	}
+	if got := hex.EncodeToString(header); got != "c000000001088394c8f03e5157080000449e7b9aec34" {
+		t.Errorf("protected header = %s", got)
+	}
+	if got := hex.EncodeToString(keys.nonce(2)); got != "fa044b2f42a3fd3b46fb255e" {
+		t.Errorf("nonce of packet 2 = %s", got)
+	}
+}
+
+// TestParseQUICInitial decodes packet protected like client Initial of RFC
+// 9001 A.2: 4 byte packet number 2 and CRYPTO frame followed by padding
+func TestParseQUICInitial(t *testing.T) {
+	hello, err := BuildClientHello(HelloSpec{SNI: "example.com", ALPN: []string{"h3"}, QUIC: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := deriveInitialKeys(QUICv1, unhex(t, vectorDCID))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := unhex(t, "c300000001088394c8f03e5157080000449e00000002")
+	var payload builder
+	payload.uint8(frameCrypto)
+	payload.varint(0)
+	payload.uint16(uint16(len(hello)) | 0x4000)
+	payload.bytes(hello)
+	// Length of 0x049e covers packet number, payload and AEAD tag
+	payload.bytes(make([]byte, 0x049e-4-16-len(payload)))
+
+	aead, err := keys.aead()
+	if err != nil {
+		t.Fatal(err)
+	}
+	packet := aead.Seal(append([]byte{}, header...), keys.nonce(2), payload, header)
+	if len(packet) != MinQUICInitialSize {
+		t.Fatalf("packet takes %d bytes, want %d", len(packet), MinQUICInitialSize)
+	}
+	pnOffset := len(header) - 4
+	mask, err := keys.headerMask(packet[pnOffset+4 : pnOffset+4+16])
+	if err != nil {
+		t.Fatal(err)
+	}
+	packet[0] ^= mask[0] & 0x0f
+	for i := 0; i < 4; i++ {
+		packet[pnOffset+i] ^= mask[1+i]
+	}
+
+	if !IsQUICInitial(packet) || Detect(packet) != KindQUIC {
+		t.Fatalf("packet isn't recognized as QUIC Initial")
+	}
+	initial, err := ParseQUICInitial(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if initial.PacketNumber != 2 || !bytes.Equal(initial.DCID, unhex(t, vectorDCID)) || initial.Size != len(packet) {
+		t.Errorf("packet number %d, DCID %x, size %d", initial.PacketNumber, initial.DCID, initial.Size)
+	}
+	if initial.Frames["CRYPTO"] != 1 || initial.Frames["PADDING"] == 0 {
+		t.Errorf("frames = %v", initial.Frames)
+	}
+	if !bytes.Equal(initial.Crypto, hello) {
+		t.Errorf("CRYPTO data differs from ClientHello")
+	}
+	if initial.ClientHello == nil || len(initial.ClientHello.SNI) != 1 || initial.ClientHello.SNI[0] != "example.com" {
+		t.Errorf("ClientHello = %+v", initial.ClientHello)
+	}
+
+	// Changed ciphertext doesn't decrypt
+	packet[len(packet)-1] ^= 1
+	if _, err := ParseQUICInitial(packet); err == nil {
+		t.Error("packet with broken tag is decoded")
+	}
+	binary.BigEndian.PutUint32(packet[1:], 0x0a0a0a0a)
+	if IsQUICInitial(packet) {
+		t.Error("packet of unknown version is recognized as Initial")
+	}
+}
@@ -0,0 +1,348 @@
+// Package fake decodes and builds fake payloads winws sends to DPI before
+// real data: TLS ClientHello and QUIC Initial packets.
+package fake
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// TLS extension types used by ClientHello
+const (
+	extServerName        uint16 = 0
+	extSupportedGroups   uint16 = 10
+	extALPN              uint16 = 16
+	extPadding           uint16 = 21
+	extSupportedVersions uint16 = 43
+	extKeyShare          uint16 = 51
+)
+
+const (
+	recordHandshake   = 0x16
+	typeClientHello   = 0x01
+	handshakeHeaderSz = 4
+	recordHeaderSz    = 5
+)
+
+var errShort = errors.New("unexpected end of data")
+
+// Extension is raw TLS extension
+type Extension struct {
+	Type uint16
+	Data []byte
+}
+
+// KeyShare is key exchange offered for named group
+type KeyShare struct {
+	Group uint16
+	// Length is size of public key, ML-KEM and Kyber keys are over 1 KB
+	Length int
+}
+
+// ClientHello is decoded TLS ClientHello
+type ClientHello struct {
+	// RecordVersion is version in TLS record header, 0 when ClientHello
+	// isn't wrapped in record, like in QUIC CRYPTO frames
+	RecordVersion uint16
+	Version       uint16
+	Random        []byte
+	SessionID     []byte
+	CipherSuites  []uint16
+	Compression   []byte
+	Extensions    []Extension
+
+	// Values of well known extensions
+	SNI               []string
+	ALPN              []string
+	SupportedGroups   []uint16
+	SupportedVersions []uint16
+	KeyShares         []KeyShare
+	Padding           int
+
+	// Size is number of bytes ClientHello takes with record header
+	Size int
+	// Trailing is number of bytes after ClientHello
+	Trailing int
+	// Truncated is set when data ends before end of ClientHello, for
+	// example when it's split across several QUIC packets
+	Truncated bool
+}
+
+// HasPostQuantum reports whether ClientHello offers Kyber or ML-KEM key share
+func (h *ClientHello) HasPostQuantum() bool {
+	for _, share := range h.KeyShares {
+		if IsPostQuantum(share.Group) {
+			return true
+		}
+	}
+	return false
+}
+
+// Extension returns data of extension with given type
+func (h *ClientHello) Extension(typ uint16) ([]byte, bool) {
+	for _, ext := range h.Extensions {
+		if ext.Type == typ {
+			return ext.Data, true
+		}
+	}
+	return nil, false
+}
+
+// IsTLSClientHello reports whether data starts with TLS record of ClientHello
+func IsTLSClientHello(data []byte) bool {
+	return len(data) > recordHeaderSz && data[0] == recordHandshake && data[1] == 3 && data[5] == typeClientHello
+}
+
+// ParseClientHello decodes ClientHello in TLS record
+func ParseClientHello(data []byte) (*ClientHello, error) {
+	if len(data) < recordHeaderSz {
+		return nil, errShort
+	}
+	if data[0] != recordHandshake {
+		return nil, fmt.Errorf("not TLS handshake record, content type %d", data[0])
+	}
+	length := int(binary.BigEndian.Uint16(data[3:5]))
+	record := data[recordHeaderSz:]
+	trailing := 0
+	if len(record) > length {
+		trailing = len(record) - length
+		record = record[:length]
+	}
+
+	hello, err := ParseHandshake(record)
+	if err != nil {
+		return nil, err
+	}
+	hello.RecordVersion = binary.BigEndian.Uint16(data[1:3])
+	hello.Size += recordHeaderSz
+	hello.Trailing += trailing
+	hello.Truncated = hello.Truncated || len(data)-recordHeaderSz < length
+	return hello, nil
+}
+
+// ParseHandshake decodes ClientHello handshake message without record header
+func ParseHandshake(data []byte) (*ClientHello, error) {
+	r := reader(data)
+	typ, ok := r.uint8()
+	if !ok {
+		return nil, errShort
+	}
+	if typ != typeClientHello {
+		return nil, fmt.Errorf("not ClientHello, handshake type %d", typ)
+	}
+	length, ok := r.uint24()
+	if !ok {
+		return nil, errShort
+	}
+
+	hello := &ClientHello{Size: handshakeHeaderSz + length}
+	body := r.rest()
+	if len(body) > length {
+		hello.Trailing = len(body) - length
+		body = body[:length]
+	} else if len(body) < length {
+		hello.Truncated = true
+	}
+
+	r = reader(body)
+	var version uint16
+	if version, ok = r.uint16(); !ok {
+		return nil, errShort
+	}
+	hello.Version = version
+	if hello.Random, ok = r.bytes(32); !ok {
+		return nil, errShort
+	}
+	if hello.SessionID, ok = r.vector8(); !ok {
+		return nil, errShort
+	}
+	suites, ok := r.vector16()
+	if !ok {
+		return nil, errShort
+	}
+	for s := reader(suites); len(s) >= 2; {
+		suite, _ := s.uint16()
+		hello.CipherSuites = append(hello.CipherSuites, suite)
+	}
+	if hello.Compression, ok = r.vector8(); !ok {
+		return nil, errShort
+	}
+
+	// ClientHello without extensions is valid, but not for TLS 1.3
+	if len(r) == 0 {
+		return hello, nil
+	}
+	extLength, ok := r.uint16()
+	if !ok {
+		return nil, errShort
+	}
+	if len(r) < int(extLength) {
+		hello.Truncated = true
+	}
+	for len(r) > 0 {
+		typ, ok1 := r.uint16()
+		size, ok2 := r.uint16()
+		data, ok3 := r.bytes(int(size))
+		if !ok1 || !ok2 || !ok3 {
+			hello.Truncated = true
+			break
+		}
+		hello.Extensions = append(hello.Extensions, Extension{Type: typ, Data: data})
+		if err := hello.decodeExtension(typ, data); err != nil {
+			return nil, fmt.Errorf("invalid %s extension: %v", ExtensionName(typ), err)
+		}
+	}
+	return hello, nil
+}
+
+func (h *ClientHello) decodeExtension(typ uint16, data []byte) error {
+	r := reader(data)
+	switch typ {
+	case extServerName:
+		list, ok := r.vector16()
+		if !ok {
+			return errShort
+		}
+		for l := reader(list); len(l) > 0; {
+			nameType, ok1 := l.uint8()
+			name, ok2 := l.vector16()
+			if !ok1 || !ok2 {
+				return errShort
+			}
+			if nameType == 0 {
+				h.SNI = append(h.SNI, string(name))
+			}
+		}
+	case extALPN:
+		list, ok := r.vector16()
+		if !ok {
+			return errShort
+		}
+		for l := reader(list); len(l) > 0; {
+			protocol, ok := l.vector8()
+			if !ok {
+				return errShort
+			}
+			h.ALPN = append(h.ALPN, string(protocol))
+		}
+	case extSupportedGroups:
+		list, ok := r.vector16()
+		if !ok {
+			return errShort
+		}
+		for l := reader(list); len(l) >= 2; {
+			group, _ := l.uint16()
+			h.SupportedGroups = append(h.SupportedGroups, group)
+		}
+	case extSupportedVersions:
+		list, ok := r.vector8()
+		if !ok {
+			return errShort
+		}
+		for l := reader(list); len(l) >= 2; {
+			version, _ := l.uint16()
+			h.SupportedVersions = append(h.SupportedVersions, version)
+		}
+	case extKeyShare:
+		list, ok := r.vector16()
+		if !ok {
+			return errShort
+		}
+		for l := reader(list); len(l) > 0; {
+			group, ok1 := l.uint16()
+			key, ok2 := l.vector16()
+			if !ok1 || !ok2 {
+				return errShort
+			}
+			h.KeyShares = append(h.KeyShares, KeyShare{Group: group, Length: len(key)})
+		}
+	case extPadding:
+		h.Padding = len(data)
+	}
+	return nil
+}
+
+// reader consumes big endian values from byte slice
+type reader []byte
+
+func (r *reader) bytes(n int) ([]byte, bool) {
+	if n < 0 || len(*r) < n {
+		return nil, false
+	}
+	b := (*r)[:n]
+	*r = (*r)[n:]
+	return b, true
+}
+
+func (r *reader) uint8() (uint8, bool) {
+	b, ok := r.bytes(1)
+	if !ok {
+		return 0, false
+	}
+	return b[0], true
+}
+
+func (r *reader) uint16() (uint16, bool) {
+	b, ok := r.bytes(2)
+	if !ok {
+		return 0, false
+	}
+	return binary.BigEndian.Uint16(b), true
+}
+
+func (r *reader) uint24() (int, bool) {
+	b, ok := r.bytes(3)
+	if !ok {
+		return 0, false
+	}
+	return int(b[0])<<16 | int(b[1])<<8 | int(b[2]), true
+}
+
+func (r *reader) uint32() (uint32, bool) {
+	b, ok := r.bytes(4)
+	if !ok {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(b), true
+}
+
+func (r *reader) vector8() ([]byte, bool) {
+	n, ok := r.uint8()
+	if !ok {
+		return nil, false
+	}
+	return r.bytes(int(n))
+}
+
+func (r *reader) vector16() ([]byte, bool) {
+	n, ok := r.uint16()
+	if !ok {
+		return nil, false
+	}
+	return r.bytes(int(n))
+}
+
+// varint reads QUIC variable-length integer
+func (r *reader) varint() (uint64, bool) {
+	first, ok := r.uint8()
+	if !ok {
+		return 0, false
+	}
+	length := 1 << (first >> 6)
+	value := uint64(first & 0x3f)
+	rest, ok := r.bytes(length - 1)
+	if !ok {
+		return 0, false
+	}
+	for _, b := range rest {
+		value = value<<8 | uint64(b)
+	}
+	return value, true
+}
+
+func (r *reader) rest() []byte {
+	b := *r
+	*r = nil
+	return b
+}
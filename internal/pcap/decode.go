@@ -0,0 +1,165 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// IP protocol numbers
+const (
+	ProtoTCP = 6
+	ProtoUDP = 17
+)
+
+// TCP flags
+const (
+	FlagFIN = 0x01
+	FlagSYN = 0x02
+	FlagRST = 0x04
+	FlagPSH = 0x08
+	FlagACK = 0x10
+)
+
+// Packet is decoded TCP or UDP packet
+type Packet struct {
+	Time     time.Time
+	Src, Dst netip.Addr
+	// TTL is hop limit for IPv6
+	TTL uint8
+	// IPID is identification of IPv4 header, 0 for IPv6
+	IPID  uint16
+	Proto int
+
+	SrcPort, DstPort uint16
+	// TCP only
+	Seq, Ack uint32
+	Flags    uint8
+	Window   uint16
+
+	Payload []byte
+}
+
+// Has reports whether all given TCP flags are set
+func (p *Packet) Has(flags uint8) bool {
+	return p.Flags&flags == flags
+}
+
+// Decode decodes IP packet in frame. It returns nil without error for
+// packets that are neither TCP nor UDP, like ARP or ICMP.
+func Decode(frame Frame) (*Packet, error) {
+	data, ok := stripLink(frame)
+	if !ok || len(data) == 0 {
+		return nil, nil
+	}
+
+	packet := &Packet{Time: frame.Time}
+	var transport []byte
+	switch data[0] >> 4 {
+	case 4:
+		if len(data) < 20 {
+			return nil, fmt.Errorf("truncated IPv4 header")
+		}
+		headerLength := int(data[0]&0x0f) * 4
+		total := int(binary.BigEndian.Uint16(data[2:]))
+		if headerLength < 20 || len(data) < headerLength {
+			return nil, fmt.Errorf("invalid IPv4 header length")
+		}
+		// Fragments other than first carry no transport header
+		if binary.BigEndian.Uint16(data[6:])&0x1fff != 0 {
+			return nil, nil
+		}
+		packet.TTL = data[8]
+		packet.IPID = binary.BigEndian.Uint16(data[4:])
+		packet.Proto = int(data[9])
+		packet.Src = netip.AddrFrom4([4]byte(data[12:16]))
+		packet.Dst = netip.AddrFrom4([4]byte(data[16:20]))
+		// Ethernet pads short frames, total length cuts padding
+		if total >= headerLength && total < len(data) {
+			data = data[:total]
+		}
+		transport = data[headerLength:]
+	case 6:
+		if len(data) < 40 {
+			return nil, fmt.Errorf("truncated IPv6 header")
+		}
+		packet.TTL = data[7]
+		packet.Proto = int(data[6])
+		packet.Src = netip.AddrFrom16([16]byte(data[8:24]))
+		packet.Dst = netip.AddrFrom16([16]byte(data[24:40]))
+		if payloadLength := int(binary.BigEndian.Uint16(data[4:])); 40+payloadLength < len(data) {
+			data = data[:40+payloadLength]
+		}
+		// Extension headers are rare in captures of browsers and are skipped
+		transport = data[40:]
+	default:
+		return nil, nil
+	}
+
+	switch packet.Proto {
+	case ProtoTCP:
+		if len(transport) < 20 {
+			return nil, fmt.Errorf("truncated TCP header")
+		}
+		packet.SrcPort = binary.BigEndian.Uint16(transport)
+		packet.DstPort = binary.BigEndian.Uint16(transport[2:])
+		packet.Seq = binary.BigEndian.Uint32(transport[4:])
+		packet.Ack = binary.BigEndian.Uint32(transport[8:])
+		packet.Flags = transport[13]
+		packet.Window = binary.BigEndian.Uint16(transport[14:])
+		offset := int(transport[12]>>4) * 4
+		if offset < 20 || offset > len(transport) {
+			return nil, fmt.Errorf("invalid TCP data offset")
+		}
+		packet.Payload = transport[offset:]
+	case ProtoUDP:
+		if len(transport) < 8 {
+			return nil, fmt.Errorf("truncated UDP header")
+		}
+		packet.SrcPort = binary.BigEndian.Uint16(transport)
+		packet.DstPort = binary.BigEndian.Uint16(transport[2:])
+		packet.Payload = transport[8:]
+	default:
+		return nil, nil
+	}
+	return packet, nil
+}
+
+// stripLink returns IP packet in frame
+func stripLink(frame Frame) ([]byte, bool) {
+	data := frame.Data
+	switch frame.LinkType {
+	case LinkEthernet:
+		if len(data) < 14 {
+			return nil, false
+		}
+		etherType := binary.BigEndian.Uint16(data[12:])
+		data = data[14:]
+		// VLAN tags
+		for (etherType == 0x8100 || etherType == 0x88a8) && len(data) >= 4 {
+			etherType = binary.BigEndian.Uint16(data[2:])
+			data = data[4:]
+		}
+		return data, etherType == 0x0800 || etherType == 0x86dd
+	case LinkRaw, LinkIPv4, LinkIPv6:
+		return data, true
+	case LinkNull, LinkLoop:
+		// 4 byte address family in host or network byte order
+		if len(data) < 4 {
+			return nil, false
+		}
+		return data[4:], true
+	case LinkLinuxSLL:
+		if len(data) < 16 {
+			return nil, false
+		}
+		return data[16:], true
+	case LinkSLL2:
+		if len(data) < 20 {
+			return nil, false
+		}
+		return data[20:], true
+	}
+	return nil, false
+}
@@ -0,0 +1,248 @@
+// Package pcap reads packet captures in pcap and pcapng formats written by
+// Wireshark and tcpdump and decodes IPv4 and IPv6 packets with TCP and UDP.
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Link types of captures, see https://www.tcpdump.org/linktypes.html
+const (
+	LinkNull     = 0
+	LinkEthernet = 1
+	LinkRaw      = 101
+	LinkLinuxSLL = 113
+	LinkIPv4     = 228
+	LinkIPv6     = 229
+	LinkLoop     = 108
+	LinkSLL2     = 276
+)
+
+// Frame is captured frame with its link type
+type Frame struct {
+	Time     time.Time
+	LinkType int
+	Data     []byte
+}
+
+// Magic numbers of file formats
+const (
+	magicMicros     = 0xa1b2c3d4
+	magicNanos      = 0xa1b23c4d
+	pcapngSHB       = 0x0a0d0d0a
+	pcapngByteOrder = 0x1a2b3c4d
+)
+
+// Block types of pcapng
+const (
+	blockIDB    = 0x00000001
+	blockPacket = 0x00000002 // obsolete Packet Block
+	blockSPB    = 0x00000003
+	blockEPB    = 0x00000006
+)
+
+// ReadFile reads all frames from pcap or pcapng file
+func ReadFile(path string) ([]Frame, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Read(file)
+}
+
+// Read reads all frames from pcap or pcapng stream. Format is detected by
+// magic number.
+func Read(r io.Reader) ([]Frame, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("error reading capture header: %v", err)
+	}
+	if binary.LittleEndian.Uint32(head) == pcapngSHB {
+		return readPcapng(br)
+	}
+	return readPcap(br)
+}
+
+func readPcap(r io.Reader) ([]Frame, error) {
+	header := make([]byte, 24)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("error reading pcap header: %v", err)
+	}
+
+	var order binary.ByteOrder
+	var nanos bool
+	for _, o := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch o.Uint32(header) {
+		case magicMicros:
+			order = o
+		case magicNanos:
+			order, nanos = o, true
+		}
+	}
+	if order == nil {
+		return nil, errors.New("not pcap or pcapng file")
+	}
+	linkType := int(order.Uint32(header[20:]) & 0xffff)
+
+	var frames []Frame
+	record := make([]byte, 16)
+	for {
+		if _, err := io.ReadFull(r, record); err != nil {
+			if err == io.EOF {
+				return frames, nil
+			}
+			// Capture cut while writing, keep what was read
+			if err == io.ErrUnexpectedEOF {
+				return frames, nil
+			}
+			return nil, err
+		}
+		sec := int64(order.Uint32(record))
+		frac := int64(order.Uint32(record[4:]))
+		if !nanos {
+			frac *= 1000
+		}
+		data := make([]byte, order.Uint32(record[8:]))
+		if _, err := io.ReadFull(r, data); err != nil {
+			return frames, nil
+		}
+		frames = append(frames, Frame{Time: time.Unix(sec, frac), LinkType: linkType, Data: data})
+	}
+}
+
+// pcapngInterface is interface described by Interface Description Block
+type pcapngInterface struct {
+	linkType int
+	// units is number of timestamp units per second
+	units uint64
+}
+
+func readPcapng(r io.Reader) ([]Frame, error) {
+	var order binary.ByteOrder = binary.LittleEndian
+	var interfaces []pcapngInterface
+	var frames []Frame
+
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return frames, nil
+			}
+			return nil, err
+		}
+		blockType := order.Uint32(header)
+
+		// Byte order of section is known only from its header block
+		if blockType == pcapngSHB {
+			magic := make([]byte, 4)
+			if _, err := io.ReadFull(r, magic); err != nil {
+				return nil, fmt.Errorf("error reading pcapng section header: %v", err)
+			}
+			switch {
+			case binary.LittleEndian.Uint32(magic) == pcapngByteOrder:
+				order = binary.LittleEndian
+			case binary.BigEndian.Uint32(magic) == pcapngByteOrder:
+				order = binary.BigEndian
+			default:
+				return nil, errors.New("invalid pcapng byte order magic")
+			}
+			length := order.Uint32(header[4:])
+			if length < 16 {
+				return nil, errors.New("invalid pcapng section header")
+			}
+			if _, err := io.CopyN(io.Discard, r, int64(length-12)); err != nil {
+				return frames, nil
+			}
+			interfaces = nil
+			continue
+		}
+
+		length := order.Uint32(header[4:])
+		if length < 12 || length%4 != 0 {
+			return nil, fmt.Errorf("invalid pcapng block length %d", length)
+		}
+		body := make([]byte, length-8)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return frames, nil
+		}
+		// Block ends with its length repeated
+		body = body[:len(body)-4]
+
+		switch blockType {
+		case blockIDB:
+			if len(body) < 8 {
+				return nil, errors.New("invalid pcapng interface description")
+			}
+			iface := pcapngInterface{linkType: int(order.Uint16(body)), units: 1000000}
+			iface.units = timestampUnits(body[8:], order, iface.units)
+			interfaces = append(interfaces, iface)
+		case blockEPB, blockPacket:
+			if len(body) < 20 {
+				continue
+			}
+			id := int(order.Uint32(body))
+			if blockType == blockPacket {
+				id = int(order.Uint16(body))
+			}
+			if id >= len(interfaces) {
+				return nil, fmt.Errorf("packet of unknown interface %d", id)
+			}
+			iface := interfaces[id]
+			timestamp := uint64(order.Uint32(body[4:]))<<32 | uint64(order.Uint32(body[8:]))
+			captured := int(order.Uint32(body[12:]))
+			if 20+captured > len(body) {
+				continue
+			}
+			frames = append(frames, Frame{
+				Time:     unitsToTime(timestamp, iface.units),
+				LinkType: iface.linkType,
+				Data:     append([]byte{}, body[20:20+captured]...),
+			})
+		case blockSPB:
+			if len(interfaces) == 0 || len(body) < 4 {
+				continue
+			}
+			captured := min(int(order.Uint32(body)), len(body)-4)
+			frames = append(frames, Frame{LinkType: interfaces[0].linkType, Data: append([]byte{}, body[4:4+captured]...)})
+		}
+	}
+}
+
+// timestampUnits reads if_tsresol option of interface, by default timestamps
+// are in microseconds
+func timestampUnits(options []byte, order binary.ByteOrder, units uint64) uint64 {
+	for len(options) >= 4 {
+		code := order.Uint16(options)
+		length := int(order.Uint16(options[2:]))
+		if code == 0 || 4+length > len(options) {
+			break
+		}
+		if code == 9 && length >= 1 {
+			resolution := options[4]
+			base := uint64(10)
+			if resolution&0x80 != 0 {
+				base = 2
+			}
+			units = 1
+			for i := 0; i < int(resolution&0x7f); i++ {
+				units *= base
+			}
+		}
+		options = options[4+(length+3)/4*4:]
+	}
+	return units
+}
+
+func unitsToTime(timestamp, units uint64) time.Time {
+	sec := timestamp / units
+	frac := timestamp % units
+	return time.Unix(int64(sec), int64(frac*1000000000/units))
+}
@@ -0,0 +1,175 @@
+package pcap
+
+import (
+	"bytes"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Frames of fixtures are captured starting from this time
+var captureStart = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		file string
+		// links and offsets are link types and times of frames from
+		// capture start, zero time is frame without timestamp
+		links   []int
+		offsets []time.Duration
+	}{
+		// Little endian pcap with microseconds
+		{
+			file:    "ethernet.pcap",
+			links:   []int{LinkEthernet, LinkEthernet, LinkEthernet, LinkEthernet, LinkEthernet},
+			offsets: []time.Duration{0, 1500 * time.Microsecond, 2250 * time.Microsecond, 3 * time.Millisecond, 4 * time.Millisecond},
+		},
+		// Big endian pcap with nanoseconds
+		{
+			file:    "sll.pcap",
+			links:   []int{LinkLinuxSLL},
+			offsets: []time.Duration{123 * time.Nanosecond},
+		},
+		// Interfaces with default and nanosecond resolution, simple packet
+		// block and second section in big endian
+		{
+			file:    "capture.pcapng",
+			links:   []int{LinkEthernet, LinkSLL2, LinkEthernet, LinkNull},
+			offsets: []time.Duration{0, time.Millisecond + time.Nanosecond, -1, 2 * time.Millisecond},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			frames, err := ReadFile(filepath.Join("testdata", test.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(frames) != len(test.links) {
+				t.Fatalf("read %d frames, want %d", len(frames), len(test.links))
+			}
+			for i, frame := range frames {
+				if frame.LinkType != test.links[i] {
+					t.Errorf("frame %d: link type %d, want %d", i, frame.LinkType, test.links[i])
+				}
+				want := time.Time{}
+				if test.offsets[i] >= 0 {
+					want = captureStart.Add(test.offsets[i])
+				}
+				if !frame.Time.Equal(want) {
+					t.Errorf("frame %d: time %v, want %v", i, frame.Time, want)
+				}
+			}
+		})
+	}
+}
+
+// TestReadTruncated reads capture cut while it was written
+func TestReadTruncated(t *testing.T) {
+	for _, file := range []string{"ethernet.pcap", "capture.pcapng"} {
+		data, err := os.ReadFile(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		full, err := Read(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames, err := Read(bytes.NewReader(data[:len(data)-10]))
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+		}
+		if len(frames) != len(full)-1 {
+			t.Errorf("%s: read %d frames of truncated capture, want %d", file, len(frames), len(full)-1)
+		}
+	}
+
+	if _, err := Read(bytes.NewReader([]byte("not a capture file at all"))); err == nil {
+		t.Error("text is read as capture")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	client := netip.MustParseAddr("192.168.1.10")
+	server := netip.MustParseAddr("203.0.113.5")
+	client6 := netip.MustParseAddr("2001:db8::10")
+	server6 := netip.MustParseAddr("2001:db8::5")
+	quic := netip.MustParseAddr("198.51.100.7")
+
+	syn := &Packet{Src: client, Dst: server, TTL: 128, IPID: 0x1234, Proto: ProtoTCP,
+		SrcPort: 50000, DstPort: 443, Seq: 1000, Flags: FlagSYN, Window: 64240, Payload: []byte{}}
+	udp := &Packet{Src: client, Dst: quic, TTL: 64, IPID: 0x2345, Proto: ProtoUDP,
+		SrcPort: 50001, DstPort: 443, Payload: []byte("quic")}
+	v6 := &Packet{Src: client6, Dst: server6, TTL: 57, Proto: ProtoTCP,
+		SrcPort: 50002, DstPort: 443, Seq: 7, Ack: 8, Flags: FlagPSH | FlagACK, Window: 64240, Payload: []byte("hello")}
+
+	tests := []struct {
+		file string
+		// want is decoded packet of every frame, nil for frames that are
+		// skipped
+		want []*Packet
+	}{
+		// SYN in Ethernet frame padded to 60 bytes, UDP with VLAN tag,
+		// IPv6 with two VLAN tags, ARP and IPv4 fragment that isn't first
+		{file: "ethernet.pcap", want: []*Packet{syn, udp, v6, nil, nil}},
+		{file: "sll.pcap", want: []*Packet{syn}},
+		{file: "capture.pcapng", want: []*Packet{syn, v6, udp, udp}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			frames, err := ReadFile(filepath.Join("testdata", test.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(frames) != len(test.want) {
+				t.Fatalf("read %d frames, want %d", len(frames), len(test.want))
+			}
+			for i, frame := range frames {
+				packet, err := Decode(frame)
+				if err != nil {
+					t.Errorf("frame %d: %v", i, err)
+					continue
+				}
+				want := test.want[i]
+				if want == nil {
+					if packet != nil {
+						t.Errorf("frame %d is decoded as %+v, want it skipped", i, packet)
+					}
+					continue
+				}
+				if packet == nil {
+					t.Errorf("frame %d isn't decoded", i)
+					continue
+				}
+				got := *packet
+				got.Time = time.Time{}
+				if got.Src != want.Src || got.Dst != want.Dst || got.TTL != want.TTL || got.IPID != want.IPID ||
+					got.Proto != want.Proto || got.SrcPort != want.SrcPort || got.DstPort != want.DstPort ||
+					got.Seq != want.Seq || got.Ack != want.Ack || got.Flags != want.Flags || got.Window != want.Window ||
+					!bytes.Equal(got.Payload, want.Payload) {
+					t.Errorf("frame %d decoded as\n%+v\nwant\n%+v", i, got, *want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated IPv4", []byte{0x45, 0, 0, 20}},
+		{"truncated IPv6", []byte{0x60, 0, 0, 0}},
+		{"IPv4 header length", append([]byte{0x44}, make([]byte, 19)...)},
+		{"truncated TCP", append([]byte{0x45, 0, 0, 30, 0, 0, 0, 0, 64, ProtoTCP}, make([]byte, 20)...)},
+	}
+	for _, test := range tests {
+		if packet, err := Decode(Frame{LinkType: LinkRaw, Data: test.data}); err == nil {
+			t.Errorf("%s: decoded as %+v", test.name, packet)
+		}
+	}
+}
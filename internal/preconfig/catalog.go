@@ -0,0 +1,507 @@
+package preconfig
+
+import "strings"
+
+// Lang is language of descriptions
+type Lang string
+
+const (
+	LangEN Lang = "en"
+	LangRU Lang = "ru"
+)
+
+// ParseLang returns language by code, English for unknown ones
+func ParseLang(code string) Lang {
+	if strings.HasPrefix(strings.ToLower(code), "ru") {
+		return LangRU
+	}
+	return LangEN
+}
+
+// Text is description in every supported language
+type Text struct {
+	EN, RU string
+}
+
+// In returns text in given language
+func (t Text) In(lang Lang) string {
+	if lang == LangRU && t.RU != "" {
+		return t.RU
+	}
+	return t.EN
+}
+
+// Descriptions of winws options
+var optionTexts = map[string]Text{
+	"wf-iface": {
+		"intercept traffic only of network interface with given index",
+		"перехватывать трафик только сетевого интерфейса с указанным номером",
+	},
+	"wf-l3": {
+		"intercept only given IP versions",
+		"перехватывать только указанные версии IP",
+	},
+	"wf-tcp": {
+		"WinDivert intercepts outgoing TCP connections to these ports, other traffic never reaches winws",
+		"WinDivert перехватывает исходящие TCP соединения на эти порты, остальной трафик не попадает в winws",
+	},
+	"wf-udp": {
+		"WinDivert intercepts outgoing UDP datagrams to these ports, other traffic never reaches winws",
+		"WinDivert перехватывает исходящие UDP датаграммы на эти порты, остальной трафик не попадает в winws",
+	},
+	"wf-raw": {
+		"WinDivert filter written by hand, replaces filter built from --wf-tcp and --wf-udp",
+		"фильтр WinDivert, написанный вручную, заменяет фильтр из --wf-tcp и --wf-udp",
+	},
+	"wf-save": {
+		"save built WinDivert filter to file and exit",
+		"сохранить собранный фильтр WinDivert в файл и выйти",
+	},
+	"wf-dup-check": {
+		"drop duplicate packets WinDivert may deliver twice",
+		"отбрасывать дубликаты пакетов, которые WinDivert может передать дважды",
+	},
+	"ssid-filter": {
+		"work only when connected to Wi-Fi networks with given names",
+		"работать только при подключении к Wi-Fi сетям с указанными именами",
+	},
+	"nlm-filter": {
+		"work only in Windows networks with given names",
+		"работать только в сетях Windows с указанными именами",
+	},
+	"nlm-list": {
+		"print Windows networks and exit",
+		"вывести сети Windows и выйти",
+	},
+	"debug": {
+		"print debug log of every processed packet",
+		"выводить отладочный лог по каждому обработанному пакету",
+	},
+	"dry-run": {
+		"check options and exit without intercepting traffic",
+		"проверить опции и выйти, не перехватывая трафик",
+	},
+	"version": {
+		"print version and exit",
+		"вывести версию и выйти",
+	},
+	"comment": {
+		"comment, ignored by winws",
+		"комментарий, winws его игнорирует",
+	},
+	"ctrack-timeouts": {
+		"how long connection tracker remembers connections in different states",
+		"сколько времени трекер соединений помнит соединения в разных состояниях",
+	},
+	"ctrack-disable": {
+		"disable connection tracking, options that need it stop working",
+		"отключить отслеживание соединений, опции, которым оно нужно, перестают работать",
+	},
+	"ipcache-lifetime": {
+		"how long IP cache keeps hop count and host name of address",
+		"сколько времени кэш IP хранит число хопов и имя хоста адреса",
+	},
+	"ipcache-hostname": {
+		"remember host name of IP address, so later connections without SNI match hostlist",
+		"запоминать имя хоста IP адреса, чтобы последующие соединения без SNI попадали в хостлист",
+	},
+	"new": {
+		"starts next profile, packet is handled by first profile it matches",
+		"начинает следующий профиль, пакет обрабатывается первым подходящим профилем",
+	},
+	"skip": {
+		"profile is ignored, quick way to disable it",
+		"профиль игнорируется, быстрый способ его отключить",
+	},
+	"filter-l3": {
+		"profile applies only to given IP versions",
+		"профиль применяется только к указанным версиям IP",
+	},
+	"filter-tcp": {
+		"profile applies only to TCP connections to these ports",
+		"профиль применяется только к TCP соединениям на эти порты",
+	},
+	"filter-udp": {
+		"profile applies only to UDP datagrams to these ports",
+		"профиль применяется только к UDP датаграммам на эти порты",
+	},
+	"filter-l7": {
+		"profile applies only to given application protocols",
+		"профиль применяется только к указанным протоколам приложений",
+	},
+	"filter-ssid": {
+		"profile applies only in Wi-Fi networks with given names",
+		"профиль применяется только в Wi-Fi сетях с указанными именами",
+	},
+	"hostlist": {
+		"profile applies only to domains from list and their subdomains",
+		"профиль применяется только к доменам из списка и их поддоменам",
+	},
+	"hostlist-domains": {
+		"profile applies only to these domains and their subdomains",
+		"профиль применяется только к этим доменам и их поддоменам",
+	},
+	"hostlist-exclude": {
+		"domains from list and their subdomains are never processed by profile",
+		"домены из списка и их поддомены никогда не обрабатываются профилем",
+	},
+	"hostlist-exclude-domains": {
+		"these domains and their subdomains are never processed by profile",
+		"эти домены и их поддомены никогда не обрабатываются профилем",
+	},
+	"hostlist-auto": {
+		"winws adds domains to this list by itself when connections to them fail like blocked ones",
+		"winws сам добавляет в этот список домены, соединения с которыми обрываются как при блокировке",
+	},
+	"hostlist-auto-fail-threshold": {
+		"number of failures before domain is added to autohostlist",
+		"число неудач, после которого домен добавляется в автохостлист",
+	},
+	"hostlist-auto-fail-time": {
+		"failures are counted within this many seconds",
+		"неудачи считаются в пределах этого числа секунд",
+	},
+	"hostlist-auto-retrans-threshold": {
+		"number of retransmissions that counts as failure",
+		"число повторных передач, которое считается неудачей",
+	},
+	"hostlist-auto-debug": {
+		"log why domains are added to autohostlist",
+		"записывать в лог, почему домены добавлены в автохостлист",
+	},
+	"ipset": {
+		"profile applies only to IP addresses and subnets from list",
+		"профиль применяется только к IP адресам и подсетям из списка",
+	},
+	"ipset-ip": {
+		"profile applies only to these IP addresses and subnets",
+		"профиль применяется только к этим IP адресам и подсетям",
+	},
+	"ipset-exclude": {
+		"IP addresses and subnets from list are never processed by profile",
+		"IP адреса и подсети из списка никогда не обрабатываются профилем",
+	},
+	"ipset-exclude-ip": {
+		"these IP addresses and subnets are never processed by profile",
+		"эти IP адреса и подсети никогда не обрабатываются профилем",
+	},
+	"hostcase": {
+		"write HTTP header as \"host:\", DPI comparing it case-sensitively misses it",
+		"писать HTTP заголовок как \"host:\", DPI, сравнивающий его с учётом регистра, его пропускает",
+	},
+	"hostspell": {
+		"spell HTTP Host header exactly as given, like \"HoSt\"",
+		"писать HTTP заголовок Host ровно так, как указано, например \"HoSt\"",
+	},
+	"hostnospace": {
+		"remove space after \"Host:\" in HTTP request",
+		"убрать пробел после \"Host:\" в HTTP запросе",
+	},
+	"domcase": {
+		"mix case of letters in domain of HTTP Host header",
+		"перемешать регистр букв в домене заголовка HTTP Host",
+	},
+	"methodeol": {
+		"add line break before HTTP method, some DPI can't parse such request",
+		"добавить перевод строки перед HTTP методом, некоторые DPI не могут разобрать такой запрос",
+	},
+	"wssize": {
+		"make server think client's TCP window is tiny, so server splits its answer, including TLS ServerHello, into small segments",
+		"заставить сервер считать TCP окно клиента крошечным, чтобы он разбил ответ, включая TLS ServerHello, на маленькие сегменты",
+	},
+	"wssize-cutoff": {
+		"stop shrinking window after this many packets",
+		"перестать уменьшать окно после этого числа пакетов",
+	},
+	"synack-split": {
+		"split SYN-ACK of server into separate SYN and ACK",
+		"разделить SYN-ACK сервера на отдельные SYN и ACK",
+	},
+	"dpi-desync": {
+		"how to trick DPI, up to three modes are applied in order",
+		"как обмануть DPI, до трёх режимов применяются по порядку",
+	},
+	"dpi-desync-ttl": {
+		"TTL of fake packets: they expire after this many hops, after DPI but before server",
+		"TTL фейковых пакетов: они исчезают через это число хопов, после DPI, но до сервера",
+	},
+	"dpi-desync-ttl6": {
+		"hop limit of fake packets for IPv6",
+		"hop limit фейковых пакетов для IPv6",
+	},
+	"dpi-desync-autottl": {
+		"pick TTL of fake packets by itself: hop count to server, measured from its answer, minus given delta, so fakes expire right before server",
+		"подбирать TTL фейковых пакетов автоматически: число хопов до сервера, измеренное по его ответу, минус указанная дельта, чтобы фейки исчезали прямо перед сервером",
+	},
+	"dpi-desync-autottl6": {
+		"automatic hop limit of fake packets for IPv6",
+		"автоматический hop limit фейковых пакетов для IPv6",
+	},
+	"dpi-desync-fooling": {
+		"how fake packets are spoiled, so DPI accepts them but server drops them",
+		"как испортить фейковые пакеты, чтобы DPI их принял, а сервер отбросил",
+	},
+	"dpi-desync-repeats": {
+		"send every fake packet this many times",
+		"отправлять каждый фейковый пакет столько раз",
+	},
+	"dpi-desync-skip-nosni": {
+		"don't desync TLS connections without SNI",
+		"не применять desync к TLS соединениям без SNI",
+	},
+	"dpi-desync-split-pos": {
+		"where request is split: byte offset or marker like sld (start of domain), midsld (middle of domain) or sniext",
+		"где разрезать запрос: смещение в байтах или маркер вроде sld (начало домена), midsld (середина домена) или sniext",
+	},
+	"dpi-desync-split-http-req": {
+		"where HTTP request is split, like method or host",
+		"где разрезать HTTP запрос, например method или host",
+	},
+	"dpi-desync-split-tls": {
+		"where TLS ClientHello is split, like sni or sniext",
+		"где разрезать TLS ClientHello, например sni или sniext",
+	},
+	"dpi-desync-split-seqovl": {
+		"prepend this many garbage bytes to first segment with overlapping sequence numbers: DPI reads garbage, server overwrites it with real data",
+		"добавить к первому сегменту столько мусорных байт с перекрывающимися номерами последовательности: DPI читает мусор, сервер заменяет его настоящими данными",
+	},
+	"dpi-desync-split-seqovl-pattern": {
+		"file with data used as garbage of --dpi-desync-split-seqovl",
+		"файл с данными, используемыми как мусор для --dpi-desync-split-seqovl",
+	},
+	"dpi-desync-fakedsplit-pattern": {
+		"file with data of fakes sent by fakedsplit and fakeddisorder",
+		"файл с данными фейков, которые отправляют fakedsplit и fakeddisorder",
+	},
+	"dpi-desync-ipfrag-pos-tcp": {
+		"offset of IP fragmentation for TCP",
+		"смещение IP фрагментации для TCP",
+	},
+	"dpi-desync-ipfrag-pos-udp": {
+		"offset of IP fragmentation for UDP",
+		"смещение IP фрагментации для UDP",
+	},
+	"dpi-desync-badseq-increment": {
+		"how much sequence number of badseq fakes is shifted",
+		"на сколько сдвигается номер последовательности фейков badseq",
+	},
+	"dpi-desync-badack-increment": {
+		"how much acknowledgement number of badseq fakes is shifted",
+		"на сколько сдвигается номер подтверждения фейков badseq",
+	},
+	"dpi-desync-any-protocol": {
+		"desync any traffic, not only recognized HTTP, TLS and QUIC, needed for Discord voice and games",
+		"применять desync к любому трафику, а не только к распознанному HTTP, TLS и QUIC, нужно для голоса Discord и игр",
+	},
+	"dpi-desync-fake-http": {
+		"HTTP request sent as fake instead of built-in one",
+		"HTTP запрос, отправляемый как фейк вместо встроенного",
+	},
+	"dpi-desync-fake-tls": {
+		"TLS ClientHello sent as fake instead of built-in one, DPI sees SNI of this file",
+		"TLS ClientHello, отправляемый как фейк вместо встроенного, DPI видит SNI из этого файла",
+	},
+	"dpi-desync-fake-tls-mod": {
+		"how winws changes fake ClientHello, like rnd (random data) or sni=DOMAIN",
+		"как winws изменяет фейковый ClientHello, например rnd (случайные данные) или sni=ДОМЕН",
+	},
+	"dpi-desync-fake-unknown": {
+		"fake sent for TCP protocols winws doesn't recognize",
+		"фейк для TCP протоколов, которые winws не распознаёт",
+	},
+	"dpi-desync-fake-syndata": {
+		"data put into SYN packet by syndata mode",
+		"данные, которые режим syndata кладёт в SYN пакет",
+	},
+	"dpi-desync-fake-quic": {
+		"QUIC Initial sent as fake instead of built-in one",
+		"QUIC Initial, отправляемый как фейк вместо встроенного",
+	},
+	"dpi-desync-fake-wireguard": {
+		"fake sent before WireGuard handshake",
+		"фейк, отправляемый перед рукопожатием WireGuard",
+	},
+	"dpi-desync-fake-dht": {
+		"fake sent before BitTorrent DHT messages",
+		"фейк, отправляемый перед сообщениями BitTorrent DHT",
+	},
+	"dpi-desync-fake-discord": {
+		"fake sent before Discord voice IP discovery",
+		"фейк, отправляемый перед определением IP голоса Discord",
+	},
+	"dpi-desync-fake-stun": {
+		"fake sent before STUN messages",
+		"фейк, отправляемый перед сообщениями STUN",
+	},
+	"dpi-desync-fake-unknown-udp": {
+		"fake sent for UDP protocols winws doesn't recognize",
+		"фейк для UDP протоколов, которые winws не распознаёт",
+	},
+	"dpi-desync-udplen-increment": {
+		"udplen mode makes datagrams this many bytes longer, DPI matching packets by length misses them",
+		"режим udplen удлиняет датаграммы на столько байт, DPI, определяющий пакеты по длине, их пропускает",
+	},
+	"dpi-desync-udplen-pattern": {
+		"data appended to datagrams by udplen mode",
+		"данные, которые режим udplen добавляет к датаграммам",
+	},
+	"dpi-desync-cutoff": {
+		"stop desync after given packet of connection, like n2 (second packet) or d3 (third data packet), saves CPU",
+		"прекратить desync после указанного пакета соединения, например n2 (второй пакет) или d3 (третий пакет с данными), экономит процессор",
+	},
+	"dpi-desync-start": {
+		"start desync only from given packet of connection",
+		"начинать desync только с указанного пакета соединения",
+	},
+}
+
+// Descriptions of --dpi-desync modes
+var modeTexts = map[string]Text{
+	"fake": {
+		"send fake request before real one, DPI reads fake and lets real request through",
+		"отправить фейковый запрос перед настоящим, DPI читает фейк и пропускает настоящий запрос",
+	},
+	"fakeknown": {
+		"like fake, but only for recognized protocols",
+		"как fake, но только для распознанных протоколов",
+	},
+	"rst": {
+		"send fake RST, DPI thinks connection is closed",
+		"отправить фейковый RST, DPI считает соединение закрытым",
+	},
+	"rstack": {
+		"send fake RST with ACK, DPI thinks connection is closed",
+		"отправить фейковый RST с ACK, DPI считает соединение закрытым",
+	},
+	"synack": {
+		"send SYN-ACK before SYN, DPI confuses sides of connection",
+		"отправить SYN-ACK перед SYN, DPI путает стороны соединения",
+	},
+	"syndata": {
+		"put data into SYN packet, DPI that doesn't expect data there gets confused",
+		"положить данные в SYN пакет, DPI, который не ожидает там данных, сбивается",
+	},
+	"disorder": {
+		"split request in two and send second part first",
+		"разрезать запрос на две части и отправить вторую первой",
+	},
+	"disorder2": {
+		"like disorder, but without fakes between parts",
+		"как disorder, но без фейков между частями",
+	},
+	"split": {
+		"split request in two TCP segments, DPI reading single segment misses domain",
+		"разрезать запрос на два TCP сегмента, DPI, читающий один сегмент, не видит домен",
+	},
+	"split2": {
+		"like split, but without fakes between parts",
+		"как split, но без фейков между частями",
+	},
+	"multisplit": {
+		"split request at several positions from --dpi-desync-split-pos",
+		"разрезать запрос в нескольких местах из --dpi-desync-split-pos",
+	},
+	"multidisorder": {
+		"split request at several positions and send parts in reverse order",
+		"разрезать запрос в нескольких местах и отправить части в обратном порядке",
+	},
+	"fakedsplit": {
+		"split request and surround parts with fakes of same size",
+		"разрезать запрос и окружить части фейками того же размера",
+	},
+	"fakeddisorder": {
+		"like fakedsplit, but parts are sent in reverse order",
+		"как fakedsplit, но части отправляются в обратном порядке",
+	},
+	"hostfakesplit": {
+		"split request around domain and send fake domain next to real one",
+		"разрезать запрос вокруг домена и отправить фейковый домен рядом с настоящим",
+	},
+	"ipfrag1": {
+		"fragment IP packet, DPI that doesn't reassemble fragments misses request",
+		"фрагментировать IP пакет, DPI, не собирающий фрагменты, пропускает запрос",
+	},
+	"ipfrag2": {
+		"fragment IP packet, DPI that doesn't reassemble fragments misses request",
+		"фрагментировать IP пакет, DPI, не собирающий фрагменты, пропускает запрос",
+	},
+	"udplen": {
+		"make UDP datagrams longer, see --dpi-desync-udplen-increment",
+		"удлинить UDP датаграммы, см. --dpi-desync-udplen-increment",
+	},
+	"tamper": {
+		"modify packets of protocol, like Discord voice, so DPI doesn't recognize them",
+		"изменить пакеты протокола, например голоса Discord, чтобы DPI их не распознал",
+	},
+	"hopbyhop": {
+		"add IPv6 hop-by-hop header",
+		"добавить заголовок IPv6 hop-by-hop",
+	},
+	"hopbyhop2": {
+		"add two IPv6 hop-by-hop headers",
+		"добавить два заголовка IPv6 hop-by-hop",
+	},
+	"destopt": {
+		"add IPv6 destination options header",
+		"добавить заголовок IPv6 destination options",
+	},
+	"ipfrag1-destopt": {
+		"fragment IPv6 packet and add destination options header",
+		"фрагментировать IPv6 пакет и добавить заголовок IPv6 destination options",
+	},
+	"none": {
+		"do nothing",
+		"ничего не делать",
+	},
+}
+
+// Descriptions of --dpi-desync-fooling methods
+var foolingTexts = map[string]Text{
+	"none": {
+		"fakes aren't spoiled",
+		"фейки не портятся",
+	},
+	"md5sig": {
+		"add TCP MD5 signature option, servers without it drop packet, DPI ignores it",
+		"добавить опцию TCP MD5 подписи, серверы без неё отбрасывают пакет, DPI её игнорирует",
+	},
+	"badseq": {
+		"use wrong sequence number, server drops packet as out of window",
+		"использовать неверный номер последовательности, сервер отбрасывает пакет как вне окна",
+	},
+	"badsum": {
+		"use wrong TCP checksum, server drops packet, DPI doesn't check it",
+		"использовать неверную контрольную сумму TCP, сервер отбрасывает пакет, DPI её не проверяет",
+	},
+	"datanoack": {
+		"send data without ACK flag, server drops it",
+		"отправить данные без флага ACK, сервер их отбрасывает",
+	},
+	"hopbyhop": {
+		"add IPv6 hop-by-hop header",
+		"добавить заголовок IPv6 hop-by-hop",
+	},
+	"hopbyhop2": {
+		"add two IPv6 hop-by-hop headers, packet is dropped by routers",
+		"добавить два заголовка IPv6 hop-by-hop, пакет отбрасывается маршрутизаторами",
+	},
+	"ts": {
+		"use old TCP timestamp, server drops packet",
+		"использовать старую TCP метку времени, сервер отбрасывает пакет",
+	},
+}
+
+// DescribeOption returns description of winws option, empty if unknown
+func DescribeOption(name string, lang Lang) string {
+	return optionTexts[name].In(lang)
+}
+
+// DescribeMode returns description of --dpi-desync mode, empty if unknown
+func DescribeMode(mode string, lang Lang) string {
+	return modeTexts[mode].In(lang)
+}
+
+// DescribeFooling returns description of fooling method, empty if unknown
+func DescribeFooling(method string, lang Lang) string {
+	return foolingTexts[method].In(lang)
+}
@@ -0,0 +1,143 @@
+package preconfig
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Template describes pre-config to generate
+type Template struct {
+	// Title is shown in winws window title after "ZAPRET: "
+	Title string
+	// List is hostlist file name in lists folder, like "list-ultimate.txt"
+	List string
+	// Comments are written after header, one per line
+	Comments []string
+	Tags     []string
+	Profiles []Profile
+}
+
+// Standard header every shipped pre-config starts with
+const header = `@echo off
+chcp 65001 >nul
+:: 65001 - UTF-8
+
+cd /d "%~dp0..\"
+set BIN=%~dp0..\bin\
+`
+
+// NewOption returns option with value written as is
+func NewOption(name, value string) Option {
+	return Option{Name: name, Value: value, RawValue: value, HasValue: true}
+}
+
+// NewFlag returns option without value
+func NewFlag(name string) Option {
+	return Option{Name: name}
+}
+
+// QuotedOption returns option with value in quotes, used for paths
+func QuotedOption(name, value string) Option {
+	return Option{Name: name, Value: value, RawValue: `"` + value + `"`, HasValue: true}
+}
+
+// HostlistOption binds profile to hostlist of pre-config
+func HostlistOption() Option {
+	return QuotedOption("hostlist", "%LIST_PATH%")
+}
+
+// Render returns BAT file in format of shipped pre-configs: --wf-tcp and
+// --wf-udp cover ports of all profiles, every profile on its own line.
+func (t Template) Render() string {
+	var b strings.Builder
+	b.WriteString(header)
+	for _, comment := range t.Comments {
+		b.WriteString(":: " + comment + "\n")
+	}
+	if len(t.Tags) > 0 {
+		b.WriteString(":: tags: " + strings.Join(t.Tags, ", ") + "\n")
+	}
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "set LIST_TITLE=ZAPRET: %s\n", t.Title)
+	fmt.Fprintf(&b, "set LIST_PATH=%%~dp0..\\lists\\%s\n\n", t.List)
+
+	b.WriteString(`start "%LIST_TITLE%" /min "%BIN%winws.exe" ^` + "\n")
+
+	var wf []string
+	if ports := t.ports("filter-tcp"); ports != "" {
+		wf = append(wf, "--wf-tcp="+ports)
+	}
+	if ports := t.ports("filter-udp"); ports != "" {
+		wf = append(wf, "--wf-udp="+ports)
+	}
+	b.WriteString(strings.Join(wf, " "))
+
+	for i, profile := range t.Profiles {
+		if i > 0 {
+			b.WriteString(" --new")
+		}
+		b.WriteString(" ^\n")
+		var options []string
+		for _, o := range profile.Options {
+			options = append(options, o.String())
+		}
+		b.WriteString(strings.Join(options, " "))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// ports returns sorted union of ports of filter option across profiles
+func (t Template) ports(filter string) string {
+	seen := make(map[string]bool)
+	var ports []string
+	for _, profile := range t.Profiles {
+		value, ok := profile.Get(filter)
+		if !ok {
+			continue
+		}
+		for _, port := range strings.Split(value, ",") {
+			if port != "" && !seen[port] {
+				seen[port] = true
+				ports = append(ports, port)
+			}
+		}
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		a, _ := strconv.Atoi(strings.Split(ports[i], "-")[0])
+		b, _ := strconv.Atoi(strings.Split(ports[j], "-")[0])
+		return a < b
+	})
+	return strings.Join(ports, ",")
+}
+
+// Save writes pre-config to directory as "<name>.bat". Existing file is
+// overwritten only when overwrite is set, otherwise error wrapping
+// fs.ErrExist is returned.
+func (t Template) Save(dir, name string, overwrite bool) (string, error) {
+	if strings.ContainsAny(name, `\/:*?"<>|`) {
+		return "", fmt.Errorf("invalid pre-config name: %s", name)
+	}
+	path := filepath.Join(dir, name+".bat")
+	if _, err := os.Stat(path); err == nil && !overwrite {
+		return "", fmt.Errorf("pre-config %s: %w", name, fs.ErrExist)
+	}
+
+	// Parse rendered file back, so broken template is never saved
+	content := t.Render()
+	if _, err := Parse(name+".bat", strings.NewReader(content)); err != nil {
+		return "", fmt.Errorf("generated pre-config is invalid: %v", err)
+	}
+
+	// cmd handles Windows line endings most reliably
+	content = strings.ReplaceAll(content, "\n", "\r\n")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("error saving pre-config: %v", err)
+	}
+	return path, nil
+}
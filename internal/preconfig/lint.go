@@ -0,0 +1,131 @@
+package preconfig
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ankddev/zapret-discord-youtube/internal/fake"
+)
+
+// Severity of problem found in pre-config
+type Severity string
+
+const (
+	// Error breaks strategy, like fake of wrong protocol
+	Error Severity = "error"
+	// Warning may break strategy on some machines, like absolute path
+	Warning Severity = "warning"
+)
+
+// Problem is issue found in pre-config
+type Problem struct {
+	Severity  Severity
+	Preconfig string
+	Option    string
+	Message   string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s: --%s: %s", p.Severity, p.Preconfig, p.Option, p.Message)
+}
+
+// Options of winws taking payload file with kinds of payload they accept.
+// Empty list means any payload fits.
+var payloadOptions = map[string][]fake.Kind{
+	"dpi-desync-fake-tls":             {fake.KindTLS},
+	"dpi-desync-fake-quic":            {fake.KindQUIC, fake.KindQUICAny},
+	"dpi-desync-fake-http":            {fake.KindHTTP},
+	"dpi-desync-fake-wireguard":       {fake.KindWG},
+	"dpi-desync-fake-dht":             {fake.KindDHT},
+	"dpi-desync-fake-discord":         nil,
+	"dpi-desync-fake-stun":            nil,
+	"dpi-desync-fake-unknown":         nil,
+	"dpi-desync-fake-unknown-udp":     nil,
+	"dpi-desync-fake-syndata":         nil,
+	"dpi-desync-split-seqovl-pattern": nil,
+	"dpi-desync-udplen-pattern":       nil,
+}
+
+// isInlinePayload reports whether value is payload itself, not file: hex
+// string like 0xDEADBEEF or ! for built-in payload of winws
+func isInlinePayload(value string) bool {
+	return strings.HasPrefix(value, "0x") || strings.HasPrefix(value, "!")
+}
+
+// LintPayloads checks that every payload file pre-configs refer to exists and
+// its content matches option it's passed to, so TLS ClientHello isn't sent as
+// QUIC fake. Files are classified by content, not by name.
+func LintPayloads(preconfigs []*Preconfig) []Problem {
+	kinds := make(map[string]fake.Kind)
+	reported := make(map[string]bool)
+	var problems []Problem
+
+	for _, p := range preconfigs {
+		options := append([]Option{}, p.Global...)
+		for _, profile := range p.Profiles {
+			options = append(options, profile.Options...)
+		}
+
+		for _, o := range options {
+			accepted, ok := payloadOptions[o.Name]
+			if !ok || !o.HasValue || isInlinePayload(o.Value) {
+				continue
+			}
+			// Same payload is often passed to every profile
+			key := p.Name + "|" + o.Name + "|" + o.Value
+			if reported[key] {
+				continue
+			}
+			reported[key] = true
+			problem := Problem{Preconfig: p.Name, Option: o.Name}
+
+			value := strings.Trim(o.RawValue, `"`)
+			path := p.ResolvePath(o.Value)
+			kind, classified := kinds[path]
+			if !classified {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					problem.Severity = Warning
+					problem.Message = fmt.Sprintf("payload file %s not found", value)
+					if strings.HasPrefix(value, "/") || strings.Contains(value, ":") {
+						problem.Message += ", use path relative to %BIN% instead of absolute one"
+					}
+					problems = append(problems, problem)
+					continue
+				}
+				kind = fake.Detect(data)
+				kinds[path] = kind
+			}
+
+			if len(accepted) > 0 && !containsKind(accepted, kind) {
+				problem.Severity = Error
+				problem.Message = fmt.Sprintf("%s is %s, expected %s", value, kind, kindList(accepted))
+				problems = append(problems, problem)
+			}
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Severity == Error && problems[j].Severity != Error
+	})
+	return problems
+}
+
+func containsKind(kinds []fake.Kind, kind fake.Kind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func kindList(kinds []fake.Kind) string {
+	names := make([]string, len(kinds))
+	for i, k := range kinds {
+		names[i] = string(k)
+	}
+	return strings.Join(names, " or ")
+}
@@ -0,0 +1,72 @@
+package preconfig
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLintPayloads(t *testing.T) {
+	// Payloads are resolved relative to pre-config, so fixtures are loaded by
+	// absolute path
+	dir, err := filepath.Abs(filepath.Join("testdata", "pre-configs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		// problems are severity, option and part of message of every
+		// problem found, errors first
+		problems []Problem
+	}{
+		{name: "Shipped payloads"},
+		{name: "QUIC as TLS", problems: []Problem{
+			{Severity: Error, Option: "dpi-desync-fake-tls", Message: "is QUIC Initial, expected TLS ClientHello"},
+		}},
+		{name: "TLS as QUIC", problems: []Problem{
+			{Severity: Error, Option: "dpi-desync-fake-quic", Message: "is TLS ClientHello, expected QUIC Initial or QUIC packet"},
+		}},
+		{name: "Missing payloads", problems: []Problem{
+			{Severity: Warning, Option: "dpi-desync-fake-tls", Message: "tls_clienthello_missing.bin not found"},
+			{Severity: Warning, Option: "dpi-desync-fake-syndata", Message: "use path relative to %BIN%"},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := Load(filepath.Join(dir, test.name+".bat"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			problems := LintPayloads([]*Preconfig{p})
+			if len(problems) != len(test.problems) {
+				t.Fatalf("problems %v, want %d", problems, len(test.problems))
+			}
+			for i, want := range test.problems {
+				got := problems[i]
+				if got.Severity != want.Severity || got.Option != want.Option || got.Preconfig != test.name || !strings.Contains(got.Message, want.Message) {
+					t.Errorf("problem %q, want %s: %s: --%s: ...%s...", got, want.Severity, test.name, want.Option, want.Message)
+				}
+			}
+		})
+	}
+}
+
+// TestLintShippedPreconfigs runs check build does, so broken pre-config fails
+// tests before release
+func TestLintShippedPreconfigs(t *testing.T) {
+	dir, err := filepath.Abs(filepath.Join("..", "..", "pre-configs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	preconfigs, err := LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, problem := range LintPayloads(preconfigs) {
+		if problem.Severity == Error {
+			t.Error(problem)
+		}
+	}
+}
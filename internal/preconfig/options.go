@@ -0,0 +1,202 @@
+package preconfig
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ArgKind tells whether winws option takes value
+type ArgKind int
+
+const (
+	ArgNone ArgKind = iota
+	ArgRequired
+	ArgOptional
+)
+
+// OptionSpec describes option winws accepts
+type OptionSpec struct {
+	Name string
+	Arg  ArgKind
+	// File is set for options whose value is path of file that must exist,
+	// unless it's inline payload
+	File bool
+}
+
+// Options of winws used by pre-configs and blockcheck. Options of nfqws that
+// winws doesn't support, like --qnum, aren't listed.
+var optionSpecs = []OptionSpec{
+	// WinDivert filter
+	{Name: "wf-iface", Arg: ArgRequired},
+	{Name: "wf-l3", Arg: ArgRequired},
+	{Name: "wf-tcp", Arg: ArgRequired},
+	{Name: "wf-udp", Arg: ArgRequired},
+	{Name: "wf-raw", Arg: ArgRequired},
+	{Name: "wf-save", Arg: ArgRequired},
+	{Name: "wf-dup-check", Arg: ArgOptional},
+	{Name: "ssid-filter", Arg: ArgRequired},
+	{Name: "nlm-filter", Arg: ArgRequired},
+	{Name: "nlm-list", Arg: ArgOptional},
+
+	// Instance
+	{Name: "debug", Arg: ArgOptional},
+	{Name: "dry-run", Arg: ArgNone},
+	{Name: "version", Arg: ArgNone},
+	{Name: "comment", Arg: ArgOptional},
+	{Name: "ctrack-timeouts", Arg: ArgRequired},
+	{Name: "ctrack-disable", Arg: ArgOptional},
+	{Name: "ipcache-lifetime", Arg: ArgRequired},
+	{Name: "ipcache-hostname", Arg: ArgOptional},
+
+	// Profile filters
+	{Name: "new", Arg: ArgNone},
+	{Name: "skip", Arg: ArgNone},
+	{Name: "filter-l3", Arg: ArgRequired},
+	{Name: "filter-tcp", Arg: ArgRequired},
+	{Name: "filter-udp", Arg: ArgRequired},
+	{Name: "filter-l7", Arg: ArgRequired},
+	{Name: "filter-ssid", Arg: ArgRequired},
+	{Name: "hostlist", Arg: ArgRequired, File: true},
+	{Name: "hostlist-domains", Arg: ArgRequired},
+	{Name: "hostlist-exclude", Arg: ArgRequired, File: true},
+	{Name: "hostlist-exclude-domains", Arg: ArgRequired},
+	{Name: "hostlist-auto", Arg: ArgRequired},
+	{Name: "hostlist-auto-fail-threshold", Arg: ArgRequired},
+	{Name: "hostlist-auto-fail-time", Arg: ArgRequired},
+	{Name: "hostlist-auto-retrans-threshold", Arg: ArgRequired},
+	{Name: "hostlist-auto-debug", Arg: ArgRequired},
+	{Name: "ipset", Arg: ArgRequired, File: true},
+	{Name: "ipset-ip", Arg: ArgRequired},
+	{Name: "ipset-exclude", Arg: ArgRequired, File: true},
+	{Name: "ipset-exclude-ip", Arg: ArgRequired},
+
+	// HTTP modifications
+	{Name: "hostcase", Arg: ArgNone},
+	{Name: "hostspell", Arg: ArgRequired},
+	{Name: "hostnospace", Arg: ArgNone},
+	{Name: "domcase", Arg: ArgNone},
+	{Name: "methodeol", Arg: ArgNone},
+
+	// TCP window
+	{Name: "wssize", Arg: ArgRequired},
+	{Name: "wssize-cutoff", Arg: ArgRequired},
+	{Name: "synack-split", Arg: ArgOptional},
+
+	// Desync
+	{Name: "dpi-desync", Arg: ArgRequired},
+	{Name: "dpi-desync-ttl", Arg: ArgRequired},
+	{Name: "dpi-desync-ttl6", Arg: ArgRequired},
+	{Name: "dpi-desync-autottl", Arg: ArgOptional},
+	{Name: "dpi-desync-autottl6", Arg: ArgOptional},
+	{Name: "dpi-desync-fooling", Arg: ArgRequired},
+	{Name: "dpi-desync-repeats", Arg: ArgRequired},
+	{Name: "dpi-desync-skip-nosni", Arg: ArgOptional},
+	{Name: "dpi-desync-split-pos", Arg: ArgRequired},
+	{Name: "dpi-desync-split-http-req", Arg: ArgRequired},
+	{Name: "dpi-desync-split-tls", Arg: ArgRequired},
+	{Name: "dpi-desync-split-seqovl", Arg: ArgRequired},
+	{Name: "dpi-desync-split-seqovl-pattern", Arg: ArgRequired, File: true},
+	{Name: "dpi-desync-fakedsplit-pattern", Arg: ArgRequired, File: true},
+	{Name: "dpi-desync-ipfrag-pos-tcp", Arg: ArgRequired},
+	{Name: "dpi-desync-ipfrag-pos-udp", Arg: ArgRequired},
+	{Name: "dpi-desync-badseq-increment", Arg: ArgRequired},
+	{Name: "dpi-desync-badack-increment", Arg: ArgRequired},
+	{Name: "dpi-desync-any-protocol", Arg: ArgOptional},
+	{Name: "dpi-desync-fake-http", Arg: ArgRequired, File: true},
+	{Name: "dpi-desync-fake-tls", Arg: ArgRequired, File: true},
+	{Name: "dpi-desync-fake-tls-mod", Arg: ArgRequired},
+	{Name: "dpi-desync-fake-unknown", Arg: ArgRequired, File: true},
+	{Name: "dpi-desync-fake-syndata", Arg: ArgRequired, File: true},
+	{Name: "dpi-desync-fake-quic", Arg: ArgRequired, File: true},
+	{Name: "dpi-desync-fake-wireguard", Arg: ArgRequired, File: true},
+	{Name: "dpi-desync-fake-dht", Arg: ArgRequired, File: true},
+	{Name: "dpi-desync-fake-discord", Arg: ArgRequired, File: true},
+	{Name: "dpi-desync-fake-stun", Arg: ArgRequired, File: true},
+	{Name: "dpi-desync-fake-unknown-udp", Arg: ArgRequired, File: true},
+	{Name: "dpi-desync-udplen-increment", Arg: ArgRequired},
+	{Name: "dpi-desync-udplen-pattern", Arg: ArgRequired, File: true},
+	{Name: "dpi-desync-cutoff", Arg: ArgRequired},
+	{Name: "dpi-desync-start", Arg: ArgRequired},
+}
+
+var optionsByName = func() map[string]OptionSpec {
+	specs := make(map[string]OptionSpec, len(optionSpecs))
+	for _, spec := range optionSpecs {
+		specs[spec.Name] = spec
+	}
+	return specs
+}()
+
+// LookupOption returns description of winws option by name without dashes
+func LookupOption(name string) (OptionSpec, bool) {
+	spec, ok := optionsByName[name]
+	return spec, ok
+}
+
+// Desync modes winws knows, up to three of them are joined by comma
+var desyncModes = map[string]bool{
+	"fake": true, "fakeknown": true, "rst": true, "rstack": true, "synack": true, "syndata": true,
+	"disorder": true, "disorder2": true, "split": true, "split2": true, "multisplit": true,
+	"multidisorder": true, "fakedsplit": true, "fakeddisorder": true, "hostfakesplit": true,
+	"ipfrag1": true, "ipfrag2": true, "udplen": true, "tamper": true, "hopbyhop": true,
+	"hopbyhop2": true, "destopt": true, "ipfrag1-destopt": true, "none": true,
+}
+
+// Fooling methods of --dpi-desync-fooling
+var foolingMethods = map[string]bool{
+	"none": true, "md5sig": true, "badseq": true, "badsum": true, "datanoack": true,
+	"hopbyhop": true, "hopbyhop2": true, "ts": true,
+}
+
+// ValidateValue checks value of option the way winws parses it. Only options
+// with fixed syntax are checked, others are accepted as is.
+func ValidateValue(name, value string) error {
+	switch name {
+	case "wf-tcp", "wf-udp", "filter-tcp", "filter-udp":
+		return validatePorts(value)
+	case "wf-l3", "filter-l3":
+		for _, l3 := range strings.Split(value, ",") {
+			if l3 != "ipv4" && l3 != "ipv6" {
+				return fmt.Errorf("invalid l3 protocol '%s'", l3)
+			}
+		}
+	case "dpi-desync":
+		modes := strings.Split(value, ",")
+		if len(modes) > 3 {
+			return fmt.Errorf("too many desync modes '%s'", value)
+		}
+		for _, mode := range modes {
+			if !desyncModes[mode] {
+				return fmt.Errorf("invalid desync mode '%s'", mode)
+			}
+		}
+	case "dpi-desync-fooling":
+		for _, method := range strings.Split(value, ",") {
+			if !foolingMethods[method] {
+				return fmt.Errorf("invalid fooling '%s'", method)
+			}
+		}
+	case "dpi-desync-repeats", "dpi-desync-ttl", "dpi-desync-ttl6", "dpi-desync-split-seqovl", "dpi-desync-udplen-increment":
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("invalid number '%s'", value)
+		}
+	}
+	return nil
+}
+
+// validatePorts checks port list like "80,443,50000-65535"
+func validatePorts(list string) error {
+	for _, item := range strings.Split(list, ",") {
+		low, high, isRange := strings.Cut(item, "-")
+		if !isRange {
+			high = low
+		}
+		from, err1 := strconv.Atoi(low)
+		to, err2 := strconv.Atoi(high)
+		if err1 != nil || err2 != nil || from < 0 || to > 65535 || from > to {
+			return fmt.Errorf("invalid port filter '%s'", item)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,381 @@
+// Package preconfig parses pre-config BAT files: variables they set, metadata
+// in comments and winws command line split into profiles.
+package preconfig
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Option is single winws option like --dpi-desync=fake
+type Option struct {
+	Name     string // without leading dashes
+	Value    string // with variables expanded and quotes removed
+	RawValue string // as written in BAT file
+	HasValue bool
+}
+
+func (o Option) String() string {
+	if !o.HasValue {
+		return "--" + o.Name
+	}
+	return "--" + o.Name + "=" + o.RawValue
+}
+
+// Profile is set of options between --new separators
+type Profile struct {
+	Options []Option
+}
+
+// Get returns value of first option with given name
+func (p Profile) Get(name string) (string, bool) {
+	for _, o := range p.Options {
+		if o.Name == name {
+			return o.Value, true
+		}
+	}
+	return "", false
+}
+
+// Has reports whether profile contains option with given name
+func (p Profile) Has(name string) bool {
+	_, ok := p.Get(name)
+	return ok
+}
+
+// Preconfig is parsed pre-config BAT file
+type Preconfig struct {
+	Path   string
+	Name   string // file name without extension
+	Family string // part of name before variant, like "UltimateFix"
+	Title  string // window title from LIST_TITLE
+	Vars   map[string]string
+	Tags   []string
+	// Global holds options applied to whole winws instance, like --wf-tcp
+	Global []Option
+	// Profiles hold options of every profile, global options excluded
+	Profiles []Profile
+	// Trailing holds lines after winws command that aren't part of it
+	// because previous line doesn't end with ^
+	Trailing []string
+}
+
+// Options that configure winws instance rather than single profile
+var globalOptions = map[string]bool{
+	"debug":            true,
+	"ctrack-timeouts":  true,
+	"ipcache-lifetime": true,
+	"ipcache-hostname": true,
+}
+
+// IsGlobal reports whether option applies to whole winws instance
+func IsGlobal(name string) bool {
+	return strings.HasPrefix(name, "wf-") || globalOptions[name]
+}
+
+// Load parses pre-config from file
+func Load(path string) (*Preconfig, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	p, err := Parse(filepath.Base(path), file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filepath.Base(path), err)
+	}
+	p.Path = path
+	return p, nil
+}
+
+// LoadDir parses every pre-config in directory, sorted by name
+func LoadDir(dir string) ([]*Preconfig, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".bat") {
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var preconfigs []*Preconfig
+	for _, name := range names {
+		p, err := Load(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		preconfigs = append(preconfigs, p)
+	}
+	return preconfigs, nil
+}
+
+// Parse reads pre-config from r. Name is file name, used to derive family.
+func Parse(name string, r io.Reader) (*Preconfig, error) {
+	p := &Preconfig{
+		Name: strings.TrimSuffix(name, filepath.Ext(name)),
+		Vars: make(map[string]string),
+	}
+	p.Family = FamilyOf(p.Name)
+
+	var command []string
+	inCommand, commandDone := false, false
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if inCommand {
+			continued := strings.HasSuffix(line, "^")
+			command = append(command, strings.TrimSpace(strings.TrimSuffix(line, "^")))
+			if !continued {
+				inCommand, commandDone = false, true
+			}
+			continue
+		}
+
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "::"), strings.HasPrefix(strings.ToLower(line), "rem "):
+			p.parseComment(line)
+		case strings.HasPrefix(strings.ToLower(line), "set "):
+			key, value, ok := strings.Cut(line[4:], "=")
+			if ok {
+				p.Vars[strings.TrimSpace(key)] = value
+			}
+		case strings.HasPrefix(strings.ToLower(line), "start ") && strings.Contains(strings.ToLower(line), "winws.exe"):
+			if commandDone || command != nil {
+				return nil, fmt.Errorf("more than one winws command")
+			}
+			continued := strings.HasSuffix(line, "^")
+			command = append(command, strings.TrimSpace(strings.TrimSuffix(line, "^")))
+			inCommand = continued
+			commandDone = !continued
+		case commandDone:
+			p.Trailing = append(p.Trailing, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if command == nil {
+		return nil, fmt.Errorf("winws command not found")
+	}
+
+	p.Title = p.Vars["LIST_TITLE"]
+	if err := p.parseCommand(strings.Join(command, " ")); err != nil {
+		return nil, err
+	}
+	p.deriveTags()
+	return p, nil
+}
+
+// parseComment reads metadata comments like ":: tags: youtube, discord"
+func (p *Preconfig) parseComment(line string) {
+	text := strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(line, "::"), "rem "))
+	key, value, ok := strings.Cut(text, ":")
+	if !ok || strings.ToLower(strings.TrimSpace(key)) != "tags" {
+		return
+	}
+	for _, tag := range strings.Split(value, ",") {
+		p.addTag(tag)
+	}
+}
+
+func (p *Preconfig) parseCommand(command string) error {
+	tokens, err := splitArgs(command)
+	if err != nil {
+		return err
+	}
+
+	// Skip "start", window title, start flags and winws path
+	i := 0
+	for i < len(tokens) && !strings.HasSuffix(strings.ToLower(tokens[i].raw), "winws.exe\"") &&
+		!strings.HasSuffix(strings.ToLower(tokens[i].raw), "winws.exe") {
+		i++
+	}
+	if i == len(tokens) {
+		return fmt.Errorf("winws executable not found in command")
+	}
+
+	profile := Profile{}
+	args := tokens[i+1:]
+	for j := 0; j < len(args); j++ {
+		token := args[j]
+		if !strings.HasPrefix(token.raw, "--") {
+			return fmt.Errorf("unexpected argument: %s", token.raw)
+		}
+		name, raw, hasValue := strings.Cut(token.raw[2:], "=")
+		// winws also accepts value as separate argument: --wssize 1:6
+		if !hasValue && j+1 < len(args) && !strings.HasPrefix(args[j+1].raw, "--") {
+			raw, hasValue = args[j+1].raw, true
+			j++
+		}
+		if name == "new" {
+			p.Profiles = append(p.Profiles, profile)
+			profile = Profile{}
+			continue
+		}
+
+		option := Option{Name: name, HasValue: hasValue}
+		if hasValue {
+			option.RawValue = raw
+			option.Value = p.Expand(unquote(raw))
+		}
+		if IsGlobal(name) {
+			p.Global = append(p.Global, option)
+		} else {
+			profile.Options = append(profile.Options, option)
+		}
+	}
+	if len(profile.Options) > 0 {
+		p.Profiles = append(p.Profiles, profile)
+	}
+	return nil
+}
+
+// Expand replaces %VAR% references with values set in pre-config. Unknown
+// variables and %~dp0 are kept as is.
+func (p *Preconfig) Expand(s string) string {
+	for i := 0; i < 10 && strings.Contains(s, "%"); i++ {
+		expanded := s
+		for key, value := range p.Vars {
+			expanded = strings.ReplaceAll(expanded, "%"+key+"%", value)
+		}
+		if expanded == s {
+			break
+		}
+		s = expanded
+	}
+	return s
+}
+
+// ResolvePath returns path of file option value refers to on this machine.
+// Relative paths are relative to root folder of fix, as pre-configs run from
+// it. Cygwin paths like /cygdrive/c/... are mapped to Windows ones.
+func (p *Preconfig) ResolvePath(value string) string {
+	dir := filepath.Dir(p.Path)
+	path := strings.ReplaceAll(p.Expand(value), "%~dp0", filepath.ToSlash(dir)+"/")
+	path = strings.ReplaceAll(path, `\`, "/")
+	if rest, ok := strings.CutPrefix(path, "/cygdrive/"); ok && len(rest) > 0 {
+		drive, rest, _ := strings.Cut(rest, "/")
+		path = drive + ":/" + rest
+	}
+	if !filepath.IsAbs(path) && !isDrivePath(path) {
+		path = filepath.Join(filepath.Dir(dir), path)
+	}
+	return filepath.Clean(filepath.FromSlash(path))
+}
+
+func isDrivePath(path string) bool {
+	return len(path) >= 3 && path[1] == ':' && path[2] == '/'
+}
+
+// GetGlobal returns value of global option
+func (p *Preconfig) GetGlobal(name string) (string, bool) {
+	for _, o := range p.Global {
+		if o.Name == name {
+			return o.Value, true
+		}
+	}
+	return "", false
+}
+
+// deriveTags adds tags from family name and from lists and ipsets pre-config
+// uses, so "YoutubeFix" or pre-config using list-youtube.txt gets "youtube"
+func (p *Preconfig) deriveTags() {
+	p.addTag(strings.TrimSuffix(strings.ToLower(p.Family), "fix"))
+	for _, profile := range p.Profiles {
+		for _, o := range profile.Options {
+			switch o.Name {
+			case "hostlist", "ipset":
+				base := strings.ToLower(filepath.Base(strings.ReplaceAll(o.Value, "\\", "/")))
+				base = strings.TrimSuffix(base, filepath.Ext(base))
+				base = strings.TrimPrefix(strings.TrimPrefix(base, "list-"), "ipset-")
+				p.addTag(base)
+			}
+		}
+	}
+}
+
+func (p *Preconfig) addTag(tag string) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" {
+		return
+	}
+	for _, t := range p.Tags {
+		if t == tag {
+			return
+		}
+	}
+	p.Tags = append(p.Tags, tag)
+}
+
+// HasTag reports whether pre-config has given tag
+func (p *Preconfig) HasTag(tag string) bool {
+	tag = strings.ToLower(tag)
+	for _, t := range p.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// FamilyOf returns family of pre-config name: "UltimateFix (ALT v4)" belongs
+// to "UltimateFix"
+func FamilyOf(name string) string {
+	name = strings.TrimSuffix(name, ".bat")
+	if i := strings.Index(name, " ("); i >= 0 {
+		return name[:i]
+	}
+	return strings.TrimSpace(name)
+}
+
+type token struct {
+	raw string
+}
+
+// splitArgs splits command line by spaces outside of double quotes. Quotes
+// are kept in tokens, so values can be written back unchanged.
+func splitArgs(command string) ([]token, error) {
+	var tokens []token
+	var current strings.Builder
+	inQuotes := false
+	for _, c := range command {
+		switch {
+		case c == '"':
+			inQuotes = !inQuotes
+			current.WriteRune(c)
+		case (c == ' ' || c == '\t') && !inQuotes:
+			if current.Len() > 0 {
+				tokens = append(tokens, token{raw: current.String()})
+				current.Reset()
+			}
+		default:
+			current.WriteRune(c)
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quote in winws command")
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, token{raw: current.String()})
+	}
+	return tokens, nil
+}
+
+func unquote(s string) string {
+	return strings.ReplaceAll(s, "\"", "")
+}
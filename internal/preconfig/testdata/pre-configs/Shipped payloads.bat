@@ -0,0 +1,13 @@
+@echo off
+chcp 65001 >nul
+:: 65001 - UTF-8
+
+cd /d "%~dp0..\"
+:: Fixtures use payloads shipped in bin folder of repository
+set BIN=%~dp0..\..\..\..\bin\
+
+start "zapret" /min "%BIN%winws.exe" ^
+--wf-tcp=443 --wf-udp=443 ^
+--filter-tcp=443 --dpi-desync=fake --dpi-desync-fake-tls="%BIN%tls_clienthello_www_google_com.bin" --new ^
+--filter-udp=443 --dpi-desync=fake --dpi-desync-fake-quic="%BIN%quic_initial_www_google_com.bin" --new ^
+--filter-udp=50000-50100 --dpi-desync=fake --dpi-desync-fake-quic="%BIN%quic_initial_www_google_com.bin" --dpi-desync-fake-unknown-udp=0x00000000
@@ -0,0 +1,11 @@
+@echo off
+chcp 65001 >nul
+:: 65001 - UTF-8
+
+cd /d "%~dp0..\"
+:: Fixtures use payloads shipped in bin folder of repository
+set BIN=%~dp0..\..\..\..\bin\
+
+start "zapret" /min "%BIN%winws.exe" ^
+--wf-udp=443 ^
+--filter-udp=443 --dpi-desync=fake --dpi-desync-fake-quic="%BIN%tls_clienthello_www_google_com.bin"
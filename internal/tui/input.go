@@ -0,0 +1,87 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/eiannone/keyboard"
+)
+
+// Key is key list reacts to
+type Key int
+
+const (
+	KeyUnknown Key = iota
+	KeyUp
+	KeyDown
+	KeyPageUp
+	KeyPageDown
+	KeyHome
+	KeyEnd
+	KeyEnter
+	KeySpace
+	KeyEsc
+)
+
+// Input delivers keys pressed by user
+type Input interface {
+	ReadKey() (Key, error)
+}
+
+// Keyboard reads keys from console
+type Keyboard struct{}
+
+// OpenKeyboard switches console to reading single keys
+func OpenKeyboard() (*Keyboard, error) {
+	if err := keyboard.Open(); err != nil {
+		return nil, fmt.Errorf("error initializing keyboard: %v", err)
+	}
+	return &Keyboard{}, nil
+}
+
+func (k *Keyboard) ReadKey() (Key, error) {
+	_, key, err := keyboard.GetKey()
+	if err != nil {
+		return KeyUnknown, fmt.Errorf("error reading keyboard: %v", err)
+	}
+	switch key {
+	case keyboard.KeyArrowUp:
+		return KeyUp, nil
+	case keyboard.KeyArrowDown:
+		return KeyDown, nil
+	case keyboard.KeyPgup:
+		return KeyPageUp, nil
+	case keyboard.KeyPgdn:
+		return KeyPageDown, nil
+	case keyboard.KeyHome:
+		return KeyHome, nil
+	case keyboard.KeyEnd:
+		return KeyEnd, nil
+	case keyboard.KeyEnter:
+		return KeyEnter, nil
+	case keyboard.KeySpace:
+		return KeySpace, nil
+	case keyboard.KeyEsc, keyboard.KeyCtrlC:
+		// Console doesn't send interrupt while keys are read one by one
+		return KeyEsc, nil
+	}
+	return KeyUnknown, nil
+}
+
+// Close returns console to line input
+func (k *Keyboard) Close() {
+	keyboard.Close()
+}
+
+// Keys is input that replays fixed keys, for driving list without console.
+// It returns io.EOF after last key.
+type Keys []Key
+
+func (k *Keys) ReadKey() (Key, error) {
+	if len(*k) == 0 {
+		return KeyUnknown, io.EOF
+	}
+	key := (*k)[0]
+	*k = (*k)[1:]
+	return key, nil
+}
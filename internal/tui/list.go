@@ -0,0 +1,250 @@
+// Package tui draws menus of interactive utilities: lists scrolled to fit
+// terminal, with single choice or with checkboxes.
+package tui
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	colorReset = "\033[0m"
+	colorCyan  = "\033[36m"
+	colorGrey  = "\033[90m"
+
+	clearFrame = "\033[H\033[J"
+	cursorMark = "► "
+)
+
+// Cancelled is returned by Run when user pressed Esc
+const Cancelled = -1
+
+// Item is row of list
+type Item struct {
+	Label   string
+	Checked bool // state of checkbox in list with checkboxes
+}
+
+// Items makes items with given labels
+func Items(labels ...string) []Item {
+	items := make([]Item, len(labels))
+	for i, label := range labels {
+		items[i].Label = label
+	}
+	return items
+}
+
+// List is menu user moves through with arrows. Actions are shown above items
+// and never scroll away, items scroll when they don't fit screen. Rows are
+// numbered from first action to last item.
+type List struct {
+	// Header holds lines shown above list, they may contain line breaks
+	Header  []string
+	Actions []string
+	Items   []Item
+	// Checkboxes makes Enter and Space toggle items, only actions are chosen
+	Checkboxes bool
+	// MaxVisible limits number of visible items, 0 fits them to screen
+	MaxVisible int
+
+	cursor  int // row under cursor
+	offset  int // first visible item
+	visible int // number of visible items in last frame
+}
+
+// Cursor returns row under cursor
+func (l *List) Cursor() int {
+	return l.cursor
+}
+
+// Run draws list and handles keys until user chooses row. It returns chosen
+// row or Cancelled.
+func (l *List) Run(screen Screen, input Input) (int, error) {
+	for {
+		if err := l.Render(screen); err != nil {
+			return Cancelled, err
+		}
+		key, err := input.ReadKey()
+		if err != nil {
+			return Cancelled, err
+		}
+		if choice, done := l.HandleKey(key); done {
+			return choice, nil
+		}
+	}
+}
+
+// HandleKey moves cursor, toggles item or chooses row. Done is set when list
+// is finished with choice.
+func (l *List) HandleKey(key Key) (choice int, done bool) {
+	rows := len(l.Actions) + len(l.Items)
+	if key == KeyEsc {
+		return Cancelled, true
+	}
+	if rows == 0 {
+		return 0, false
+	}
+
+	page := max(l.visible, 1)
+	switch key {
+	case KeyUp:
+		l.cursor = max(l.cursor-1, 0)
+	case KeyDown:
+		l.cursor = min(l.cursor+1, rows-1)
+	case KeyPageUp:
+		l.cursor = max(l.cursor-page, 0)
+	case KeyPageDown:
+		l.cursor = min(l.cursor+page, rows-1)
+	case KeyHome:
+		l.cursor = 0
+	case KeyEnd:
+		l.cursor = rows - 1
+	case KeyEnter, KeySpace:
+		if !l.Checkboxes {
+			// Space is only for checkboxes, so it can't start anything by mistake
+			return l.cursor, key == KeyEnter
+		}
+		if item := l.cursor - len(l.Actions); item >= 0 {
+			l.Items[item].Checked = !l.Items[item].Checked
+			return 0, false
+		}
+		return l.cursor, true
+	}
+	return 0, false
+}
+
+// Render draws whole list on screen with single write
+func (l *List) Render(screen Screen) error {
+	width, height := screen.Size()
+	var buf bytes.Buffer
+	buf.WriteString(clearFrame)
+
+	used := 0
+	for _, line := range l.Header {
+		buf.WriteString(line + "\n")
+		used += lineRows(line, width)
+	}
+	for i, action := range l.Actions {
+		l.writeRow(&buf, i, action, width)
+	}
+	used += len(l.Actions)
+	if len(l.Actions) > 0 && len(l.Items) > 0 {
+		buf.WriteString("\n")
+		used++
+	}
+
+	// Two rows are kept for scroll indicators and one below them, so frame
+	// ending with line break doesn't scroll terminal
+	l.visible = height - used - 3
+	if l.MaxVisible > 0 {
+		l.visible = min(l.visible, l.MaxVisible)
+	}
+	l.visible = max(l.visible, 1)
+	l.scroll()
+
+	if len(l.Items) > 0 {
+		if l.offset > 0 {
+			buf.WriteString(colorGrey + "↑ more items above" + colorReset)
+		}
+		buf.WriteString("\n")
+	}
+	end := min(l.offset+l.visible, len(l.Items))
+	for i := l.offset; i < end; i++ {
+		label := l.Items[i].Label
+		if l.Checkboxes {
+			if l.Items[i].Checked {
+				label = "[+] " + label
+			} else {
+				label = "[ ] " + label
+			}
+		}
+		l.writeRow(&buf, len(l.Actions)+i, label, width)
+	}
+	if end < len(l.Items) {
+		buf.WriteString(colorGrey + "↓ more items below" + colorReset + "\n")
+	}
+
+	_, err := screen.Write(buf.Bytes())
+	return err
+}
+
+// scroll moves visible part of items so item under cursor is in it
+func (l *List) scroll() {
+	if item := l.cursor - len(l.Actions); item >= 0 {
+		if item < l.offset {
+			l.offset = item
+		}
+		if item >= l.offset+l.visible {
+			l.offset = item - l.visible + 1
+		}
+	}
+	l.offset = max(0, min(l.offset, len(l.Items)-l.visible))
+}
+
+// writeRow writes row cut to width of screen, long row would wrap and push
+// list down
+func (l *List) writeRow(buf *bytes.Buffer, row int, label string, width int) {
+	text := truncate(label, width-utf8.RuneCountInString(cursorMark))
+	if row == l.cursor {
+		buf.WriteString(colorCyan + cursorMark + text + colorReset + "\n")
+	} else {
+		buf.WriteString("  " + text + "\n")
+	}
+}
+
+// truncate cuts text to given number of characters
+func truncate(text string, n int) string {
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	if n <= 0 {
+		return ""
+	}
+	runes := []rune(text)
+	return string(runes[:n-1]) + "…"
+}
+
+// lineRows returns number of terminal rows text takes, counting wrapped lines
+func lineRows(text string, width int) int {
+	rows := 0
+	for _, line := range strings.Split(text, "\n") {
+		length := utf8.RuneCountInString(stripEscapes(line))
+		rows += max(1, (length+width-1)/width)
+	}
+	return rows
+}
+
+// stripEscapes removes control sequences like colors from text
+func stripEscapes(text string) string {
+	if !strings.Contains(text, "\033") {
+		return text
+	}
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\033' {
+			b.WriteByte(text[i])
+			continue
+		}
+		if i+1 < len(text) && text[i+1] == '[' {
+			i += 2
+			for i < len(text) && (text[i] < 0x40 || text[i] > 0x7e) {
+				i++
+			}
+		}
+	}
+	return b.String()
+}
+
+// Show runs list in console. Terminal is restored before it returns, so
+// caller can print results or start other programs right away.
+func Show(l *List) (int, error) {
+	console := OpenConsole()
+	defer console.Close()
+	keys, err := OpenKeyboard()
+	if err != nil {
+		return Cancelled, err
+	}
+	defer keys.Close()
+	return l.Run(console, keys)
+}
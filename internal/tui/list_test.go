@@ -0,0 +1,186 @@
+package tui
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with current output")
+
+func labels(prefix string, n int) []string {
+	var result []string
+	for i := 1; i <= n; i++ {
+		result = append(result, fmt.Sprintf("%s %02d", prefix, i))
+	}
+	return result
+}
+
+func repeatKey(key Key, n int) []Key {
+	keys := make([]Key, n)
+	for i := range keys {
+		keys[i] = key
+	}
+	return keys
+}
+
+func TestListRender(t *testing.T) {
+	menu := func() *List {
+		return &List{
+			Header: []string{"Select pre-config", "==="},
+			Items:  Items(labels("pre-config", 20)...),
+		}
+	}
+	checklist := func() *List {
+		return &List{
+			Header:     []string{"Use arrows", ""},
+			Actions:    []string{"SAVE LIST", "CANCEL"},
+			Items:      Items(labels("list", 8)...),
+			Checkboxes: true,
+			MaxVisible: 4,
+		}
+	}
+
+	tests := []struct {
+		name          string
+		list          *List
+		width, height int
+		keys          []Key
+		// choice is expected result of last key, -2 when list isn't done
+		choice int
+	}{
+		{name: "top", list: menu(), width: 40, height: 12, choice: -2},
+		// Cursor on last visible row stays there until next Down
+		{name: "last_visible", list: menu(), width: 40, height: 12, keys: repeatKey(KeyDown, 6), choice: -2},
+		// Next Down scrolls by exactly one row
+		{name: "scroll_one", list: menu(), width: 40, height: 12, keys: repeatKey(KeyDown, 7), choice: -2},
+		{name: "bottom", list: menu(), width: 40, height: 12, keys: []Key{KeyEnd}, choice: -2},
+		{name: "down_past_bottom", list: menu(), width: 40, height: 12, keys: append([]Key{KeyEnd}, repeatKey(KeyDown, 3)...), choice: -2},
+		{name: "up_to_top", list: menu(), width: 40, height: 12, keys: append(repeatKey(KeyDown, 10), repeatKey(KeyUp, 12)...), choice: -2},
+		{name: "page_down", list: menu(), width: 40, height: 12, keys: []Key{KeyPageDown, KeyPageDown}, choice: -2},
+		{name: "page_up", list: menu(), width: 40, height: 12, keys: []Key{KeyEnd, KeyPageUp}, choice: -2},
+		{name: "enter", list: menu(), width: 40, height: 12, keys: []Key{KeyDown, KeyDown, KeyEnter}, choice: 2},
+		{name: "space_ignored", list: menu(), width: 40, height: 12, keys: []Key{KeyDown, KeySpace}, choice: -2},
+		{name: "esc", list: menu(), width: 40, height: 12, keys: []Key{KeyDown, KeyEsc}, choice: Cancelled},
+		{name: "checkbox_toggle", list: checklist(), width: 40, height: 20, keys: []Key{KeyDown, KeyDown, KeySpace, KeyDown, KeyEnter, KeyEnter}, choice: -2},
+		{name: "checkbox_scroll", list: checklist(), width: 40, height: 20, keys: []Key{KeyEnd, KeySpace, KeyUp, KeyUp, KeyUp, KeyUp}, choice: -2},
+		// Cursor goes back to actions, items keep their scroll position
+		{name: "checkbox_action", list: checklist(), width: 40, height: 20, keys: []Key{KeyEnd, KeyHome, KeyDown, KeySpace}, choice: 1},
+		{name: "truncate", list: &List{
+			Header: []string{"Narrow"},
+			Items:  Items("short", "GeneralFix (ALT Beeline, Rostelekom).bat", "exactly eighteen!!"),
+		}, width: 20, height: 10, keys: []Key{KeyDown}, choice: -2},
+		{name: "wrapped_header", list: &List{
+			Header: []string{
+				"Welcome!",
+				"\nUsing ARROWS on your keyboard, select BAT file from list for running or select 'Exit'.\n",
+				"For selection press ENTER.",
+			},
+			Items: Items(labels("pre-config", 20)...),
+		}, width: 30, height: 16, keys: repeatKey(KeyDown, 5), choice: -2},
+		{name: "empty", list: &List{Header: []string{"Nothing here"}}, width: 40, height: 6, keys: []Key{KeyDown, KeyEnter}, choice: -2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			screen := NewVirtualTerminal(test.width, test.height)
+			choice := -2
+			for _, key := range test.keys {
+				if err := test.list.Render(screen); err != nil {
+					t.Fatal(err)
+				}
+				if c, done := test.list.HandleKey(key); done {
+					choice = c
+				}
+			}
+			if choice != test.choice {
+				t.Errorf("choice = %d, want %d", choice, test.choice)
+			}
+			if err := test.list.Render(screen); err != nil {
+				t.Fatal(err)
+			}
+
+			got := screen.String()
+			// Frame must fit screen, otherwise terminal scrolls and header
+			// goes away
+			first := strings.SplitN(test.list.Header[0], "\n", 2)[0]
+			if line := strings.SplitN(got, "\n", 2)[0]; line != first {
+				t.Errorf("first row = %q, want %q, frame doesn't fit screen", line, first)
+			}
+			if rows := strings.Count(got, "\n"); rows >= test.height {
+				t.Errorf("frame takes %d rows of %d, last row must stay empty", rows, test.height)
+			}
+			checkGolden(t, got)
+		})
+	}
+}
+
+func checkGolden(t *testing.T, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", t.Name()[strings.Index(t.Name(), "/")+1:]+".golden")
+	if *update {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v, run tests with -update to create golden file", err)
+	}
+	if got != string(want) {
+		t.Errorf("frame differs from %s\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestRun(t *testing.T) {
+	list := &List{Items: Items("Exit", "Run", "Check")}
+	keys := Keys{KeyDown, KeyDown, KeyUp, KeyEnter}
+	choice, err := list.Run(NewVirtualTerminal(40, 10), &keys)
+	if err != nil || choice != 1 {
+		t.Errorf("Run = %d, %v, want 1, nil", choice, err)
+	}
+
+	keys = Keys{KeyDown}
+	if _, err := list.Run(NewVirtualTerminal(40, 10), &keys); err == nil {
+		t.Error("Run without Enter must fail when input ends")
+	}
+}
+
+func TestVirtualTerminal(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		want   string
+	}{
+		{"plain", []string{"ab\ncd\n"}, "ab\ncd\n"},
+		{"colors", []string{"\033[36m► a\033[0m\n"}, "► a\n"},
+		// Writing last column doesn't move cursor to next row until next
+		// character, so full row followed by line break takes one row
+		{"full_row", []string{"abcde\nf"}, "abcde\nf\n"},
+		{"wrap", []string{"abcdefg"}, "abcde\nfg\n"},
+		{"clear", []string{"abc\ndef\n", "\033[H\033[Jxy"}, "xy\n"},
+		{"clear_to_end", []string{"abc\ndef", "\033[1;2H\033[J"}, "a\n"},
+		{"cursor_up", []string{"a\nb\nc", "\033[2AX"}, "aX\nb\nc\n"},
+		{"scroll", []string{"1\n2\n3\n4\n5"}, "3\n4\n5\n"},
+		{"split_sequence", []string{"a\033[", "36mb\033", "[0mc"}, "abc\n"},
+		{"split_rune", []string{"a\xe2\x96", "\xbab"}, "a►b\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			screen := NewVirtualTerminal(5, 3)
+			for _, s := range test.writes {
+				screen.Write([]byte(s))
+			}
+			if got := screen.String(); got != test.want {
+				t.Errorf("screen = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/eiannone/keyboard"
+)
+
+// Terminal control
+const (
+	enterAltScreen = "\033[?1049h"
+	exitAltScreen  = "\033[?1049l"
+	hideCursor     = "\033[?25l"
+	showCursor     = "\033[?25h"
+
+	defaultWidth  = 80
+	defaultHeight = 24
+)
+
+// Screen is terminal list is drawn on
+type Screen interface {
+	Write(p []byte) (int, error)
+	// Size returns width and height of screen in characters
+	Size() (width, height int)
+}
+
+// Console is terminal program runs in. List is drawn on alternate screen, so
+// output printed before it stays untouched.
+type Console struct {
+	signals chan os.Signal
+}
+
+// OpenConsole switches terminal to alternate screen and restores it when
+// program is interrupted
+func OpenConsole() *Console {
+	c := &Console{signals: make(chan os.Signal, 1)}
+	signal.Notify(c.signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		if _, ok := <-c.signals; ok {
+			fmt.Print(showCursor + exitAltScreen)
+			keyboard.Close()
+			os.Exit(1)
+		}
+	}()
+	fmt.Print(enterAltScreen + hideCursor)
+	return c
+}
+
+func (c *Console) Write(p []byte) (int, error) {
+	return os.Stdout.Write(p)
+}
+
+// Size asks terminal for its size every time, so list follows resizing
+func (c *Console) Size() (int, int) {
+	cmd := exec.Command("stty", "size")
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	if err != nil {
+		return defaultWidth, defaultHeight
+	}
+
+	var rows, cols int
+	if _, err := fmt.Sscanf(string(out), "%d %d", &rows, &cols); err != nil || rows <= 0 || cols <= 0 {
+		return defaultWidth, defaultHeight
+	}
+	return cols, rows
+}
+
+// Close returns terminal to normal screen
+func (c *Console) Close() {
+	signal.Stop(c.signals)
+	close(c.signals)
+	fmt.Print(showCursor + exitAltScreen)
+}
@@ -0,0 +1,202 @@
+package tui
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// VirtualTerminal is screen in memory. It understands control sequences
+// lists use, so frames can be compared with golden files without console.
+// Colors are dropped.
+type VirtualTerminal struct {
+	width, height int
+	cells         [][]rune
+	row, col      int
+	// wrap is set when last column is written, next character goes to next
+	// row like in real terminals
+	wrap bool
+	// pending holds incomplete control sequence or character from end of
+	// previous write
+	pending []byte
+}
+
+// NewVirtualTerminal creates empty terminal of given size
+func NewVirtualTerminal(width, height int) *VirtualTerminal {
+	t := &VirtualTerminal{width: width, height: height}
+	t.cells = make([][]rune, height)
+	for i := range t.cells {
+		t.cells[i] = blankRow(width)
+	}
+	return t
+}
+
+func blankRow(width int) []rune {
+	row := make([]rune, width)
+	for i := range row {
+		row[i] = ' '
+	}
+	return row
+}
+
+func (t *VirtualTerminal) Size() (int, int) {
+	return t.width, t.height
+}
+
+func (t *VirtualTerminal) Write(p []byte) (int, error) {
+	data := append(t.pending, p...)
+	t.pending = nil
+	for len(data) > 0 {
+		switch data[0] {
+		case '\033':
+			n := sequenceLength(data)
+			if n == 0 {
+				t.pending = append([]byte{}, data...)
+				return len(p), nil
+			}
+			t.control(string(data[:n]))
+			data = data[n:]
+			continue
+		case '\n':
+			t.lineFeed()
+		case '\r':
+			t.col, t.wrap = 0, false
+		case '\b':
+			t.col, t.wrap = max(t.col-1, 0), false
+		case '\t':
+			t.col = min((t.col/8+1)*8, t.width-1)
+		default:
+			if !utf8.FullRune(data) {
+				t.pending = append([]byte{}, data...)
+				return len(p), nil
+			}
+			r, size := utf8.DecodeRune(data)
+			t.put(r)
+			data = data[size:]
+			continue
+		}
+		data = data[1:]
+	}
+	return len(p), nil
+}
+
+// sequenceLength returns length of control sequence at start of data, 0 if
+// it's incomplete
+func sequenceLength(data []byte) int {
+	if len(data) < 2 {
+		return 0
+	}
+	if data[1] != '[' {
+		return 2
+	}
+	for i := 2; i < len(data); i++ {
+		if data[i] >= 0x40 && data[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// control applies control sequence, unknown ones are ignored
+func (t *VirtualTerminal) control(sequence string) {
+	if len(sequence) < 3 || sequence[1] != '[' {
+		return
+	}
+	final := sequence[len(sequence)-1]
+	params := sequence[2 : len(sequence)-1]
+	if strings.HasPrefix(params, "?") {
+		// Alternate screen starts empty
+		if params == "?1049" && final == 'h' {
+			t.clear(0, 0)
+			t.row, t.col, t.wrap = 0, 0, false
+		}
+		return
+	}
+	args := strings.Split(params, ";")
+	arg := func(i, defaultValue int) int {
+		if i >= len(args) {
+			return defaultValue
+		}
+		n, err := strconv.Atoi(args[i])
+		if err != nil || n == 0 {
+			return defaultValue
+		}
+		return n
+	}
+
+	t.wrap = false
+	switch final {
+	case 'H', 'f':
+		t.row = min(arg(0, 1), t.height) - 1
+		t.col = min(arg(1, 1), t.width) - 1
+	case 'A':
+		t.row = max(t.row-arg(0, 1), 0)
+	case 'B':
+		t.row = min(t.row+arg(0, 1), t.height-1)
+	case 'C':
+		t.col = min(t.col+arg(0, 1), t.width-1)
+	case 'D':
+		t.col = max(t.col-arg(0, 1), 0)
+	case 'J':
+		switch params {
+		case "", "0":
+			t.clear(t.row, t.col)
+		case "2":
+			t.clear(0, 0)
+		}
+	case 'K':
+		for col := t.col; col < t.width; col++ {
+			t.cells[t.row][col] = ' '
+		}
+	}
+}
+
+// clear blanks screen from given position to end
+func (t *VirtualTerminal) clear(row, col int) {
+	for ; col < t.width; col++ {
+		t.cells[row][col] = ' '
+	}
+	for row++; row < t.height; row++ {
+		t.cells[row] = blankRow(t.width)
+	}
+}
+
+func (t *VirtualTerminal) put(r rune) {
+	if t.wrap {
+		t.lineFeed()
+	}
+	t.cells[t.row][t.col] = r
+	if t.col == t.width-1 {
+		t.wrap = true
+	} else {
+		t.col++
+	}
+}
+
+// lineFeed moves cursor to start of next row, scrolling screen at bottom
+func (t *VirtualTerminal) lineFeed() {
+	t.col, t.wrap = 0, false
+	if t.row < t.height-1 {
+		t.row++
+		return
+	}
+	copy(t.cells, t.cells[1:])
+	t.cells[t.height-1] = blankRow(t.width)
+}
+
+// String returns text on screen, one line per row, without trailing spaces
+// and empty rows at bottom
+func (t *VirtualTerminal) String() string {
+	lines := make([]string, t.height)
+	last := -1
+	for i, row := range t.cells {
+		lines[i] = strings.TrimRight(string(row), " ")
+		if lines[i] != "" {
+			last = i
+		}
+	}
+	if last < 0 {
+		return ""
+	}
+	return strings.Join(lines[:last+1], "\n") + "\n"
+}
@@ -0,0 +1,87 @@
+// Package winws builds commands that run winws with options of pre-config and
+// lets tests replace winws with stand-in through environment variable.
+package winws
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ankddev/zapret-discord-youtube/internal/preconfig"
+)
+
+// EnvPath is environment variable with path of executable to run instead of
+// winws.exe from BIN folder of pre-config, like fake_winws in tests
+const EnvPath = "ZAPRET_WINWS"
+
+// Override returns path of winws replacement, empty when real winws is used
+func Override() string {
+	return os.Getenv(EnvPath)
+}
+
+// ProcessName returns image name of winws process
+func ProcessName() string {
+	if path := Override(); path != "" {
+		return filepath.Base(path)
+	}
+	return "winws.exe"
+}
+
+// Command builds command running winws with arguments of pre-config the same
+// way "start" in pre-config would
+func Command(batFile string) (*exec.Cmd, error) {
+	abs, err := filepath.Abs(batFile)
+	if err != nil {
+		return nil, err
+	}
+	p, err := preconfig.Load(abs)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Trailing) > 0 {
+		return nil, fmt.Errorf("lines after winws command aren't part of it, missing ^")
+	}
+
+	// %~dp0 is directory of BAT file with trailing backslash
+	dir := filepath.Dir(abs) + string(filepath.Separator)
+	expand := func(s string) string {
+		return strings.ReplaceAll(p.Expand(s), "%~dp0", dir)
+	}
+
+	executable := Override()
+	if executable == "" {
+		bin, ok := p.Vars["BIN"]
+		if !ok {
+			return nil, fmt.Errorf("BIN variable isn't set")
+		}
+		executable = filepath.Clean(expand(bin) + "winws.exe")
+	}
+
+	var args []string
+	options := append([]preconfig.Option{}, p.Global...)
+	for i, profile := range p.Profiles {
+		if i > 0 {
+			options = append(options, preconfig.Option{Name: "new"})
+		}
+		options = append(options, profile.Options...)
+	}
+	for _, o := range options {
+		if o.HasValue {
+			args = append(args, "--"+o.Name+"="+expand(o.Value))
+		} else {
+			args = append(args, "--"+o.Name)
+		}
+	}
+
+	command := exec.Command(executable, args...)
+	// Pre-configs run from root folder of fix: cd /d "%~dp0..\"
+	command.Dir = filepath.Dir(filepath.Dir(abs))
+	return command, nil
+}
+
+// How long winws must stay alive to count as ready. winws exits right away
+// on unknown option or when WinDivert can't be initialized.
+const ReadyGrace = 500 * time.Millisecond
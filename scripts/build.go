@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ankddev/zapret-discord-youtube/internal/preconfig"
 	"github.com/briandowns/spinner"
 	"github.com/cli/safeexec"
 )
@@ -20,7 +21,12 @@ func main() {
 		os.Exit(1)
 	}
 
-	fmt.Println("[1/4] Building...")
+	fmt.Println("[1/5] Checking pre-configs...")
+	if !checkPreconfigs() {
+		os.Exit(1)
+	}
+
+	fmt.Println("[2/5] Building...")
 	ldflags := os.Getenv("GO_LDFLAGS")
 	ldflags = fmt.Sprintf("-X main.version=%s %s", version(), ldflags)
 	_ = os.Mkdir("build", os.ModePerm)
@@ -77,8 +83,8 @@ func main() {
 	}
 
 	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
-	s.Suffix = " [2/4] Adding directories..."
-	s.FinalMSG = "[2/4] Adding directories...\n"
+	s.Suffix = " [3/5] Adding directories..."
+	s.FinalMSG = "[3/5] Adding directories...\n"
 	s.HideCursor = true
 	s.Start()
 	for zipPath, fsPath := range dirsToAdd {
@@ -91,17 +97,21 @@ func main() {
 	s.Stop()
 
 	// Add individual files
-	s.Suffix = " [3/4] Adding files..."
-	s.FinalMSG = "[3/4] Adding files...\n"
+	s.Suffix = " [4/5] Adding files..."
+	s.FinalMSG = "[4/5] Adding files...\n"
 	s.HideCursor = true
 	s.Start()
 	filesToAdd := map[string]string{
-		"blockcheck.cmd":                      filepath.Join(currentDir, "blockcheck.cmd"),
-		"Add to autorun.exe":                  filepath.Join(buildDir, "add_to_autorun.exe"),
-		"Automatically search pre-config.exe": filepath.Join(buildDir, "preconfig_tester.exe"),
-		"Run pre-config.exe":                  filepath.Join(buildDir, "run_preconfig.exe"),
-		"Set domain list.exe":                 filepath.Join(buildDir, "select_domains.exe"),
-		"Check for updates.exe":               filepath.Join(buildDir, "check_for_updates.exe"),
+		"blockcheck.cmd":                        filepath.Join(currentDir, "blockcheck.cmd"),
+		"Add to autorun.exe":                    filepath.Join(buildDir, "add_to_autorun.exe"),
+		"Automatically search pre-config.exe":   filepath.Join(buildDir, "preconfig_tester.exe"),
+		"Run pre-config.exe":                    filepath.Join(buildDir, "run_preconfig.exe"),
+		"Set domain list.exe":                   filepath.Join(buildDir, "select_domains.exe"),
+		"Check for updates.exe":                 filepath.Join(buildDir, "check_for_updates.exe"),
+		"Create pre-config from blockcheck.exe": filepath.Join(buildDir, "generate_preconfig.exe"),
+		"Fake payload tool.exe":                 filepath.Join(buildDir, "fake_payload.exe"),
+		"Analyze capture.exe":                   filepath.Join(buildDir, "pcap_analyzer.exe"),
+		"Explain pre-config.exe":                filepath.Join(buildDir, "explain_preconfig.exe"),
 	}
 
 	for zipPath, fsPath := range filesToAdd {
@@ -114,7 +124,7 @@ func main() {
 
 	s.Stop()
 
-	fmt.Println("[4/4] Release archive created successfully!")
+	fmt.Println("[5/5] Release archive created successfully!")
 	fmt.Printf("\nRelease build ready! Check '%s'\n", zipPath)
 	fmt.Println("Press Enter to continue...")
 	fmt.Scanln()
@@ -157,6 +167,27 @@ func addFileToZip(zipWriter *zip.Writer, zipPath string, fsPath string) error {
 	return err
 }
 
+// checkPreconfigs makes sure every pre-config passes payload of right kind to
+// fake options. Missing files are only reported, they may exist on user side.
+func checkPreconfigs() bool {
+	preconfigs, err := preconfig.LoadDir("pre-configs")
+	if err != nil {
+		fmt.Printf("Error reading pre-configs: %v\n", err)
+		return false
+	}
+	ok := true
+	for _, problem := range preconfig.LintPayloads(preconfigs) {
+		fmt.Println(problem)
+		if problem.Severity == preconfig.Error {
+			ok = false
+		}
+	}
+	if !ok {
+		fmt.Println("Pre-configs check failed")
+	}
+	return ok
+}
+
 // version returns version from environment variable or git describe
 func version() string {
 	if versionEnv := os.Getenv("VERSION"); versionEnv != "" {